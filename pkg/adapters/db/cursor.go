@@ -0,0 +1,213 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/responses"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Cursor represents a position in a sorted list.
+// It is sent to clients as an opaque string.
+type Cursor struct {
+	Sorts    string        `json:"s"`           // Signature of the sort used to build the cursor
+	Values   []cursorValue `json:"v"`           // Values of the sort columns of the row
+	Backward bool          `json:"b,omitempty"` // Rows before the cursor are requested
+}
+
+// cursorValue is a typed cursor value, so that times are not compared as strings.
+type cursorValue struct {
+	Time  *time.Time `json:"t,omitempty"`
+	Value any        `json:"v"`
+}
+
+// value returns the value to bind to the query.
+func (v cursorValue) value() any {
+	if v.Time != nil {
+		return *v.Time
+	}
+	return v.Value
+}
+
+// Encode returns the cursor as a base64url string.
+func (c Cursor) Encode() (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor decodes a cursor returned by Cursor.Encode.
+func DecodeCursor(s string) (c Cursor, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	err = d.Decode(&c)
+	return c, err
+}
+
+// SortsSignature returns a string representing the sort columns and directions.
+// Example: "-name,+id"
+func SortsSignature(sorts []Sort) string {
+	parts := make([]string, len(sorts))
+	for i, s := range sorts {
+		if s.Desc {
+			parts[i] = "-" + s.Column
+		} else {
+			parts[i] = "+" + s.Column
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// keysetCondition returns the condition selecting the rows after (or before if backward is true)
+// the cursor values, for the sort columns.
+// Example for "-name,+id": name < v1 OR (name = v1 AND id > v2)
+func keysetCondition(sorts []Sort, values []cursorValue, backward bool) clause.Expression {
+	conditions := make([]clause.Expression, 0, len(sorts))
+	for i, s := range sorts {
+		exprs := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			exprs = append(exprs, clause.Eq{Column: clause.Column{Name: sorts[j].Column}, Value: values[j].value()})
+		}
+
+		column := clause.Column{Name: s.Column}
+		if s.Desc != backward {
+			exprs = append(exprs, clause.Lt{Column: column, Value: values[i].value()})
+		} else {
+			exprs = append(exprs, clause.Gt{Column: column, Value: values[i].value()})
+		}
+		conditions = append(conditions, clause.And(exprs...))
+	}
+	return clause.Or(conditions...)
+}
+
+// invalidCursor returns the error returned when a cursor cannot be used.
+func invalidCursor() error {
+	return utils.ValidatorErrors{{FailedField: "cursor", Tag: "cursor"}}
+}
+
+// FindPage finds a page of items of the query sorted by the sortable fields.
+// The page starts after (or before) the cursor if it is set, or at the page number otherwise.
+// The total number of items matching the query is only counted if requested.
+// Invalid sorts or cursors return a utils.ValidatorErrors.
+func FindPage[T any](q *gorm.DB, pagination requests.Pagination, fields SortableFields) (items []T, page responses.Pagination, err error) {
+	sorts, err := SortColumns(pagination.Sorts, fields)
+	if err != nil {
+		return items, page, err
+	}
+	signature := SortsSignature(sorts)
+
+	var cursor Cursor
+	if pagination.Cursor != "" {
+		cursor, err = DecodeCursor(pagination.Cursor)
+		if err != nil || cursor.Sorts != signature || len(cursor.Values) != len(sorts) {
+			return items, page, invalidCursor()
+		}
+	}
+
+	q = q.Model(new(T))
+
+	// Total rows
+	// ----------
+	if pagination.Total {
+		var total int64
+		if err = q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return items, page, err
+		}
+		page.Total = &total
+	}
+
+	// Page rows
+	// ---------
+	offset, limit := PaginateValues(pagination.Page, pagination.Limit)
+	q = q.Session(&gorm.Session{})
+	if pagination.Cursor != "" {
+		q = q.Where(keysetCondition(sorts, cursor.Values, cursor.Backward))
+	} else {
+		q = q.Offset(offset)
+	}
+
+	columns := make([]clause.OrderByColumn, len(sorts))
+	for i, s := range sorts {
+		columns[i] = clause.OrderByColumn{Column: clause.Column{Name: s.Column}, Desc: s.Desc != cursor.Backward}
+	}
+
+	// One more row is fetched to know if there is another page
+	result := q.Clauses(clause.OrderBy{Columns: columns}).Limit(limit + 1).Find(&items)
+	if result.Error != nil {
+		return items, page, result.Error
+	}
+
+	hasMore := len(items) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+	if cursor.Backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	// Cursors
+	// -------
+	if len(items) == 0 {
+		return items, page, nil
+	}
+
+	hasNext := hasMore || cursor.Backward
+	hasPrev := (pagination.Cursor != "" && !cursor.Backward) || (cursor.Backward && hasMore) || (pagination.Cursor == "" && offset > 0)
+
+	if hasNext {
+		if page.NextCursor, err = newCursor(result, items[len(items)-1], sorts, signature, false); err != nil {
+			return items, page, err
+		}
+	}
+	if hasPrev {
+		if page.PrevCursor, err = newCursor(result, items[0], sorts, signature, true); err != nil {
+			return items, page, err
+		}
+	}
+
+	return items, page, nil
+}
+
+// newCursor returns the encoded cursor pointing at the item.
+func newCursor[T any](q *gorm.DB, item T, sorts []Sort, signature string, backward bool) (string, error) {
+	cursor := Cursor{
+		Sorts:    signature,
+		Values:   make([]cursorValue, len(sorts)),
+		Backward: backward,
+	}
+
+	rv := reflect.ValueOf(&item).Elem()
+	for i, s := range sorts {
+		field := q.Statement.Schema.LookUpField(s.Column)
+		if field == nil {
+			return "", invalidCursor()
+		}
+
+		value, _ := field.ValueOf(context.Background(), rv)
+		if t, ok := value.(time.Time); ok {
+			cursor.Values[i] = cursorValue{Time: &t}
+		} else {
+			cursor.Values[i] = cursorValue{Value: value}
+		}
+	}
+
+	return cursor.Encode()
+}
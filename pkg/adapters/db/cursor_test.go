@@ -0,0 +1,103 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestCursorEncodeDecode(t *testing.T) {
+	createdAt := time.Date(2024, 3, 12, 8, 30, 15, 123000000, time.UTC)
+	cursor := Cursor{
+		Sorts:    "+created_at,+id",
+		Values:   []cursorValue{{Time: &createdAt}, {Value: "2f7d3c8e-8d4f-4a65-a7a4-9bb9b1ea6d8c"}},
+		Backward: true,
+	}
+
+	s, err := cursor.Encode()
+	assert.Nil(t, err)
+	assert.NotContains(t, s, "=")
+
+	decoded, err := DecodeCursor(s)
+	assert.Nil(t, err)
+	assert.Equal(t, cursor.Sorts, decoded.Sorts)
+	assert.True(t, decoded.Backward)
+	assert.True(t, createdAt.Equal(decoded.Values[0].value().(time.Time)))
+	assert.Equal(t, "2f7d3c8e-8d4f-4a65-a7a4-9bb9b1ea6d8c", decoded.Values[1].value())
+
+	_, err = DecodeCursor("not a cursor")
+	assert.NotNil(t, err)
+}
+
+func TestKeysetCondition(t *testing.T) {
+	gormDB, err := gorm.Open(mysql.New(mysql.Config{SkipInitializeWithVersion: true}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	assert.Nil(t, err)
+
+	type task struct {
+		ID   string
+		Name string
+	}
+	sorts := []Sort{{Column: "name", Desc: true}, {Column: "id"}}
+	values := []cursorValue{{Value: "Task"}, {Value: "1"}}
+
+	stmt := gormDB.Where(keysetCondition(sorts, values, false)).Find(&[]task{}).Statement
+	assert.Equal(t, "SELECT * FROM `tasks` WHERE (`name` < ? OR (`name` = ? AND `id` > ?))", stmt.SQL.String())
+	assert.Equal(t, []interface{}{"Task", "Task", "1"}, stmt.Vars)
+
+	stmt = gormDB.Where(keysetCondition(sorts, values, true)).Find(&[]task{}).Statement
+	assert.Equal(t, "SELECT * FROM `tasks` WHERE (`name` > ? OR (`name` = ? AND `id` < ?))", stmt.SQL.String())
+}
+
+func TestFindPageInvalidCursor(t *testing.T) {
+	gormDB, err := gorm.Open(mysql.New(mysql.Config{SkipInitializeWithVersion: true}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	assert.Nil(t, err)
+
+	type task struct {
+		ID   string
+		Name string
+	}
+	fields := SortableFields{"id": "id", "name": "name"}
+	invalid := utils.ValidatorErrors{{FailedField: "cursor", Tag: "cursor"}}
+
+	_, _, err = FindPage[task](gormDB, requests.Pagination{Cursor: "not a cursor"}, fields)
+	assert.Equal(t, invalid, err)
+
+	// Cursor built with another sort
+	cursor, _ := Cursor{Sorts: "+id", Values: []cursorValue{{Value: "1"}}}.Encode()
+	_, _, err = FindPage[task](gormDB, requests.Pagination{Sorts: "-name", Cursor: cursor}, fields)
+	assert.Equal(t, invalid, err)
+}
+
+func TestFindPageEmptySortValues(t *testing.T) {
+	database, err := New(&DatabaseConfig{Driver: DriverSQLite, Database: SQLiteMemory})
+	assert.Nil(t, err)
+
+	type item struct {
+		ID   string
+		Name string
+	}
+	assert.Nil(t, database.AutoMigrate(&item{}))
+	assert.Nil(t, database.Create(&[]item{{ID: "1", Name: ""}, {ID: "2", Name: ""}, {ID: "3", Name: ""}, {ID: "4", Name: "Task"}}).Error)
+
+	fields := SortableFields{"id": "id", "name": "name"}
+	pagination := requests.Pagination{Sorts: "+name", Limit: "2"}
+
+	var ids []string
+	for {
+		items, page, err := FindPage[item](database.DB, pagination, fields)
+		assert.Nil(t, err)
+		for _, i := range items {
+			ids = append(ids, i.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		pagination.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"1", "2", "3", "4"}, ids)
+}
@@ -1,19 +1,27 @@
 package db
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/glebarez/sqlite"
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 	"gorm.io/plugin/prometheus"
 )
@@ -26,21 +34,33 @@ const (
 	DefaultSlowThreshold time.Duration = 200 * time.Millisecond
 )
 
+// Database drivers
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// SQLiteMemory is the SQLite database name of an in-memory database.
+const SQLiteMemory = ":memory:"
+
 // DatabaseConfig represents the database configuration.
 type DatabaseConfig struct {
-	Driver          string // Not used
+	Driver          string // mysql (default), postgres or sqlite
 	Host            string
 	Username        string
 	Password        string
 	Port            int
-	Database        string
-	Charset         string
-	Collation       string
+	Database        string // Database file path or ":memory:" for SQLite
+	Charset         string // MySQL only
+	Collation       string // MySQL only
 	Location        string
+	SSLMode         string        // PostgreSQL only (Default: disable)
 	MaxIdleConns    int           // Sets the maximum number of connections in the idle connection pool
 	MaxOpenConns    int           // Sets the maximum number of open connections to the database
 	ConnMaxLifetime time.Duration // Sets the maximum amount of time a connection may be reused
 	SlowThreshold   time.Duration // Slow SQL threshold (Default: 200ms)
+	Logger          *zap.Logger   // Logger of the SQL queries (Default: JSON logger writing to GORM_LOG_OUTPUT)
 }
 
 // DB represents the database.
@@ -48,6 +68,14 @@ type DB struct {
 	*gorm.DB
 }
 
+// WithinTx runs fn in a transaction, which is committed if fn returns nil and rolled back otherwise.
+// If the database is already in a transaction, a nested transaction (savepoint) is used.
+func (db *DB) WithinTx(ctx context.Context, fn func(tx *DB) error) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&DB{tx})
+	})
+}
+
 // New makes the connection to the database.
 func New(config *DatabaseConfig) (*DB, error) {
 	dsn, err := config.dsn()
@@ -63,76 +91,59 @@ func New(config *DatabaseConfig) (*DB, error) {
 	// -------------------------
 	env := viper.GetString("APP_ENV")
 	level := getGormLogLevel(viper.GetString("GORM_LOG_LEVEL"), env)
-	output, err := getGormLogOutput(viper.GetString("GORM_LOG_OUTPUT"),
-		viper.GetString("GORM_LOG_FILE_PATH"),
-		env)
-	if err != nil {
-		return nil, err
+	zapLogger := config.Logger
+	if zapLogger == nil {
+		output, err := getGormLogOutput(viper.GetString("GORM_LOG_OUTPUT"),
+			viper.GetString("GORM_LOG_FILE_PATH"),
+			env)
+		if err != nil {
+			return nil, err
+		}
+		zapLogger = newZapLogger(output)
 	}
 
-	// Logger
-	// ------
-	// TODO: Add a custom logger for GORM like https://www.soberkoder.com/go-gorm-logging/
-	// Or try something like this: https://github.com/moul/zapgorm2
-	customLogger := logger.New(
-		log.New(output, "\r\n", log.LstdFlags), // io writer
-		logger.Config{
-			SlowThreshold:             config.SlowThreshold, // Slow SQL threshold (Default: 200ms)
-			LogLevel:                  level,                // Log level (Silent, Error, Warn, Info) (Default: Warn)
-			IgnoreRecordNotFoundError: true,                 // Ignore ErrRecordNotFound error for logger (Default: false)
-			Colorful:                  true,                 // Disable color (Default: true)
-		},
-	)
-
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: customLogger,
+	db, err := gorm.Open(config.dialector(dsn), &gorm.Config{
+		Logger:         NewGormLogger(zapLogger, level, config.SlowThreshold),
+		TranslateError: true, // Driver errors like duplicated keys are translated to GORM errors
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Options
-	// -------
-	db.Set("gorm:table_options", "ENGINE=InnoDB")
-
 	// Prometheus
 	// ----------
+	var collectors []prometheus.MetricsCollector
+	if config.driver() == DriverMySQL {
+		collectors = append(collectors, &prometheus.MySQL{
+			VariableNames: []string{"Threads_running"},
+		})
+	}
 	db.Use(prometheus.New(prometheus.Config{
-		DBName:          viper.GetString("DB_DATABASE"), // Use `DBName` as metrics label
-		RefreshInterval: 60,                             // Refresh metrics interval (default 15 seconds)
-		StartServer:     false,                          // Start http server to expose metrics
-		MetricsCollector: []prometheus.MetricsCollector{
-			&prometheus.MySQL{
-				VariableNames: []string{"Threads_running"},
-			},
-		}, // user defined metrics
+		DBName:           config.Database, // Use `DBName` as metrics label
+		RefreshInterval:  60,              // Refresh metrics interval (default 15 seconds)
+		StartServer:      false,           // Start http server to expose metrics
+		MetricsCollector: collectors,      // user defined metrics
 	}))
 
 	// Connection Pool
 	// ---------------
 	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
 	sqlDB.SetMaxIdleConns(config.MaxIdleConns)
 	sqlDB.SetMaxOpenConns(config.MaxOpenConns)
 	sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
 
-	return &DB{db}, err
-}
-
-// MakeMigrations runs GORM migrations.
-func (db *DB) MakeMigrations() error {
-	// Auto migrations
-	if err := db.AutoMigrate(entitiesList...); err != nil {
-		return err
+	// An in-memory SQLite database only exists as long as its connection
+	if config.driver() == DriverSQLite && config.Database == SQLiteMemory {
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
 	}
 
-	// Custom migrations
-	for _, m := range migrations {
-		if err := m(db); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return &DB{db}, nil
 }
 
 // getGormLogLevel returns the log level for GORM.
@@ -176,32 +187,128 @@ func getGormLogOutput(output, filePath, env string) (file io.Writer, err error)
 	}
 }
 
+// newZapLogger returns a JSON zap logger writing to output, with the same format as the server logs.
+func newZapLogger(output io.Writer) *zap.Logger {
+	encoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+		MessageKey:  "message",
+		LevelKey:    "level",
+		EncodeLevel: zapcore.CapitalLevelEncoder,
+		TimeKey:     "time",
+		EncodeTime:  zapcore.RFC3339TimeEncoder,
+	})
+
+	return zap.New(zapcore.NewCore(encoder, zapcore.AddSync(output), zapcore.DebugLevel))
+}
+
+// driver returns the database driver, MySQL by default.
+func (c *DatabaseConfig) driver() string {
+	if c.Driver == "" {
+		return DriverMySQL
+	}
+	return c.Driver
+}
+
+// dialector returns the GORM dialector of the driver.
+func (c *DatabaseConfig) dialector(dsn string) gorm.Dialector {
+	switch c.driver() {
+	case DriverPostgres:
+		return postgres.Open(dsn)
+	case DriverSQLite:
+		return sqlite.Open(dsn)
+	default:
+		return mysql.Open(dsn)
+	}
+}
+
 // dsn returns the DSN if the configuration is OK or an error in other case.
 func (c *DatabaseConfig) dsn() (dsn string, err error) {
+	switch c.driver() {
+	case DriverMySQL:
+		return c.mysqlDSN()
+	case DriverPostgres:
+		return c.postgresDSN()
+	case DriverSQLite:
+		return c.sqliteDSN()
+	default:
+		return dsn, fmt.Errorf("unsupported database driver: %s", c.Driver)
+	}
+}
+
+// mysqlDSN returns the MySQL DSN.
+// The DSN is built by the driver, so that the password can contain reserved characters like @, / or :.
+func (c *DatabaseConfig) mysqlDSN() (dsn string, err error) {
 	if c.Host == "" || c.Port == 0 || c.Username == "" || c.Password == "" || c.Database == "" {
 		return dsn, errors.New("error in database configuration")
 	}
 
-	dsn = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=True",
-		c.Username,
-		c.Password,
-		c.Host,
-		c.Port,
-		c.Database)
+	config := mysqldriver.NewConfig()
+	config.User = c.Username
+	config.Passwd = c.Password
+	config.Net = "tcp"
+	config.Addr = fmt.Sprintf("%s:%d", c.Host, c.Port)
+	config.DBName = c.Database
+	config.ParseTime = true
+	config.Collation = c.Collation
 	if c.Charset != "" {
-		dsn += fmt.Sprintf("&charset=%s", c.Charset)
-	}
-	if c.Collation != "" {
-		dsn += fmt.Sprintf("&collation=%s", c.Collation)
+		config.Params = map[string]string{"charset": c.Charset}
 	}
 	if c.Location != "" {
-		dsn += fmt.Sprintf("&loc=%s", c.Location)
+		if config.Loc, err = time.LoadLocation(c.Location); err != nil {
+			return "", fmt.Errorf("invalid database location: %w", err)
+		}
+	}
+	return config.FormatDSN(), nil
+}
+
+// postgresDSN returns the PostgreSQL DSN.
+// The password can be empty (trust or .pgpass authentication).
+func (c *DatabaseConfig) postgresDSN() (dsn string, err error) {
+	if c.Host == "" || c.Port == 0 || c.Username == "" || c.Database == "" {
+		return dsn, errors.New("error in database configuration")
+	}
+
+	sslMode := c.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+
+	dsn = fmt.Sprintf("host=%s port=%d user=%s dbname=%s sslmode=%s",
+		postgresValue(c.Host),
+		c.Port,
+		postgresValue(c.Username),
+		postgresValue(c.Database),
+		postgresValue(sslMode))
+	if c.Password != "" {
+		dsn += fmt.Sprintf(" password=%s", postgresValue(c.Password))
+	}
+	if c.Location != "" && c.Location != "Local" {
+		dsn += fmt.Sprintf(" TimeZone=%s", postgresValue(c.Location))
 	}
 	return
 }
 
-// paginateValues transforms page and limit into offset and limit.
-func paginateValues(p, l string) (offset int, limit int) {
+// postgresValue quotes a value of a PostgreSQL DSN, following the libpq rules:
+// empty values and values containing spaces are surrounded with single quotes,
+// and single quotes and backslashes are escaped with a backslash.
+func postgresValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, " \t\n\r\f\v'\\") {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// sqliteDSN returns the SQLite DSN.
+// Foreign keys are enabled, they are disabled by default in SQLite.
+func (c *DatabaseConfig) sqliteDSN() (dsn string, err error) {
+	if c.Database == "" {
+		return dsn, errors.New("error in database configuration")
+	}
+
+	return c.Database + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)", nil
+}
+
+// PaginateValues transforms page and limit into offset and limit.
+func PaginateValues(p, l string) (offset int, limit int) {
 	page, err := strconv.Atoi(p)
 	if err != nil || page < 1 {
 		page = 1
@@ -217,51 +324,108 @@ func paginateValues(p, l string) (offset int, limit int) {
 	return
 }
 
-// Paginate creates a GORM scope to paginate queries.
-func Paginate(p, l string) func(db *gorm.DB) *gorm.DB {
-	return func(db *gorm.DB) *gorm.DB {
-		offset, limit := paginateValues(p, l)
+// SortableFields maps the names of the fields which can be sorted in the API to their database columns.
+type SortableFields map[string]string
 
-		return db.Offset(offset).Limit(limit)
+// names returns the sorted list of the fields names.
+func (f SortableFields) names() []string {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
 }
 
-// orderValues transforms list of fields to sort into a map.
-func orderValues(list string, prefixes ...string) map[string]string {
-	r := make(map[string]string)
+// Sort represents a column to sort on.
+type Sort struct {
+	Column string
+	Desc   bool
+}
+
+// orderValues transforms a list of fields to sort into a list of columns, in the same order.
+// A field can be prefixed by "+" (ASC, default) or "-" (DESC). Empty fields are ignored.
+// If a field is not sortable, a utils.ValidatorErrors is returned.
+func orderValues(list string, fields SortableFields) ([]Sort, error) {
+	sorts := make([]Sort, 0)
+	var errs utils.ValidatorErrors
+
+	for _, s := range strings.Split(list, ",") {
+		// "+" is decoded as a space in query strings
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		desc := false
+		if strings.HasPrefix(s, "-") {
+			desc = true
+			s = s[1:]
+		} else if strings.HasPrefix(s, "+") {
+			s = s[1:]
+		}
+
+		column, ok := fields[s]
+		if !ok {
+			errs = append(errs, utils.ValidatorError{
+				FailedField: "s",
+				Tag:         "sort",
+				Value:       s,
+				Allowed:     fields.names(),
+			})
+			continue
+		}
+		sorts = append(sorts, Sort{Column: column, Desc: desc})
+	}
 
-	if len(list) <= 0 {
-		return r
+	if len(errs) > 0 {
+		return nil, errs
 	}
+	return sorts, nil
+}
 
-	prefix := ""
-	if len(prefixes) == 1 {
-		prefix = prefixes[0] + "."
+// SortColumns returns the columns to sort on, with the ID column added at the end,
+// if not already present, to have a stable order.
+// If a field is not sortable, a utils.ValidatorErrors is returned.
+func SortColumns(list string, fields SortableFields) ([]Sort, error) {
+	sorts, err := orderValues(list, fields)
+	if err != nil {
+		return nil, err
 	}
 
-	sorts := strings.Split(list, ",")
 	for _, s := range sorts {
-		key := fmt.Sprintf("%s%s", prefix, s[1:])
-		if strings.HasPrefix(s, "+") && len(s[1:]) > 1 {
-			r[key] = "ASC"
-		} else if strings.HasPrefix(s, "-") && len(s[1:]) > 1 {
-			r[key] = "DESC"
+		if s.Column == "id" {
+			return sorts, nil
 		}
 	}
-
-	return r
+	return append(sorts, Sort{Column: "id"}), nil
 }
 
 // Order creates a GORM scope to sort query attributes.
-// Example: "+created_at,-id" will produce "ORDER BY created_at ASC, id DESC".
-func Order(list string, prefixes ...string) func(db *gorm.DB) *gorm.DB {
+// Example: "+created_at,-name" will produce "ORDER BY created_at, name DESC, id".
+// Only the fields declared as sortable are accepted, the query fails with a utils.ValidatorErrors otherwise.
+// The ID column is always added at the end, if not already present, to have a stable order.
+func Order(list string, fields SortableFields, prefixes ...string) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
-		values := orderValues(list, prefixes...)
+		sorts, err := SortColumns(list, fields)
+		if err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+
+		table := ""
+		if len(prefixes) == 1 {
+			table = prefixes[0]
+		}
 
-		for f, s := range values {
-			db.Order(f + " " + s)
+		columns := make([]clause.OrderByColumn, len(sorts))
+		for i, s := range sorts {
+			columns[i] = clause.OrderByColumn{
+				Column: clause.Column{Table: table, Name: s.Column},
+				Desc:   s.Desc,
+			}
 		}
 
-		return db
+		return db.Clauses(clause.OrderBy{Columns: columns})
 	}
 }
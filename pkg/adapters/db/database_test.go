@@ -1,12 +1,19 @@
 package db
 
 import (
+	"context"
 	"errors"
 	"os"
 	"path"
 	"testing"
 
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
@@ -59,7 +66,7 @@ func TestDsn(t *testing.T) {
 				Port:     3306,
 			},
 			wanted: result{
-				dsn: "root:root@tcp(localhost:3306)/test?parseTime=True",
+				dsn: "root:root@tcp(localhost:3306)/test?parseTime=true",
 				err: nil,
 			},
 		},
@@ -77,7 +84,22 @@ func TestDsn(t *testing.T) {
 				Location:  "Local",
 			},
 			wanted: result{
-				dsn: "root:root@tcp(localhost:3306)/test?parseTime=True&charset=utf8mb4&collation=utf8mb4_general_ci&loc=Local",
+				dsn: "root:root@tcp(localhost:3306)/test?collation=utf8mb4_general_ci&loc=Local&parseTime=true&charset=utf8mb4",
+				err: nil,
+			},
+		},
+		{
+			name: "MySQL DSN with special characters",
+			args: DatabaseConfig{
+				Driver:   "mysql",
+				Username: "root",
+				Password: "p@ss/w:rd",
+				Database: "test",
+				Host:     "localhost",
+				Port:     3306,
+			},
+			wanted: result{
+				dsn: "root:p@ss/w:rd@tcp(localhost:3306)/test?parseTime=true",
 				err: nil,
 			},
 		},
@@ -151,6 +173,129 @@ func TestDsn(t *testing.T) {
 				err: errors.New("error in database configuration"),
 			},
 		},
+		{
+			name: "MySQL by default",
+			args: DatabaseConfig{
+				Username: "root",
+				Password: "root",
+				Database: "test",
+				Host:     "localhost",
+				Port:     3306,
+			},
+			wanted: result{
+				dsn: "root:root@tcp(localhost:3306)/test?parseTime=true",
+				err: nil,
+			},
+		},
+		{
+			name: "Simple valid PostgreSQL DSN",
+			args: DatabaseConfig{
+				Driver:   "postgres",
+				Username: "postgres",
+				Database: "test",
+				Host:     "localhost",
+				Port:     5432,
+			},
+			wanted: result{
+				dsn: "host=localhost port=5432 user=postgres dbname=test sslmode=disable",
+				err: nil,
+			},
+		},
+		{
+			name: "Complet valid PostgreSQL DSN",
+			args: DatabaseConfig{
+				Driver:    "postgres",
+				Username:  "postgres",
+				Password:  "secret",
+				Database:  "test",
+				Host:      "localhost",
+				Port:      5432,
+				Charset:   "utf8mb4",
+				Collation: "utf8mb4_general_ci",
+				Location:  "UTC",
+				SSLMode:   "require",
+			},
+			wanted: result{
+				dsn: "host=localhost port=5432 user=postgres dbname=test sslmode=require password=secret TimeZone=UTC",
+				err: nil,
+			},
+		},
+		{
+			name: "PostgreSQL DSN with special characters",
+			args: DatabaseConfig{
+				Driver:   "postgres",
+				Username: "postgres",
+				Password: `my p'a\ss`,
+				Database: "test",
+				Host:     "localhost",
+				Port:     5432,
+			},
+			wanted: result{
+				dsn: `host=localhost port=5432 user=postgres dbname=test sslmode=disable password='my p\'a\\ss'`,
+				err: nil,
+			},
+		},
+		{
+			name: "Invalid PostgreSQL DSN (no database)",
+			args: DatabaseConfig{
+				Driver:   "postgres",
+				Username: "postgres",
+				Host:     "localhost",
+				Port:     5432,
+			},
+			wanted: result{
+				dsn: "",
+				err: errors.New("error in database configuration"),
+			},
+		},
+		{
+			name: "Valid SQLite DSN",
+			args: DatabaseConfig{
+				Driver:   "sqlite",
+				Database: "/tmp/fiber.db",
+				Host:     "localhost",
+			},
+			wanted: result{
+				dsn: "/tmp/fiber.db?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)",
+				err: nil,
+			},
+		},
+		{
+			name: "Valid SQLite in-memory DSN",
+			args: DatabaseConfig{
+				Driver:   "sqlite",
+				Database: ":memory:",
+			},
+			wanted: result{
+				dsn: ":memory:?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)",
+				err: nil,
+			},
+		},
+		{
+			name: "Invalid SQLite DSN (no database)",
+			args: DatabaseConfig{
+				Driver: "sqlite",
+			},
+			wanted: result{
+				dsn: "",
+				err: errors.New("error in database configuration"),
+			},
+		},
+		{
+			name: "Unsupported driver",
+			args: DatabaseConfig{
+				Driver:   "oracle",
+				Username: "root",
+				Password: "root",
+				Database: "test",
+				Host:     "localhost",
+				Port:     1521,
+			},
+			wanted: result{
+				dsn: "",
+				err: errors.New("unsupported database driver: oracle"),
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -158,14 +303,74 @@ func TestDsn(t *testing.T) {
 			dsn, err := tt.args.dsn()
 			got := result{dsn, err}
 
-			if got.err != nil {
-				assert.Equal(t, got.dsn, tt.wanted.dsn)
+			if got.err == nil {
+				assert.Equal(t, tt.wanted.dsn, got.dsn)
 			}
 			assert.Equal(t, got.err, tt.wanted.err)
 		})
 	}
 }
 
+func TestPostgresDSNParsing(t *testing.T) {
+	config := DatabaseConfig{
+		Driver:   "postgres",
+		Username: "postgres",
+		Password: `my p'a\ss`,
+		Database: "test",
+		Host:     "localhost",
+		Port:     5432,
+	}
+	dsn, err := config.dsn()
+	assert.Nil(t, err)
+
+	// The DSN is parsed by pgx, which is used by the GORM PostgreSQL driver
+	parsed, err := pgconn.ParseConfig(dsn)
+	assert.Nil(t, err)
+	assert.Equal(t, config.Password, parsed.Password)
+	assert.Equal(t, config.Username, parsed.User)
+	assert.Equal(t, config.Database, parsed.Database)
+}
+
+func TestMySQLDSNParsing(t *testing.T) {
+	config := DatabaseConfig{
+		Driver:   "mysql",
+		Username: "root",
+		Password: "p@ss/w:rd",
+		Database: "test",
+		Host:     "localhost",
+		Port:     3306,
+		Location: "Europe/Paris",
+	}
+	dsn, err := config.dsn()
+	assert.Nil(t, err)
+
+	// The DSN is parsed by the driver used by the GORM MySQL driver
+	parsed, err := mysqldriver.ParseDSN(dsn)
+	assert.Nil(t, err)
+	assert.Equal(t, config.Password, parsed.Passwd)
+	assert.Equal(t, config.Username, parsed.User)
+	assert.Equal(t, config.Database, parsed.DBName)
+	assert.Equal(t, "localhost:3306", parsed.Addr)
+	assert.Equal(t, config.Location, parsed.Loc.String())
+
+	config.Location = "Unknown/Location"
+	_, err = config.dsn()
+	assert.NotNil(t, err)
+}
+
+func TestNewSQLiteMemory(t *testing.T) {
+	database, err := New(&DatabaseConfig{Driver: DriverSQLite, Database: SQLiteMemory})
+	assert.Nil(t, err)
+	assert.Nil(t, database.AutoMigrate(&entities.Role{}))
+
+	// The same connection is used, so tables are kept
+	assert.Nil(t, database.Create(&entities.Role{Name: "admin"}).Error)
+
+	var count int64
+	database.Model(&entities.Role{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
 func TestPaginateValues(t *testing.T) {
 	type args struct {
 		page  string
@@ -206,7 +411,7 @@ func TestPaginateValues(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			offset, limit := paginateValues(tt.args.page, tt.args.limit)
+			offset, limit := PaginateValues(tt.args.page, tt.args.limit)
 			got := result{offset: offset, limit: limit}
 			assert.Equal(t, got, tt.wanted)
 		})
@@ -214,61 +419,140 @@ func TestPaginateValues(t *testing.T) {
 }
 
 func TestOrderValues(t *testing.T) {
-	type args struct {
-		list     string
-		prefixes []string
+	fields := SortableFields{
+		"id":         "id",
+		"name":       "name",
+		"created_at": "created_at",
+		"firstName":  "first_name",
+	}
+
+	type result struct {
+		sorts []Sort
+		err   error
+	}
+
+	unknownField := func(name string) utils.ValidatorErrors {
+		return utils.ValidatorErrors{{FailedField: "s", Tag: "sort", Value: name, Allowed: []string{"created_at", "firstName", "id", "name"}}}
 	}
 
 	tests := []struct {
 		name   string
-		args   args
-		wanted map[string]string
+		list   string
+		wanted result
 	}{
 		{
-			name: "One field with no prefix",
-			args: args{"+created_at", []string{}},
-			wanted: map[string]string{
-				"created_at": "ASC",
-			},
+			name:   "One field",
+			list:   "+created_at",
+			wanted: result{sorts: []Sort{{Column: "created_at"}}},
 		},
 		{
-			name: "One field with prefix",
-			args: args{"+created_at", []string{"table"}},
-			wanted: map[string]string{
-				"table.created_at": "ASC",
-			},
+			name:   "Field mapped to another column",
+			list:   "-firstName",
+			wanted: result{sorts: []Sort{{Column: "first_name", Desc: true}}},
 		},
 		{
-			name: "Many fields with no prefix",
-			args: args{"+created_at,-id", []string{}},
-			wanted: map[string]string{
-				"created_at": "ASC",
-				"id":         "DESC",
-			},
+			name: "Many fields keep their order",
+			list: "-name,+created_at,-id",
+			wanted: result{sorts: []Sort{
+				{Column: "name", Desc: true},
+				{Column: "created_at"},
+				{Column: "id", Desc: true},
+			}},
 		},
 		{
-			name: "Many fields with prefix",
-			args: args{"+created_at,-id", []string{"table"}},
-			wanted: map[string]string{
-				"table.created_at": "ASC",
-				"table.id":         "DESC",
-			},
+			name:   "Field without prefix or with a space (decoded +)",
+			list:   "name, created_at",
+			wanted: result{sorts: []Sort{{Column: "name"}, {Column: "created_at"}}},
 		},
 		{
 			name:   "No fields",
-			args:   args{"", []string{"toto"}},
-			wanted: map[string]string{},
+			list:   "",
+			wanted: result{sorts: []Sort{}},
 		},
 		{
-			name:   "With invalid field",
-			args:   args{"created_at", []string{}},
-			wanted: map[string]string{},
+			name:   "Empty fields",
+			list:   "+name,,-id,",
+			wanted: result{sorts: []Sort{{Column: "name"}, {Column: "id", Desc: true}}},
 		},
+		{
+			name:   "Only a prefix",
+			list:   "+",
+			wanted: result{err: unknownField("")},
+		},
+		{
+			name:   "Unknown field",
+			list:   "+name,-password",
+			wanted: result{err: unknownField("password")},
+		},
+		{
+			name:   "SQL injection",
+			list:   "+name;DROP TABLE users",
+			wanted: result{err: unknownField("name;DROP TABLE users")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorts, err := orderValues(tt.list, fields)
+			assert.Equal(t, tt.wanted, result{sorts, err})
+		})
+	}
+}
+
+func TestOrder(t *testing.T) {
+	gormDB, err := gorm.Open(mysql.New(mysql.Config{SkipInitializeWithVersion: true}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	assert.Nil(t, err)
+
+	type task struct {
+		ID   string
+		Name string
+	}
+	fields := SortableFields{"id": "id", "name": "name"}
+
+	tests := []struct {
+		name   string
+		list   string
+		prefix []string
+		wanted string
+	}{
+		{"ID tiebreaker", "-name", nil, "SELECT * FROM `tasks` ORDER BY `name` DESC,`id`"},
+		{"No tiebreaker if ID is present", "-id,+name", nil, "SELECT * FROM `tasks` ORDER BY `id` DESC,`name`"},
+		{"No fields", "", nil, "SELECT * FROM `tasks` ORDER BY `id`"},
+		{"With prefix", "+name", []string{"tasks"}, "SELECT * FROM `tasks` ORDER BY `tasks`.`name`,`tasks`.`id`"},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			assert.Equal(t, orderValues(tt.args.list, tt.args.prefixes...), tt.wanted)
+			stmt := gormDB.Scopes(Order(tt.list, fields, tt.prefix...)).Find(&[]task{}).Statement
+			assert.Equal(t, tt.wanted, stmt.SQL.String())
 		})
 	}
+
+	result := gormDB.Scopes(Order("+password", fields)).Find(&[]task{})
+	assert.IsType(t, utils.ValidatorErrors{}, result.Error)
+}
+
+func TestWithinTx(t *testing.T) {
+	database, err := New(&DatabaseConfig{Driver: DriverSQLite, Database: SQLiteMemory})
+	assert.Nil(t, err)
+	assert.Nil(t, database.AutoMigrate(&entities.Role{}))
+
+	// Rollback
+	err = database.WithinTx(context.Background(), func(tx *DB) error {
+		if err := tx.Create(&entities.Role{Name: "role1"}).Error; err != nil {
+			return err
+		}
+		return errors.New("failure")
+	})
+	assert.EqualError(t, err, "failure")
+
+	// Commit
+	err = database.WithinTx(context.Background(), func(tx *DB) error {
+		return tx.Create(&entities.Role{Name: "role2"}).Error
+	})
+	assert.Nil(t, err)
+
+	var names []string
+	database.Model(&entities.Role{}).Pluck("name", &names)
+	assert.Equal(t, []string{"role2"}, names)
 }
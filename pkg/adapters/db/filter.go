@@ -0,0 +1,172 @@
+package db
+
+import (
+	"strings"
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FieldType is the type of a filterable field.
+type FieldType int
+
+// Filterable field types
+const (
+	FieldString FieldType = iota
+	FieldTime
+)
+
+// fieldTypeOperators lists the operators allowed for each field type.
+var fieldTypeOperators = map[FieldType][]requests.FilterOperator{
+	FieldString: {
+		requests.FilterEq,
+		requests.FilterNe,
+		requests.FilterContains,
+		requests.FilterStartsWith,
+		requests.FilterIn,
+	},
+	FieldTime: {
+		requests.FilterEq,
+		requests.FilterNe,
+		requests.FilterGt,
+		requests.FilterGte,
+		requests.FilterLt,
+		requests.FilterLte,
+	},
+}
+
+// timeLayouts lists the accepted formats of time values.
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+
+// likeEscaper escapes the LIKE special characters with "!".
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// FilterableField is a field which can be used in filters.
+type FilterableField struct {
+	Column string
+	Type   FieldType
+}
+
+// FilterableFields maps the names of the fields which can be filtered in the API to their database columns.
+type FilterableFields map[string]FilterableField
+
+// filterExpressions translates filters into SQL expressions.
+// If a field is not filterable, an operator is not allowed for a field or a value is invalid,
+// a utils.ValidatorErrors is returned.
+func filterExpressions(filters requests.Filters, fields FilterableFields) ([]clause.Expression, error) {
+	exprs := make([]clause.Expression, 0, len(filters))
+	var errs utils.ValidatorErrors
+
+	for _, f := range filters {
+		field, values, errFilter := fields.Values(f)
+		if errFilter != nil {
+			errs = append(errs, *errFilter)
+			continue
+		}
+		if len(values) == 0 || len(errs) > 0 {
+			continue
+		}
+
+		exprs = append(exprs, expression(clause.Column{Name: field.Column}, f.Operator, values))
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return exprs, nil
+}
+
+// Values checks that the filter can be applied and returns the filtered field
+// and the filter values converted into the field type (string or time.Time).
+func (fields FilterableFields) Values(f requests.Filter) (FilterableField, []interface{}, *utils.ValidatorError) {
+	name := "filter[" + f.Field + "][" + string(f.Operator) + "]"
+
+	field, ok := fields[f.Field]
+	if !ok {
+		return field, nil, &utils.ValidatorError{FailedField: name, Tag: "field"}
+	}
+	if !allowedOperator(field.Type, f.Operator) {
+		return field, nil, &utils.ValidatorError{FailedField: name, Tag: "operator", Value: string(f.Operator)}
+	}
+
+	values := make([]interface{}, len(f.Values))
+	for i, v := range f.Values {
+		value, err := field.value(v)
+		if err != nil {
+			return field, nil, &utils.ValidatorError{FailedField: name, Tag: "value", Value: v}
+		}
+		values[i] = value
+	}
+	return field, values, nil
+}
+
+// allowedOperator returns true if the operator can be used on the field type.
+func allowedOperator(t FieldType, operator requests.FilterOperator) bool {
+	for _, o := range fieldTypeOperators[t] {
+		if o == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// value converts a query parameter into a value of the field type.
+func (f FilterableField) value(v string) (interface{}, error) {
+	if f.Type != FieldTime {
+		return v, nil
+	}
+
+	var err error
+	for _, layout := range timeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, v); err == nil {
+			return t, nil
+		}
+	}
+	return nil, err
+}
+
+// expression returns the SQL expression of an operator.
+func expression(column clause.Column, operator requests.FilterOperator, values []interface{}) clause.Expression {
+	switch operator {
+	case requests.FilterNe:
+		return clause.Neq{Column: column, Value: values[0]}
+	case requests.FilterGt:
+		return clause.Gt{Column: column, Value: values[0]}
+	case requests.FilterGte:
+		return clause.Gte{Column: column, Value: values[0]}
+	case requests.FilterLt:
+		return clause.Lt{Column: column, Value: values[0]}
+	case requests.FilterLte:
+		return clause.Lte{Column: column, Value: values[0]}
+	case requests.FilterContains:
+		return clause.Expr{SQL: "? LIKE ? ESCAPE '!'", Vars: []interface{}{column, "%" + likeEscaper.Replace(values[0].(string)) + "%"}}
+	case requests.FilterStartsWith:
+		return clause.Expr{SQL: "? LIKE ? ESCAPE '!'", Vars: []interface{}{column, likeEscaper.Replace(values[0].(string)) + "%"}}
+	case requests.FilterIn:
+		return clause.IN{Column: column, Values: values}
+	default:
+		return clause.Eq{Column: column, Value: values[0]}
+	}
+}
+
+// Filter creates a GORM scope to filter query with conditions on filterable fields.
+// Example: filter[name][contains]=foo will produce "WHERE name LIKE '%foo%'".
+// The query fails with a utils.ValidatorErrors if a filter is invalid.
+func Filter(filters requests.Filters, fields FilterableFields) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		exprs, err := filterExpressions(filters, fields)
+		if err != nil {
+			_ = db.AddError(err)
+			return db
+		}
+		if len(exprs) == 0 {
+			return db
+		}
+
+		return db.Where(clause.And(exprs...))
+	}
+}
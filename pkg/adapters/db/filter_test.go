@@ -0,0 +1,85 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestFilter(t *testing.T) {
+	gormDB, err := gorm.Open(mysql.New(mysql.Config{SkipInitializeWithVersion: true}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	assert.Nil(t, err)
+
+	type task struct {
+		ID        string
+		Name      string
+		CreatedAt time.Time
+	}
+	fields := FilterableFields{
+		"name":       {Column: "name", Type: FieldString},
+		"created_at": {Column: "created_at", Type: FieldTime},
+	}
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		filters requests.Filters
+		sql     string
+		vars    []interface{}
+	}{
+		{
+			name:    "No filters",
+			filters: requests.Filters{},
+			sql:     "SELECT * FROM `tasks`",
+			vars:    []interface{}{},
+		},
+		{
+			name: "Contains and greater than or equal",
+			filters: requests.Filters{
+				{Field: "created_at", Operator: requests.FilterGte, Values: []string{"2024-01-01"}},
+				{Field: "name", Operator: requests.FilterContains, Values: []string{"50%_off!"}},
+			},
+			sql:  "SELECT * FROM `tasks` WHERE `created_at` >= ? AND `name` LIKE ? ESCAPE '!'",
+			vars: []interface{}{createdAt, "%50!%!_off!!%"},
+		},
+		{
+			name: "In",
+			filters: requests.Filters{
+				{Field: "name", Operator: requests.FilterIn, Values: []string{"a", "b"}},
+			},
+			sql:  "SELECT * FROM `tasks` WHERE `name` IN (?,?)",
+			vars: []interface{}{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt := gormDB.Scopes(Filter(tt.filters, fields)).Find(&[]task{}).Statement
+			assert.Equal(t, tt.sql, stmt.SQL.String())
+			assert.Equal(t, tt.vars, stmt.Vars)
+		})
+	}
+}
+
+func TestFilterExpressionsErrors(t *testing.T) {
+	fields := FilterableFields{
+		"name":       {Column: "name", Type: FieldString},
+		"created_at": {Column: "created_at", Type: FieldTime},
+	}
+
+	_, err := filterExpressions(requests.Filters{
+		{Field: "password", Operator: requests.FilterEq, Values: []string{"secret"}},
+		{Field: "name", Operator: requests.FilterGt, Values: []string{"a"}},
+		{Field: "created_at", Operator: requests.FilterLt, Values: []string{"yesterday"}},
+	}, fields)
+	assert.Equal(t, utils.ValidatorErrors{
+		{FailedField: "filter[password][eq]", Tag: "field"},
+		{FailedField: "filter[name][gt]", Tag: "operator", Value: "gt"},
+		{FailedField: "filter[created_at][lt]", Tag: "value", Value: "yesterday"},
+	}, err)
+}
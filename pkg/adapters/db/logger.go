@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	gormutils "gorm.io/gorm/utils"
+)
+
+// GormLogger is a GORM logger backed by a zap logger.
+// Each SQL query is logged as a JSON entry with the query, the number of rows,
+// the duration, a slow flag and the request ID carried by the context.
+type GormLogger struct {
+	logger                    *zap.Logger
+	level                     logger.LogLevel
+	slowThreshold             time.Duration
+	ignoreRecordNotFoundError bool
+}
+
+// NewGormLogger creates a new GORM logger.
+func NewGormLogger(l *zap.Logger, level logger.LogLevel, slowThreshold time.Duration) *GormLogger {
+	return &GormLogger{
+		logger:                    l.WithOptions(zap.WithCaller(false)),
+		level:                     level,
+		slowThreshold:             slowThreshold,
+		ignoreRecordNotFoundError: true,
+	}
+}
+
+// LogMode returns a copy of the logger with the given log level.
+func (l *GormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	nl := *l
+	nl.level = level
+	return &nl
+}
+
+// Info logs an info message.
+func (l *GormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Info {
+		l.logger.Info(fmt.Sprintf(msg, data...), l.fields(ctx)...)
+	}
+}
+
+// Warn logs a warning message.
+func (l *GormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Warn {
+		l.logger.Warn(fmt.Sprintf(msg, data...), l.fields(ctx)...)
+	}
+}
+
+// Error logs an error message.
+func (l *GormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Error {
+		l.logger.Error(fmt.Sprintf(msg, data...), l.fields(ctx)...)
+	}
+}
+
+// Trace logs an SQL query:
+//   - as an error if the query failed (level error or more),
+//   - as a warning if the query is slow (level warn or more),
+//   - as an info otherwise (level info).
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	slow := l.slowThreshold != 0 && elapsed > l.slowThreshold
+	isError := err != nil && (!errors.Is(err, gorm.ErrRecordNotFound) || !l.ignoreRecordNotFoundError)
+
+	switch {
+	case isError && l.level >= logger.Error:
+		sql, rows := fc()
+		l.logger.Error("SQL error", append(l.traceFields(ctx, sql, rows, elapsed, slow), zap.Error(err))...)
+	case slow && l.level >= logger.Warn:
+		sql, rows := fc()
+		l.logger.Warn(fmt.Sprintf("Slow SQL >= %v", l.slowThreshold), l.traceFields(ctx, sql, rows, elapsed, slow)...)
+	case l.level >= logger.Info:
+		sql, rows := fc()
+		l.logger.Info("SQL", l.traceFields(ctx, sql, rows, elapsed, slow)...)
+	}
+}
+
+// fields returns the common fields of a log entry.
+func (l *GormLogger) fields(ctx context.Context) []zap.Field {
+	return []zap.Field{
+		zap.String("caller", gormutils.FileWithLineNum()),
+		zap.String("requestId", utils.RequestIDFromContext(ctx)),
+	}
+}
+
+// traceFields returns the fields of an SQL query log entry.
+// rows is -1 when the number of rows is unknown.
+func (l *GormLogger) traceFields(ctx context.Context, sql string, rows int64, elapsed time.Duration, slow bool) []zap.Field {
+	return append(l.fields(ctx),
+		zap.String("sql", sql),
+		zap.Int64("rows", rows),
+		zap.String("duration", elapsed.String()),
+		zap.Bool("slow", slow),
+	)
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestGormLoggerTrace(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := NewGormLogger(zap.New(core), logger.Info, 100*time.Millisecond)
+	ctx := utils.ContextWithRequestID(context.Background(), "request-id")
+	fc := func() (string, int64) { return "SELECT * FROM users", 2 }
+
+	l.Trace(ctx, time.Now(), fc, nil)
+	l.Trace(ctx, time.Now().Add(-time.Second), fc, nil)
+	l.Trace(ctx, time.Now(), fc, errors.New("db error"))
+	l.Trace(ctx, time.Now(), fc, gorm.ErrRecordNotFound)
+
+	entries := logs.AllUntimed()
+	assert.Len(t, entries, 4)
+
+	assert.Equal(t, zapcore.InfoLevel, entries[0].Level)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "SELECT * FROM users", fields["sql"])
+	assert.Equal(t, int64(2), fields["rows"])
+	assert.Equal(t, false, fields["slow"])
+	assert.Equal(t, "request-id", fields["requestId"])
+
+	assert.Equal(t, zapcore.WarnLevel, entries[1].Level)
+	assert.Equal(t, true, entries[1].ContextMap()["slow"])
+
+	assert.Equal(t, zapcore.ErrorLevel, entries[2].Level)
+	assert.Equal(t, "db error", entries[2].ContextMap()["error"])
+
+	// Record not found errors are not considered as errors
+	assert.Equal(t, zapcore.InfoLevel, entries[3].Level)
+}
+
+func TestGormLoggerLevel(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	l := NewGormLogger(zap.New(core), logger.Warn, 100*time.Millisecond)
+	fc := func() (string, int64) { return "SELECT 1", -1 }
+
+	l.Trace(context.Background(), time.Now(), fc, nil)
+	l.Info(context.Background(), "info %s", "message")
+	assert.Equal(t, 0, logs.Len())
+
+	l.Trace(context.Background(), time.Now(), fc, errors.New("db error"))
+	l.Warn(context.Background(), "warn %s", "message")
+	assert.Equal(t, 2, logs.Len())
+	assert.Equal(t, "warn message", logs.All()[1].Message)
+	assert.Equal(t, "", logs.All()[1].ContextMap()["requestId"])
+
+	l.LogMode(logger.Silent).Trace(context.Background(), time.Now(), fc, errors.New("db error"))
+	assert.Equal(t, 2, logs.Len())
+}
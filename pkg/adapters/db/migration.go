@@ -1,29 +1,220 @@
 package db
 
 import (
-	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"gorm.io/gorm"
 )
 
-// entitiesList lists all entities to auto migrate.
-var entitiesList = []interface{}{
-	&entities.User{},
-	&entities.PasswordResets{},
-	&entities.Task{},
+// MigrationVersionLayout is the layout of migrations versions.
+const MigrationVersionLayout = "20060102150405"
+
+// migrationNameRegexp matches valid migrations names.
+var migrationNameRegexp = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Migration is a versioned and reversible database schema change.
+type Migration struct {
+	Version string // Creation time in the MigrationVersionLayout format
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration represents an applied migration in database.
+type SchemaMigration struct {
+	Version   string    `gorm:"primaryKey;size:14"`
+	Name      string    `gorm:"size:255;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName returns the migrations history table name.
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationStatus represents a migration and its application time if it has been applied.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies and reverts migrations and keeps track of them in the schema_migrations table.
+type Migrator struct {
+	db         *DB
+	migrations []Migration
+}
+
+// NewMigrator returns a new Migrator for the list of migrations.
+func NewMigrator(db *DB, migrations []Migration) *Migrator {
+	list := make([]Migration, len(migrations))
+	copy(list, migrations)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+
+	return &Migrator{db: db, migrations: list}
 }
 
-var migrations = []func(db *DB) error{
-	changeDescriptionTaskColumn,
+// applied returns the applied migrations indexed by version.
+func (m *Migrator) applied() (map[string]SchemaMigration, error) {
+	if err := m.db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, err
+	}
+
+	var rows []SchemaMigration
+	if err := m.db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[string]SchemaMigration, len(rows))
+	for _, r := range rows {
+		applied[r.Version] = r
+	}
+	return applied, nil
 }
 
-// changeDescriptionTaskColumn, adds the state column to the tasks table.
-func changeDescriptionTaskColumn(db *DB) error {
-	if !db.Migrator().HasColumn(&entities.Task{}, "state") {
-		tx := db.Exec(`
-			ALTER TABLE tasks MODIFY COLUMN description VARCHAR(255);
-		`)
+// Status returns all the migrations with their application time.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		status[i] = MigrationStatus{Migration: migration}
+		if a, ok := applied[migration.Version]; ok {
+			appliedAt := a.AppliedAt
+			status[i].AppliedAt = &appliedAt
+		}
+	}
+	return status, nil
+}
+
+// Pending returns the migrations not applied yet.
+func (m *Migrator) Pending() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]Migration, 0)
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies all the pending migrations by version order and returns them.
+// Each migration is applied in its own transaction.
+func (m *Migrator) Up() ([]Migration, error) {
+	pending, err := m.Pending()
+	if err != nil {
+		return nil, err
+	}
+
+	done := make([]Migration, 0, len(pending))
+	for _, migration := range pending {
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now().UTC(),
+			}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %s_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Down reverts the n last applied migrations and returns them.
+func (m *Migrator) Down(n int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	done := make([]Migration, 0, n)
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < n; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, "version = ?", migration.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %s_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// migrationTemplate is the template of a new migration file.
+var migrationTemplate = template.Must(template.New("migration").Parse(`package migrations
+
+import (
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
+	"gorm.io/gorm"
+)
+
+func init() {
+	register(db.Migration{
+		Version: "{{ .Version }}",
+		Name:    "{{ .Name }}",
+		Up: func(tx *gorm.DB) error {
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	})
+}
+`))
+
+// CreateMigrationFile creates a new empty migration file in the directory and returns its path.
+// The name is converted to snake case (Ex.: "Add tasks priority" gives "add_tasks_priority").
+func CreateMigrationFile(dir, name string, t time.Time) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+	if !migrationNameRegexp.MatchString(name) {
+		return "", errors.New("invalid migration name, only letters, digits, spaces, - and _ are allowed")
+	}
+
+	migration := Migration{
+		Version: t.UTC().Format(MigrationVersionLayout),
+		Name:    name,
+	}
+	path := filepath.Join(dir, migration.Version+"_"+migration.Name+".go")
 
-		return tx.Error
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
 	}
+	defer f.Close()
 
-	return nil
+	return path, migrationTemplate.Execute(f, migration)
 }
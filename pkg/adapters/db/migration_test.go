@@ -0,0 +1,140 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type migrationTestItem struct {
+	ID   uint
+	Name string
+}
+
+func testMigrations() []Migration {
+	return []Migration{
+		{
+			Version: "20240102000000",
+			Name:    "add_name_column",
+			Up: func(tx *gorm.DB) error {
+				return tx.Migrator().AddColumn(&migrationTestItem{}, "Name")
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropColumn(&migrationTestItem{}, "Name")
+			},
+		},
+		{
+			Version: "20240101000000",
+			Name:    "create_items",
+			Up: func(tx *gorm.DB) error {
+				return tx.Exec("CREATE TABLE migration_test_items (id INTEGER PRIMARY KEY)").Error
+			},
+			Down: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&migrationTestItem{})
+			},
+		},
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	database, err := New(&DatabaseConfig{Driver: DriverSQLite, Database: SQLiteMemory})
+	assert.Nil(t, err)
+
+	migrator := NewMigrator(database, testMigrations())
+
+	pending, err := migrator.Pending()
+	assert.Nil(t, err)
+	assert.Len(t, pending, 2)
+	assert.Equal(t, "20240101000000", pending[0].Version)
+
+	// Up
+	done, err := migrator.Up()
+	assert.Nil(t, err)
+	assert.Len(t, done, 2)
+	assert.True(t, database.Migrator().HasColumn(&migrationTestItem{}, "Name"))
+
+	done, err = migrator.Up()
+	assert.Nil(t, err)
+	assert.Empty(t, done)
+
+	status, err := migrator.Status()
+	assert.Nil(t, err)
+	assert.Len(t, status, 2)
+	assert.NotNil(t, status[0].AppliedAt)
+	assert.NotNil(t, status[1].AppliedAt)
+
+	// Down
+	done, err = migrator.Down(1)
+	assert.Nil(t, err)
+	assert.Len(t, done, 1)
+	assert.Equal(t, "add_name_column", done[0].Name)
+	assert.False(t, database.Migrator().HasColumn(&migrationTestItem{}, "Name"))
+
+	status, err = migrator.Status()
+	assert.Nil(t, err)
+	assert.NotNil(t, status[0].AppliedAt)
+	assert.Nil(t, status[1].AppliedAt)
+
+	done, err = migrator.Down(5)
+	assert.Nil(t, err)
+	assert.Len(t, done, 1)
+	assert.False(t, database.Migrator().HasTable(&migrationTestItem{}))
+
+	pending, err = migrator.Pending()
+	assert.Nil(t, err)
+	assert.Len(t, pending, 2)
+}
+
+func TestMigratorUpError(t *testing.T) {
+	database, err := New(&DatabaseConfig{Driver: DriverSQLite, Database: SQLiteMemory})
+	assert.Nil(t, err)
+
+	list := testMigrations()
+	list = append(list, Migration{
+		Version: "20240103000000",
+		Name:    "failing",
+		Up: func(tx *gorm.DB) error {
+			return errors.New("failure")
+		},
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	})
+	migrator := NewMigrator(database, list)
+
+	done, err := migrator.Up()
+	assert.EqualError(t, err, "migration 20240103000000_failing: failure")
+	assert.Len(t, done, 2)
+
+	pending, err := migrator.Pending()
+	assert.Nil(t, err)
+	assert.Len(t, pending, 1)
+}
+
+func TestCreateMigrationFile(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	path, err := CreateMigrationFile(dir, "Add tasks-priority", now)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "20240506070809_add_tasks_priority.go"), path)
+
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(content), `Version: "20240506070809"`))
+	assert.True(t, strings.Contains(string(content), `Name:    "add_tasks_priority"`))
+
+	// Existing file
+	_, err = CreateMigrationFile(dir, "add_tasks_priority", now)
+	assert.NotNil(t, err)
+
+	// Invalid name
+	_, err = CreateMigrationFile(dir, "add tasks.priority", now)
+	assert.NotNil(t, err)
+}
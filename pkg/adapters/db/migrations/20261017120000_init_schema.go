@@ -0,0 +1,118 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
+	"gorm.io/gorm"
+)
+
+// The schema is a snapshot of the entities at the time of the migration, so that later changes
+// of the entities do not change what the migration creates: they need their own migration.
+// Tables already created by the previous automatic migrations are updated.
+func init() {
+	type permission struct {
+		Name        string `gorm:"primaryKey;size:63"`
+		Description string `gorm:"size:255"`
+	}
+	type role struct {
+		Name        string       `gorm:"primaryKey;size:63"`
+		Description string       `gorm:"size:255"`
+		Permissions []permission `gorm:"many2many:role_permissions;constraint:OnDelete:CASCADE"`
+	}
+	type passwordReset struct {
+		UserID    string    `gorm:"primaryKey"`
+		Token     string    `gorm:"size:36;not null"`
+		ExpiredAt time.Time `gorm:"not null"`
+	}
+	type refreshToken struct {
+		ID        string    `gorm:"primaryKey;size:36"`
+		UserID    string    `gorm:"size:36;not null;index"`
+		FamilyID  string    `gorm:"size:36;not null;index"`
+		TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+		ExpiredAt time.Time `gorm:"not null"`
+		UsedAt    *time.Time
+		RevokedAt *time.Time
+		CreatedAt time.Time `gorm:"not null;autoCreateTime"`
+	}
+	type revokedToken struct {
+		ID        string    `gorm:"primaryKey;size:36"`
+		ExpiredAt time.Time `gorm:"not null;index"`
+	}
+	type taskTransition struct {
+		ID        string    `gorm:"primaryKey;size:36"`
+		TaskID    string    `gorm:"size:191;not null;index"`
+		FromState string    `gorm:"size:31;not null"`
+		ToState   string    `gorm:"size:31;not null"`
+		UserID    *string   `gorm:"index"`
+		CreatedAt time.Time `gorm:"not null;autoCreateTime"`
+	}
+	type task struct {
+		ID          string           `gorm:"primaryKey"`
+		Name        string           `gorm:"size:127"`
+		Description string           `gorm:"size:255"`
+		State       string           `gorm:"size:31;not null;default:todo;index"`
+		UserID      *string          `gorm:"index"`
+		CreatedAt   time.Time        `gorm:"not null;autoCreateTime"`
+		UpdatedAt   time.Time        `gorm:"not null;autoUpdateTime"`
+		DeletedAt   gorm.DeletedAt   `gorm:"index"`
+		Transitions []taskTransition `gorm:"constraint:OnDelete:CASCADE"`
+	}
+	type user struct {
+		ID            string         `gorm:"primaryKey"`
+		Username      string         `gorm:"not null;unique;size:127"`
+		Password      string         `gorm:"not null;size:255"`
+		Lastname      string         `gorm:"size:63"`
+		Firstname     string         `gorm:"size:63"`
+		TokenVersion  uint           `gorm:"not null;default:0"`
+		CreatedAt     time.Time      `gorm:"not null;autoCreateTime"`
+		UpdatedAt     time.Time      `gorm:"not null;autoUpdateTime"`
+		DeletedAt     gorm.DeletedAt `gorm:"index"`
+		Roles         []role         `gorm:"many2many:user_roles;constraint:OnDelete:CASCADE"`
+		PasswordReset passwordReset  `gorm:"constraint:OnDelete:CASCADE"`
+		RefreshTokens []refreshToken `gorm:"constraint:OnDelete:CASCADE"`
+		Tasks         []task         `gorm:"constraint:OnDelete:SET NULL"`
+	}
+
+	register(db.Migration{
+		Version: "20261017120000",
+		Name:    "init_schema",
+		Up: func(tx *gorm.DB) error {
+			if tx.Dialector.Name() == db.DriverMySQL {
+				tx = tx.Set("gorm:table_options", "ENGINE=InnoDB")
+			}
+			return tx.AutoMigrate(
+				&permission{},
+				&role{},
+				&user{},
+				&passwordReset{},
+				&refreshToken{},
+				&revokedToken{},
+				&task{},
+				&taskTransition{},
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			// Tables are dropped one by one, referencing tables first, because foreign keys
+			// cannot be disabled inside a transaction with SQLite.
+			tables := []string{
+				"user_roles",
+				"role_permissions",
+				"task_transitions",
+				"tasks",
+				"revoked_tokens",
+				"refresh_tokens",
+				"password_resets",
+				"users",
+				"roles",
+				"permissions",
+			}
+			for _, table := range tables {
+				if err := tx.Migrator().DropTable(table); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,83 @@
+package migrations
+
+import (
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// seedRolesPermissions is a snapshot of the default roles and their permissions at the time of the migration.
+// Later changes of entities.DefaultRolesPermissions need their own migration.
+var seedRolesPermissions = map[string][]string{
+	"admin": {
+		"users:read",
+		"users:create",
+		"users:update",
+		"users:delete",
+		"tasks:read",
+		"tasks:create",
+		"tasks:update",
+		"tasks:delete",
+		"tasks:all",
+	},
+	"user": {
+		"tasks:read",
+		"tasks:create",
+		"tasks:update",
+		"tasks:delete",
+	},
+}
+
+func init() {
+	type permission struct {
+		Name string `gorm:"primaryKey;size:63"`
+	}
+	type role struct {
+		Name        string       `gorm:"primaryKey;size:63"`
+		Permissions []permission `gorm:"many2many:role_permissions"`
+	}
+
+	register(db.Migration{
+		Version: "20261017120100",
+		Name:    "seed_roles_and_permissions",
+		Up: func(tx *gorm.DB) error {
+			for roleName, permissionNames := range seedRolesPermissions {
+				permissions := make([]permission, 0, len(permissionNames))
+				for _, name := range permissionNames {
+					permissions = append(permissions, permission{Name: name})
+				}
+				if result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&permissions); result.Error != nil {
+					return result.Error
+				}
+
+				r := role{Name: roleName}
+				if result := tx.Clauses(clause.OnConflict{DoNothing: true}).Omit("Permissions").Create(&r); result.Error != nil {
+					return result.Error
+				}
+				if err := tx.Model(&r).Association("Permissions").Append(permissions); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			roles := make([]string, 0, len(seedRolesPermissions))
+			permissions := make([]string, 0)
+			for roleName, permissionNames := range seedRolesPermissions {
+				roles = append(roles, roleName)
+				permissions = append(permissions, permissionNames...)
+			}
+
+			if err := tx.Table("user_roles").Where("role_name IN ?", roles).Delete(nil).Error; err != nil {
+				return err
+			}
+			if err := tx.Table("role_permissions").Where("role_name IN ?", roles).Delete(nil).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("name IN ?", roles).Delete(&role{}).Error; err != nil {
+				return err
+			}
+			return tx.Where("name IN ?", permissions).Delete(&permission{}).Error
+		},
+	})
+}
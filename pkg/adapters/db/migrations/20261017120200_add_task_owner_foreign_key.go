@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
+	"gorm.io/gorm"
+)
+
+// Tasks created before ownership are kept without owner.
+// SQLite cannot add a foreign key to an existing table, the key only exists if the table is created with it.
+// Reverting the migration keeps the owner column to not lose data.
+func init() {
+	type task struct {
+		ID     string  `gorm:"primaryKey"`
+		UserID *string `gorm:"index"`
+	}
+	type user struct {
+		ID    string `gorm:"primaryKey"`
+		Tasks []task `gorm:"constraint:OnDelete:SET NULL"`
+	}
+
+	register(db.Migration{
+		Version: "20261017120200",
+		Name:    "add_task_owner_foreign_key",
+		Up: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&task{}, "UserID") {
+				if err := tx.Migrator().AddColumn(&task{}, "UserID"); err != nil {
+					return err
+				}
+			}
+
+			if tx.Dialector.Name() != db.DriverSQLite && !tx.Migrator().HasConstraint(&user{}, "Tasks") {
+				return tx.Migrator().CreateConstraint(&user{}, "Tasks")
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			if tx.Dialector.Name() != db.DriverSQLite && tx.Migrator().HasConstraint(&user{}, "Tasks") {
+				return tx.Migrator().DropConstraint(&user{}, "Tasks")
+			}
+			return nil
+		},
+	})
+}
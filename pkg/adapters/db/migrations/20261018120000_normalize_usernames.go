@@ -0,0 +1,23 @@
+package migrations
+
+import (
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
+	"gorm.io/gorm"
+)
+
+// Usernames are emails normalized by the application (trimmed and lowercased),
+// existing usernames are normalized so that they are unique case-insensitively.
+// The migration fails if two users only differ by the case of their username: they must be merged manually.
+// Reverting the migration does not restore the original case.
+func init() {
+	register(db.Migration{
+		Version: "20261018120000",
+		Name:    "normalize_usernames",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec("UPDATE users SET username = LOWER(TRIM(username))").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	})
+}
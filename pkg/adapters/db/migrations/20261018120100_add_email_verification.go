@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
+	"gorm.io/gorm"
+)
+
+// Users created before email verification are considered verified, so that they can still log in
+// when EMAIL_VERIFICATION_REQUIRED is enabled.
+// SQLite cannot add a foreign key to an existing table, the key only exists if the table is created with it.
+func init() {
+	type emailVerification struct {
+		UserID    string    `gorm:"primaryKey"`
+		Email     string    `gorm:"size:127;not null"`
+		TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+		ExpiredAt time.Time `gorm:"not null"`
+	}
+	type user struct {
+		ID                string `gorm:"primaryKey"`
+		EmailVerifiedAt   *time.Time
+		EmailVerification emailVerification `gorm:"constraint:OnDelete:CASCADE"`
+	}
+
+	register(db.Migration{
+		Version: "20261018120100",
+		Name:    "add_email_verification",
+		Up: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&user{}, "EmailVerifiedAt") {
+				if err := tx.Migrator().AddColumn(&user{}, "EmailVerifiedAt"); err != nil {
+					return err
+				}
+			}
+			if err := tx.Exec("UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL").Error; err != nil {
+				return err
+			}
+
+			if tx.Dialector.Name() == db.DriverMySQL {
+				tx = tx.Set("gorm:table_options", "ENGINE=InnoDB")
+			}
+			if err := tx.AutoMigrate(&emailVerification{}); err != nil {
+				return err
+			}
+
+			if tx.Dialector.Name() != db.DriverSQLite && !tx.Migrator().HasConstraint(&user{}, "EmailVerification") {
+				return tx.Migrator().CreateConstraint(&user{}, "EmailVerification")
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&emailVerification{}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&user{}, "EmailVerifiedAt")
+		},
+	})
+}
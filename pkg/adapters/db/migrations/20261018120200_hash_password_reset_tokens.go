@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
+	"gorm.io/gorm"
+)
+
+// Password reset tokens are no longer stored in clear but hashed, and their uses are counted.
+// Pending requests cannot be converted and are deleted: the users have to ask for a new link.
+// The restored clear token column has an empty default, SQLite cannot add a NOT NULL column without default.
+func init() {
+	type passwordReset struct {
+		UserID    string    `gorm:"primaryKey"`
+		Token     string    `gorm:"size:36;not null;default:''"`
+		TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+		Attempts  uint      `gorm:"not null;default:0"`
+		ExpiredAt time.Time `gorm:"not null"`
+	}
+
+	register(db.Migration{
+		Version: "20261018120200",
+		Name:    "hash_password_reset_tokens",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Exec("DELETE FROM password_resets").Error; err != nil {
+				return err
+			}
+			if tx.Migrator().HasColumn(&passwordReset{}, "Token") {
+				if err := tx.Migrator().DropColumn(&passwordReset{}, "Token"); err != nil {
+					return err
+				}
+			}
+
+			for _, field := range []string{"TokenHash", "Attempts"} {
+				if err := tx.Migrator().AddColumn(&passwordReset{}, field); err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().CreateIndex(&passwordReset{}, "TokenHash")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Exec("DELETE FROM password_resets").Error; err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropIndex(&passwordReset{}, "TokenHash"); err != nil {
+				return err
+			}
+			for _, field := range []string{"TokenHash", "Attempts"} {
+				if err := tx.Migrator().DropColumn(&passwordReset{}, field); err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().AddColumn(&passwordReset{}, "Token")
+		},
+	})
+}
@@ -0,0 +1,18 @@
+// Package migrations lists the database migrations.
+// A new migration file can be created with the command: <binary> migrate create <name>
+package migrations
+
+import "github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
+
+// all lists the migrations registered by the migrations files.
+var all []db.Migration
+
+// register adds a migration to the list.
+func register(m db.Migration) {
+	all = append(all, m)
+}
+
+// All returns the list of the migrations.
+func All() []db.Migration {
+	return all
+}
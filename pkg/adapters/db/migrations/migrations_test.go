@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationsUpAndDown(t *testing.T) {
+	database, err := db.New(&db.DatabaseConfig{Driver: db.DriverSQLite, Database: db.SQLiteMemory})
+	assert.Nil(t, err)
+
+	migrator := db.NewMigrator(database, All())
+
+	done, err := migrator.Up()
+	assert.Nil(t, err)
+	assert.Len(t, done, len(All()))
+
+	// The default roles and permissions must be added by a migration when they change
+	var roles []entities.Role
+	assert.Nil(t, database.Preload("Permissions").Find(&roles).Error)
+	assert.Len(t, roles, len(entities.DefaultRolesPermissions))
+	for _, role := range roles {
+		permissions := make([]string, 0, len(role.Permissions))
+		for _, p := range role.Permissions {
+			permissions = append(permissions, p.Name)
+		}
+		assert.ElementsMatch(t, entities.DefaultRolesPermissions[role.Name], permissions, role.Name)
+	}
+
+	// All migrations can be reverted
+	done, err = migrator.Down(len(All()))
+	assert.Nil(t, err)
+	assert.Len(t, done, len(All()))
+	assert.False(t, database.Migrator().HasTable(&entities.Task{}))
+
+	// And applied again
+	done, err = migrator.Up()
+	assert.Nil(t, err)
+	assert.Len(t, done, len(All()))
+}
+
+func TestHashPasswordResetTokensMigration(t *testing.T) {
+	database, err := db.New(&db.DatabaseConfig{Driver: db.DriverSQLite, Database: db.SQLiteMemory})
+	assert.Nil(t, err)
+
+	migrator := db.NewMigrator(database, All())
+	_, err = migrator.Up()
+	assert.Nil(t, err)
+	assert.True(t, database.Migrator().HasColumn("password_resets", "token_hash"))
+	assert.True(t, database.Migrator().HasIndex("password_resets", "idx_password_resets_token_hash"))
+	assert.False(t, database.Migrator().HasColumn("password_resets", "token"))
+
+	// Reverting the migration and the following ones restores the clear token column
+	n := 0
+	for _, m := range All() {
+		if m.Version >= "20261018120200" {
+			n++
+		}
+	}
+	_, err = migrator.Down(n)
+	assert.Nil(t, err)
+	assert.True(t, database.Migrator().HasColumn("password_resets", "token"))
+	assert.False(t, database.Migrator().HasColumn("password_resets", "token_hash"))
+	assert.False(t, database.Migrator().HasColumn("password_resets", "attempts"))
+}
@@ -0,0 +1,229 @@
+// Package memory provides thread-safe in-memory implementations of the repositories.
+// They are intended for unit tests and follow the pagination, sort and filter semantics of the database stores.
+package memory
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/responses"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+)
+
+// fieldValue returns the value of a field of an item, a string or a time.Time.
+type fieldValue[T any] func(item T) interface{}
+
+// fields maps the names of the fields which can be sorted or filtered to their values.
+type fields[T any] map[string]fieldValue[T]
+
+// filterableField is a field which can be used in filters.
+type filterableField[T any] struct {
+	Type  db.FieldType
+	Value fieldValue[T]
+}
+
+// filterableFields maps the names of the fields which can be filtered to their values.
+type filterableFields[T any] map[string]filterableField[T]
+
+// cursor is the position of a page in a sorted list.
+// Unlike database cursors, it only holds an offset, which is enough for an in-memory list.
+type cursor struct {
+	Sorts  string `json:"s"`
+	Offset int    `json:"o"`
+}
+
+// encode returns the cursor as a base64url string.
+func (c cursor) encode() (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor decodes a cursor returned by cursor.encode.
+func decodeCursor(s string) (c cursor, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(data, &c)
+	return c, err
+}
+
+// invalidCursor returns the error returned when a cursor cannot be used.
+func invalidCursor() error {
+	return utils.ValidatorErrors{{FailedField: "cursor", Tag: "cursor"}}
+}
+
+// findPage returns a page of the items matching the filters, sorted by the sortable fields.
+// Invalid sorts, filters or cursors return a utils.ValidatorErrors, like db.FindPage.
+func findPage[T any](items []T, pagination requests.Pagination, sortable fields[T], filterable filterableFields[T]) ([]T, responses.Pagination, error) {
+	var page responses.Pagination
+
+	// Sorts
+	// -----
+	sortableColumns := make(db.SortableFields, len(sortable))
+	for name := range sortable {
+		sortableColumns[name] = name
+	}
+	sorts, err := db.SortColumns(pagination.Sorts, sortableColumns)
+	if err != nil {
+		return nil, page, err
+	}
+	signature := db.SortsSignature(sorts)
+
+	// Filters
+	// -------
+	items, err = filter(items, pagination.Filters, filterable)
+	if err != nil {
+		return nil, page, err
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		for _, s := range sorts {
+			c := compare(sortable[s.Column](items[i]), sortable[s.Column](items[j]))
+			if c == 0 {
+				continue
+			}
+			return (c < 0) != s.Desc
+		}
+		return false
+	})
+
+	if pagination.Total {
+		total := int64(len(items))
+		page.Total = &total
+	}
+
+	// Page
+	// ----
+	offset, limit := db.PaginateValues(pagination.Page, pagination.Limit)
+	if pagination.Cursor != "" {
+		c, err := decodeCursor(pagination.Cursor)
+		if err != nil || c.Sorts != signature || c.Offset < 0 {
+			return nil, page, invalidCursor()
+		}
+		offset = c.Offset
+	}
+
+	if offset >= len(items) {
+		return []T{}, page, nil
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+
+	// Cursors
+	// -------
+	if end < len(items) {
+		if page.NextCursor, err = (cursor{Sorts: signature, Offset: end}).encode(); err != nil {
+			return nil, page, err
+		}
+	}
+	if offset > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		if page.PrevCursor, err = (cursor{Sorts: signature, Offset: prev}).encode(); err != nil {
+			return nil, page, err
+		}
+	}
+
+	return items[offset:end], page, nil
+}
+
+// filter returns the items matching all the filters.
+func filter[T any](items []T, filters requests.Filters, filterable filterableFields[T]) ([]T, error) {
+	columns := make(db.FilterableFields, len(filterable))
+	for name, f := range filterable {
+		columns[name] = db.FilterableField{Column: name, Type: f.Type}
+	}
+
+	type condition struct {
+		value    fieldValue[T]
+		operator requests.FilterOperator
+		values   []interface{}
+	}
+	conditions := make([]condition, 0, len(filters))
+	var errs utils.ValidatorErrors
+	for _, f := range filters {
+		_, values, errFilter := columns.Values(f)
+		if errFilter != nil {
+			errs = append(errs, *errFilter)
+			continue
+		}
+		conditions = append(conditions, condition{filterable[f.Field].Value, f.Operator, values})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	result := make([]T, 0, len(items))
+	for _, item := range items {
+		ok := true
+		for _, c := range conditions {
+			if !match(c.value(item), c.operator, c.values) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// match returns true if the value satisfies the filter operator.
+// As with LIKE in SQLite, contains and starts_with are case-insensitive.
+func match(value interface{}, operator requests.FilterOperator, values []interface{}) bool {
+	if len(values) == 0 {
+		return true
+	}
+
+	switch operator {
+	case requests.FilterNe:
+		return compare(value, values[0]) != 0
+	case requests.FilterGt:
+		return compare(value, values[0]) > 0
+	case requests.FilterGte:
+		return compare(value, values[0]) >= 0
+	case requests.FilterLt:
+		return compare(value, values[0]) < 0
+	case requests.FilterLte:
+		return compare(value, values[0]) <= 0
+	case requests.FilterContains:
+		return strings.Contains(strings.ToLower(value.(string)), strings.ToLower(values[0].(string)))
+	case requests.FilterStartsWith:
+		return strings.HasPrefix(strings.ToLower(value.(string)), strings.ToLower(values[0].(string)))
+	case requests.FilterIn:
+		for _, v := range values {
+			if compare(value, v) == 0 {
+				return true
+			}
+		}
+		return false
+	default:
+		return compare(value, values[0]) == 0
+	}
+}
+
+// compare compares two values of the same type, string or time.Time.
+func compare(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	default:
+		return 0
+	}
+}
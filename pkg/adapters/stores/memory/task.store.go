@@ -0,0 +1,224 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/responses"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrRowsNotSupported is returned by the methods using *sql.Rows, which cannot be built without a database.
+var ErrRowsNotSupported = errors.New("rows are not supported by in-memory stores")
+
+// taskSortableFields lists the fields which can be used to sort tasks.
+var taskSortableFields = fields[entities.Task]{
+	"id":         func(t entities.Task) interface{} { return t.ID },
+	"name":       func(t entities.Task) interface{} { return t.Name },
+	"state":      func(t entities.Task) interface{} { return t.State },
+	"created_at": func(t entities.Task) interface{} { return t.CreatedAt },
+	"updated_at": func(t entities.Task) interface{} { return t.UpdatedAt },
+}
+
+// taskFilterableFields lists the fields which can be used to filter tasks.
+var taskFilterableFields = filterableFields[entities.Task]{
+	"name":        {Type: db.FieldString, Value: taskSortableFields["name"]},
+	"description": {Type: db.FieldString, Value: func(t entities.Task) interface{} { return t.Description }},
+	"state":       {Type: db.FieldString, Value: taskSortableFields["state"]},
+	"created_at":  {Type: db.FieldTime, Value: taskSortableFields["created_at"]},
+	"updated_at":  {Type: db.FieldTime, Value: taskSortableFields["updated_at"]},
+}
+
+// TaskStore is an in-memory implementation of repositories.TaskRepository.
+type TaskStore struct {
+	mu          sync.RWMutex
+	tasks       map[string]entities.Task             // Indexed by ID
+	transitions map[string][]entities.TaskTransition // Indexed by task ID
+}
+
+// NewTaskStore returns a new empty TaskStore.
+func NewTaskStore() *TaskStore {
+	return &TaskStore{
+		tasks:       make(map[string]entities.Task),
+		transitions: make(map[string][]entities.TaskTransition),
+	}
+}
+
+// GetAll gets a page of the tasks of a user, optionally restricted to some states and to the filters.
+// If userID is empty, the tasks of all users are returned.
+func (t *TaskStore) GetAll(ctx context.Context, userID string, states []string, pagination requests.Pagination) ([]entities.Task, responses.Pagination, error) {
+	t.mu.RLock()
+	tasks := make([]entities.Task, 0, len(t.tasks))
+	for _, task := range t.tasks {
+		if task.DeletedAt.Valid || !ownedBy(task, userID) || !inStates(task, states) {
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	t.mu.RUnlock()
+
+	return findPage(tasks, pagination, taskSortableFields, taskFilterableFields)
+}
+
+// GetAllRows is not supported and returns ErrRowsNotSupported.
+func (t *TaskStore) GetAllRows(ctx context.Context, userID string) (*sql.Rows, error) {
+	return nil, ErrRowsNotSupported
+}
+
+// Create adds a task.
+func (t *TaskStore) Create(ctx context.Context, task *entities.Task) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now().UTC()
+	task.ID = uuid.NewString()
+	if task.State == "" {
+		task.State = entities.TaskStateTodo
+	}
+	task.CreatedAt = now
+	task.UpdatedAt = now
+	task.Transitions = nil
+
+	t.tasks[task.ID] = *task
+
+	return nil
+}
+
+// GetByID returns a task from its ID.
+// Transitions are loaded from the oldest to the newest.
+// An empty task is returned if the task does not exist.
+func (t *TaskStore) GetByID(ctx context.Context, id string) (entities.Task, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	task, ok := t.tasks[id]
+	if !ok || task.DeletedAt.Valid {
+		return entities.Task{}, nil
+	}
+
+	task.Transitions = make([]entities.TaskTransition, len(t.transitions[id]))
+	copy(task.Transitions, t.transitions[id])
+	sort.SliceStable(task.Transitions, func(i, j int) bool {
+		a, b := task.Transitions[i], task.Transitions[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+
+	return task, nil
+}
+
+// GetDeletedByID returns a soft deleted task from its ID.
+// An empty task is returned if the task does not exist or is not deleted.
+func (t *TaskStore) GetDeletedByID(ctx context.Context, id string) (entities.Task, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	task, ok := t.tasks[id]
+	if !ok || !task.DeletedAt.Valid {
+		return entities.Task{}, nil
+	}
+	return task, nil
+}
+
+// Update updates task information.
+// The task is emptied if it does not exist.
+func (t *TaskStore) Update(ctx context.Context, task *entities.Task) error {
+	t.mu.Lock()
+	if existing, ok := t.tasks[task.ID]; ok && !existing.DeletedAt.Valid {
+		existing.Name = task.Name
+		existing.Description = task.Description
+		existing.UpdatedAt = time.Now().UTC()
+		t.tasks[task.ID] = existing
+	}
+	t.mu.Unlock()
+
+	taskUpdated, err := t.GetByID(ctx, task.ID)
+	if err != nil {
+		return err
+	}
+
+	*task = taskUpdated
+
+	return nil
+}
+
+// Delete soft deletes a task.
+func (t *TaskStore) Delete(ctx context.Context, id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if task, ok := t.tasks[id]; ok && !task.DeletedAt.Valid {
+		task.DeletedAt = gorm.DeletedAt{Time: time.Now().UTC(), Valid: true}
+		t.tasks[id] = task
+	}
+	return nil
+}
+
+// Restore restores a soft deleted task.
+func (t *TaskStore) Restore(ctx context.Context, id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if task, ok := t.tasks[id]; ok {
+		task.DeletedAt = gorm.DeletedAt{}
+		t.tasks[id] = task
+	}
+	return nil
+}
+
+// Transition changes the state of a task and records the transition.
+// The state is only changed if the task is still in the transition initial state,
+// false is returned otherwise.
+func (t *TaskStore) Transition(ctx context.Context, transition *entities.TaskTransition) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	task, ok := t.tasks[transition.TaskID]
+	if !ok || task.DeletedAt.Valid || task.State != transition.FromState {
+		return false, nil
+	}
+
+	now := time.Now().UTC()
+	task.State = transition.ToState
+	task.UpdatedAt = now
+	t.tasks[task.ID] = task
+
+	transition.ID = uuid.NewString()
+	transition.CreatedAt = now
+	t.transitions[task.ID] = append(t.transitions[task.ID], *transition)
+
+	return true, nil
+}
+
+// ScanRow is not supported and returns ErrRowsNotSupported.
+func (t *TaskStore) ScanRow(ctx context.Context, rows *sql.Rows, task *entities.Task) error {
+	return ErrRowsNotSupported
+}
+
+// ownedBy returns true if the task belongs to the user or if userID is empty.
+func ownedBy(task entities.Task, userID string) bool {
+	return userID == "" || (task.UserID != nil && *task.UserID == userID)
+}
+
+// inStates returns true if the task is in one of the states or if states is empty.
+func inStates(task entities.Task, states []string) bool {
+	if len(states) == 0 {
+		return true
+	}
+	for _, s := range states {
+		if task.State == s {
+			return true
+		}
+	}
+	return false
+}
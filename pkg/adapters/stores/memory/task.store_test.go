@@ -0,0 +1,169 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/repositories"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ repositories.TaskRepository = (*TaskStore)(nil)
+
+// ctx is the context of the tests.
+var ctx = context.Background()
+
+// newTaskStoreWithTasks returns a store with tasks of two users created at one minute intervals.
+func newTaskStoreWithTasks(t *testing.T) (*TaskStore, []entities.Task) {
+	store := NewTaskStore()
+	owners := []string{"user-1", "user-1", "user-2", "user-1", "user-2"}
+	names := []string{"Write tests", "Fix bug", "Deploy", "Review", "Write docs"}
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tasks := make([]entities.Task, len(names))
+	for i := range names {
+		task := entities.Task{Name: names[i], UserID: &owners[i]}
+		assert.Nil(t, store.Create(ctx, &task))
+
+		// Fixed creation dates
+		task.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		store.tasks[task.ID] = task
+		tasks[i] = task
+	}
+	return store, tasks
+}
+
+func TestTaskStoreGetAll(t *testing.T) {
+	store, tasks := newTaskStoreWithTasks(t)
+
+	// Owner
+	list, _, err := store.GetAll(ctx, "user-1", nil, requests.Pagination{Sorts: "+created_at"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{tasks[0].ID, tasks[1].ID, tasks[3].ID}, taskIDs(list))
+
+	// All owners, sorted by name descending
+	list, _, err = store.GetAll(ctx, "", nil, requests.Pagination{Sorts: "-name"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{tasks[0].ID, tasks[4].ID, tasks[3].ID, tasks[1].ID, tasks[2].ID}, taskIDs(list))
+
+	// States
+	ok, err := store.Transition(ctx, &entities.TaskTransition{TaskID: tasks[1].ID, FromState: entities.TaskStateTodo, ToState: entities.TaskStateInProgress})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	list, _, err = store.GetAll(ctx, "", []string{entities.TaskStateInProgress}, requests.Pagination{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{tasks[1].ID}, taskIDs(list))
+
+	// Filters
+	list, _, err = store.GetAll(ctx, "", nil, requests.Pagination{
+		Sorts: "created_at",
+		Filters: requests.Filters{
+			{Field: "name", Operator: requests.FilterStartsWith, Values: []string{"write"}},
+			{Field: "created_at", Operator: requests.FilterGte, Values: []string{"2024-01-01 10:01:00"}},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{tasks[4].ID}, taskIDs(list))
+
+	// Deleted tasks are excluded
+	assert.Nil(t, store.Delete(ctx, tasks[0].ID))
+	list, _, err = store.GetAll(ctx, "user-1", nil, requests.Pagination{})
+	assert.Nil(t, err)
+	assert.Len(t, list, 2)
+}
+
+func TestTaskStoreGetAllInvalidParameters(t *testing.T) {
+	store, _ := newTaskStoreWithTasks(t)
+
+	_, _, err := store.GetAll(ctx, "", nil, requests.Pagination{Sorts: "+user_id"})
+	assert.Equal(t, utils.ValidatorErrors{{
+		FailedField: "s",
+		Tag:         "sort",
+		Value:       "user_id",
+		Allowed:     []string{"created_at", "id", "name", "state", "updated_at"},
+	}}, err)
+
+	_, _, err = store.GetAll(ctx, "", nil, requests.Pagination{Filters: requests.Filters{
+		{Field: "name", Operator: requests.FilterGt, Values: []string{"a"}},
+	}})
+	assert.Equal(t, utils.ValidatorErrors{{FailedField: "filter[name][gt]", Tag: "operator", Value: "gt"}}, err)
+
+	_, _, err = store.GetAll(ctx, "", nil, requests.Pagination{Cursor: "invalid"})
+	assert.Equal(t, utils.ValidatorErrors{{FailedField: "cursor", Tag: "cursor"}}, err)
+}
+
+func TestTaskStoreGetAllPages(t *testing.T) {
+	store, tasks := newTaskStoreWithTasks(t)
+
+	// Pages
+	list, page, err := store.GetAll(ctx, "", nil, requests.Pagination{Page: "2", Limit: "2", Sorts: "created_at", Total: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{tasks[2].ID, tasks[3].ID}, taskIDs(list))
+	assert.Equal(t, int64(5), *page.Total)
+	assert.NotEmpty(t, page.NextCursor)
+	assert.NotEmpty(t, page.PrevCursor)
+
+	// Cursors
+	list, page, err = store.GetAll(ctx, "", nil, requests.Pagination{Limit: "2", Sorts: "created_at", Cursor: page.NextCursor})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{tasks[4].ID}, taskIDs(list))
+	assert.Empty(t, page.NextCursor)
+	assert.Nil(t, page.Total)
+
+	list, _, err = store.GetAll(ctx, "", nil, requests.Pagination{Limit: "2", Sorts: "created_at", Cursor: page.PrevCursor})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{tasks[2].ID, tasks[3].ID}, taskIDs(list))
+
+	// A cursor cannot be used with another sort
+	_, _, err = store.GetAll(ctx, "", nil, requests.Pagination{Limit: "2", Sorts: "name", Cursor: page.PrevCursor})
+	assert.NotNil(t, err)
+}
+
+func TestTaskStoreTransition(t *testing.T) {
+	store, tasks := newTaskStoreWithTasks(t)
+
+	transition := entities.TaskTransition{TaskID: tasks[0].ID, FromState: entities.TaskStateDone, ToState: entities.TaskStateArchived}
+	ok, err := store.Transition(ctx, &transition)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	// Concurrent transitions from the same state: only one succeeds
+	var wg sync.WaitGroup
+	results := make(chan bool, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, _ := store.Transition(ctx, &entities.TaskTransition{TaskID: tasks[0].ID, FromState: entities.TaskStateTodo, ToState: entities.TaskStateInProgress})
+			results <- ok
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	succeeded := 0
+	for ok := range results {
+		if ok {
+			succeeded++
+		}
+	}
+	assert.Equal(t, 1, succeeded)
+
+	task, err := store.GetByID(ctx, tasks[0].ID)
+	assert.Nil(t, err)
+	assert.Equal(t, entities.TaskStateInProgress, task.State)
+	assert.Len(t, task.Transitions, 1)
+}
+
+func taskIDs(tasks []entities.Task) []string {
+	ids := make([]string, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	return ids
+}
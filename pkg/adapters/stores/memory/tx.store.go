@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/repositories"
+)
+
+// TxManager runs units of work on in-memory stores.
+// Units of work are serialized and the stores are restored to their previous state if fn fails,
+// so changes made outside units of work while fn runs are lost on rollback.
+type TxManager struct {
+	mu    sync.Mutex
+	users *UserStore
+	tasks *TaskStore
+}
+
+// NewTxManager returns a new TxManager for the stores.
+func NewTxManager(users *UserStore, tasks *TaskStore) *TxManager {
+	return &TxManager{users: users, tasks: tasks}
+}
+
+// WithinTx runs fn with the stores and rolls back their changes if fn returns an error.
+func (t *TxManager) WithinTx(ctx context.Context, fn func(repos repositories.Repositories) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	users := t.users.snapshot()
+	tasks := t.tasks.snapshot()
+
+	if err := fn(repositories.Repositories{Users: t.users, Tasks: t.tasks}); err != nil {
+		t.users.restore(users)
+		t.tasks.restore(tasks)
+		return err
+	}
+	return nil
+}
+
+// userStoreState is a copy of the data of a UserStore.
+type userStoreState struct {
+	users              map[string]entities.User
+	userRoles          map[string][]string
+	passwordResets     map[string]entities.PasswordResets
+	emailVerifications map[string]entities.EmailVerification
+	refreshTokens      map[string]entities.RefreshToken
+	revokedTokens      map[string]entities.RevokedToken
+}
+
+// snapshot returns a copy of the store data.
+func (u *UserStore) snapshot() userStoreState {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	userRoles := make(map[string][]string, len(u.userRoles))
+	for id, roles := range u.userRoles {
+		userRoles[id] = append([]string(nil), roles...)
+	}
+
+	return userStoreState{
+		users:              copyMap(u.users),
+		userRoles:          userRoles,
+		passwordResets:     copyMap(u.passwordResets),
+		emailVerifications: copyMap(u.emailVerifications),
+		refreshTokens:      copyMap(u.refreshTokens),
+		revokedTokens:      copyMap(u.revokedTokens),
+	}
+}
+
+// restore replaces the store data by a snapshot.
+func (u *UserStore) restore(s userStoreState) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.users = s.users
+	u.userRoles = s.userRoles
+	u.passwordResets = s.passwordResets
+	u.emailVerifications = s.emailVerifications
+	u.refreshTokens = s.refreshTokens
+	u.revokedTokens = s.revokedTokens
+}
+
+// taskStoreState is a copy of the data of a TaskStore.
+type taskStoreState struct {
+	tasks       map[string]entities.Task
+	transitions map[string][]entities.TaskTransition
+}
+
+// snapshot returns a copy of the store data.
+func (t *TaskStore) snapshot() taskStoreState {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	transitions := make(map[string][]entities.TaskTransition, len(t.transitions))
+	for id, list := range t.transitions {
+		transitions[id] = append([]entities.TaskTransition(nil), list...)
+	}
+
+	return taskStoreState{
+		tasks:       copyMap(t.tasks),
+		transitions: transitions,
+	}
+}
+
+// restore replaces the store data by a snapshot.
+func (t *TaskStore) restore(s taskStoreState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.tasks = s.tasks
+	t.transitions = s.transitions
+}
+
+// copyMap returns a shallow copy of a map.
+func copyMap[V any](m map[string]V) map[string]V {
+	c := make(map[string]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
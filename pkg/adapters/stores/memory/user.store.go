@@ -0,0 +1,549 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/stores"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/responses"
+	values_objects "github.com/fabienbellanger/fiber-boilerplate/pkg/domain/value_objects"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// userSortableFields lists the fields which can be used to sort users.
+var userSortableFields = fields[entities.User]{
+	"id":         func(u entities.User) interface{} { return u.ID },
+	"username":   func(u entities.User) interface{} { return u.Username.String() },
+	"lastname":   func(u entities.User) interface{} { return u.Lastname },
+	"firstname":  func(u entities.User) interface{} { return u.Firstname },
+	"created_at": func(u entities.User) interface{} { return u.CreatedAt },
+	"updated_at": func(u entities.User) interface{} { return u.UpdatedAt },
+}
+
+// userFilterableFields lists the fields which can be used to filter users.
+var userFilterableFields = filterableFields[entities.User]{
+	"username":   {Type: db.FieldString, Value: userSortableFields["username"]},
+	"lastname":   {Type: db.FieldString, Value: userSortableFields["lastname"]},
+	"firstname":  {Type: db.FieldString, Value: userSortableFields["firstname"]},
+	"created_at": {Type: db.FieldTime, Value: userSortableFields["created_at"]},
+	"updated_at": {Type: db.FieldTime, Value: userSortableFields["updated_at"]},
+}
+
+// UserStore is an in-memory implementation of repositories.UserRepository.
+// Roles are initialized with entities.DefaultRolesPermissions.
+type UserStore struct {
+	mu                 sync.RWMutex
+	hasher             utils.PasswordHasher
+	users              map[string]entities.User // Indexed by ID
+	userRoles          map[string][]string      // Role names indexed by user ID
+	roles              map[string]entities.Role // Indexed by name
+	passwordResets     map[string]entities.PasswordResets
+	emailVerifications map[string]entities.EmailVerification
+	refreshTokens      map[string]entities.RefreshToken
+	revokedTokens      map[string]entities.RevokedToken
+}
+
+// NewUserStore returns a new empty UserStore.
+func NewUserStore(hasher utils.PasswordHasher) *UserStore {
+	roles := make(map[string]entities.Role, len(entities.DefaultRolesPermissions))
+	for name, permissionNames := range entities.DefaultRolesPermissions {
+		role := entities.Role{Name: name, Permissions: make([]entities.Permission, len(permissionNames))}
+		for i, permission := range permissionNames {
+			role.Permissions[i] = entities.Permission{Name: permission}
+		}
+		roles[name] = role
+	}
+
+	return &UserStore{
+		hasher:             hasher,
+		users:              make(map[string]entities.User),
+		userRoles:          make(map[string][]string),
+		roles:              roles,
+		passwordResets:     make(map[string]entities.PasswordResets),
+		emailVerifications: make(map[string]entities.EmailVerification),
+		refreshTokens:      make(map[string]entities.RefreshToken),
+		revokedTokens:      make(map[string]entities.RevokedToken),
+	}
+}
+
+// Login gets user from username and password.
+// gorm.ErrRecordNotFound is returned if the user does not exist or if the password is wrong.
+func (u *UserStore) Login(ctx context.Context, username, password string) (entities.User, error) {
+	user, err := u.GetByUsername(ctx, username)
+	if err != nil {
+		return entities.User{}, err
+	}
+	if user.ID == "" {
+		return entities.User{}, gorm.ErrRecordNotFound
+	}
+
+	ok, err := u.hasher.Verify(user.Password, password)
+	if err != nil {
+		return entities.User{}, err
+	}
+	if !ok {
+		return entities.User{}, gorm.ErrRecordNotFound
+	}
+
+	return u.GetByID(ctx, user.ID)
+}
+
+// GetAll gets a page of users matching the filters.
+func (u *UserStore) GetAll(ctx context.Context, pagination requests.Pagination) ([]entities.User, responses.Pagination, error) {
+	u.mu.RLock()
+	users := make([]entities.User, 0, len(u.users))
+	for _, user := range u.users {
+		if !user.DeletedAt.Valid {
+			users = append(users, user)
+		}
+	}
+	u.mu.RUnlock()
+
+	return findPage(users, pagination, userSortableFields, userFilterableFields)
+}
+
+// Create adds a user.
+// gorm.ErrDuplicatedKey is returned if the username is already used.
+func (u *UserStore) Create(ctx context.Context, user *entities.User) error {
+	hashedPassword, err := u.hasher.Hash(user.Password)
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, existing := range u.users {
+		if existing.Username == user.Username {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+
+	now := time.Now().UTC()
+	user.ID = uuid.NewString()
+	user.Password = hashedPassword
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	user.Roles = nil
+
+	u.users[user.ID] = *user
+
+	return nil
+}
+
+// GetByID returns a user from its ID with its roles and permissions.
+// An empty user is returned if the user does not exist.
+func (u *UserStore) GetByID(ctx context.Context, id string) (entities.User, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	user, ok := u.users[id]
+	if !ok || user.DeletedAt.Valid {
+		return entities.User{}, nil
+	}
+
+	user.Roles = make([]entities.Role, 0, len(u.userRoles[id]))
+	for _, name := range u.userRoles[id] {
+		user.Roles = append(user.Roles, u.roles[name])
+	}
+
+	return user, nil
+}
+
+// GetByUsername returns a user from its username.
+// An empty user is returned if the user does not exist.
+func (u *UserStore) GetByUsername(ctx context.Context, username string) (entities.User, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	for _, user := range u.users {
+		if user.Username.String() == username && !user.DeletedAt.Valid {
+			return user, nil
+		}
+	}
+	return entities.User{}, nil
+}
+
+// Delete soft deletes a user.
+func (u *UserStore) Delete(ctx context.Context, id string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if user, ok := u.users[id]; ok && !user.DeletedAt.Valid {
+		user.DeletedAt = gorm.DeletedAt{Time: time.Now().UTC(), Valid: true}
+		u.users[id] = user
+	}
+	return nil
+}
+
+// Update updates user information.
+// The user is emptied if it does not exist.
+// gorm.ErrDuplicatedKey is returned if the username is already used by another user.
+func (u *UserStore) Update(ctx context.Context, user *entities.User) error {
+	hashedPassword, err := u.hasher.Hash(user.Password)
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	if existing, ok := u.users[user.ID]; ok && !existing.DeletedAt.Valid {
+		for _, other := range u.users {
+			if other.ID != user.ID && other.Username == user.Username {
+				u.mu.Unlock()
+				return gorm.ErrDuplicatedKey
+			}
+		}
+
+		existing.Lastname = user.Lastname
+		existing.Firstname = user.Firstname
+		existing.Username = user.Username
+		existing.Password = hashedPassword
+		existing.UpdatedAt = time.Now().UTC()
+		u.users[user.ID] = existing
+	}
+	u.mu.Unlock()
+
+	userUpdated, err := u.GetByID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	*user = userUpdated
+
+	return nil
+}
+
+// UpdateProfile updates the user profile (lastname and firstname).
+// The user is emptied if it does not exist.
+func (u *UserStore) UpdateProfile(ctx context.Context, user *entities.User) error {
+	u.mu.Lock()
+	if existing, ok := u.users[user.ID]; ok && !existing.DeletedAt.Valid {
+		existing.Lastname = user.Lastname
+		existing.Firstname = user.Firstname
+		existing.UpdatedAt = time.Now().UTC()
+		u.users[user.ID] = existing
+	}
+	u.mu.Unlock()
+
+	userUpdated, err := u.GetByID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	*user = userUpdated
+
+	return nil
+}
+
+// UpdatePassword updates user password.
+// gorm.ErrRecordNotFound is returned if the user does not exist or if its password hash is not currentPassword.
+func (u *UserStore) UpdatePassword(ctx context.Context, id, currentPassword, password string) error {
+	hashedPassword, err := u.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	user, ok := u.users[id]
+	if !ok || user.Password != currentPassword {
+		return gorm.ErrRecordNotFound
+	}
+
+	user.Password = hashedPassword
+	user.UpdatedAt = time.Now().UTC()
+	u.users[id] = user
+	return nil
+}
+
+// GetIDFromPasswordReset returns the ID and the password of the user of a valid password reset token hash.
+// Empty values are returned if the token does not exist or has expired.
+func (u *UserStore) GetIDFromPasswordReset(ctx context.Context, tokenHash, password string) (string, string, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	now := time.Now().UTC()
+	for _, passwordReset := range u.passwordResets {
+		if passwordReset.TokenHash != tokenHash || passwordReset.ExpiredAt.Before(now) {
+			continue
+		}
+
+		user, ok := u.users[passwordReset.UserID]
+		if !ok || user.DeletedAt.Valid {
+			break
+		}
+		return user.ID, user.Password, nil
+	}
+	return "", "", nil
+}
+
+// IncrementPasswordResetAttempts increments the number of uses of a valid password reset token hash
+// and returns it. 0 is returned if the token does not exist or has expired.
+func (u *UserStore) IncrementPasswordResetAttempts(ctx context.Context, tokenHash string) (uint, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := time.Now().UTC()
+	for userID, passwordReset := range u.passwordResets {
+		if passwordReset.TokenHash != tokenHash || passwordReset.ExpiredAt.Before(now) {
+			continue
+		}
+
+		passwordReset.Attempts++
+		u.passwordResets[userID] = passwordReset
+		return passwordReset.Attempts, nil
+	}
+	return 0, nil
+}
+
+// ConsumePasswordReset deletes the valid password reset of a token hash, so that the token cannot be used again.
+// gorm.ErrRecordNotFound is returned if the token does not exist, has expired or has already been consumed.
+func (u *UserStore) ConsumePasswordReset(ctx context.Context, tokenHash string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := time.Now().UTC()
+	for userID, passwordReset := range u.passwordResets {
+		if passwordReset.TokenHash != tokenHash || passwordReset.ExpiredAt.Before(now) {
+			continue
+		}
+
+		delete(u.passwordResets, userID)
+		return nil
+	}
+	return gorm.ErrRecordNotFound
+}
+
+// DeletePasswordReset deletes user password reset.
+func (u *UserStore) DeletePasswordReset(ctx context.Context, userId string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	delete(u.passwordResets, userId)
+	return nil
+}
+
+// CreateOrUpdatePasswordReset adds a reset password request or replaces the existing one.
+func (u *UserStore) CreateOrUpdatePasswordReset(ctx context.Context, passwordReset entities.PasswordResets) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.passwordResets[passwordReset.UserID] = passwordReset
+	return nil
+}
+
+// GetEmailVerification returns the valid email verification of a token hash.
+// An empty email verification is returned if the token does not exist, has expired or if the user has been deleted.
+func (u *UserStore) GetEmailVerification(ctx context.Context, tokenHash string) (entities.EmailVerification, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	now := time.Now().UTC()
+	for _, emailVerification := range u.emailVerifications {
+		if emailVerification.TokenHash != tokenHash || emailVerification.ExpiredAt.Before(now) {
+			continue
+		}
+
+		user, ok := u.users[emailVerification.UserID]
+		if !ok || user.DeletedAt.Valid {
+			break
+		}
+		return emailVerification, nil
+	}
+	return entities.EmailVerification{}, nil
+}
+
+// DeleteEmailVerification deletes user email verification.
+func (u *UserStore) DeleteEmailVerification(ctx context.Context, userID string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	delete(u.emailVerifications, userID)
+	return nil
+}
+
+// CreateOrUpdateEmailVerification adds an email verification or replaces the existing one.
+func (u *UserStore) CreateOrUpdateEmailVerification(ctx context.Context, emailVerification entities.EmailVerification) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.emailVerifications[emailVerification.UserID] = emailVerification
+	return nil
+}
+
+// VerifyEmail sets the username of a user to the verified email.
+// gorm.ErrDuplicatedKey is returned if the email is already used by another user.
+func (u *UserStore) VerifyEmail(ctx context.Context, userID, email string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	user, ok := u.users[userID]
+	if !ok {
+		return nil
+	}
+	for _, other := range u.users {
+		if other.ID != userID && other.Username.String() == email {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+
+	now := time.Now().UTC()
+	user.Username = values_objects.Email(email)
+	user.EmailVerifiedAt = &now
+	user.UpdatedAt = now
+	u.users[userID] = user
+	return nil
+}
+
+// CreateRefreshToken adds a refresh token.
+func (u *UserStore) CreateRefreshToken(ctx context.Context, refreshToken *entities.RefreshToken) error {
+	if refreshToken.ID == "" {
+		refreshToken.ID = uuid.NewString()
+	}
+	if refreshToken.CreatedAt.IsZero() {
+		refreshToken.CreatedAt = time.Now().UTC()
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.refreshTokens[refreshToken.ID] = *refreshToken
+	return nil
+}
+
+// GetRefreshToken returns a refresh token from its hash.
+// An empty token is returned if the token does not exist.
+func (u *UserStore) GetRefreshToken(ctx context.Context, tokenHash string) (entities.RefreshToken, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	for _, refreshToken := range u.refreshTokens {
+		if refreshToken.TokenHash == tokenHash {
+			return refreshToken, nil
+		}
+	}
+	return entities.RefreshToken{}, nil
+}
+
+// UseRefreshToken marks a refresh token as used.
+// It returns false if the token had already been used or revoked.
+func (u *UserStore) UseRefreshToken(ctx context.Context, id string) (bool, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	refreshToken, ok := u.refreshTokens[id]
+	if !ok || !refreshToken.IsUsable() {
+		return false, nil
+	}
+
+	now := time.Now().UTC()
+	refreshToken.UsedAt = &now
+	u.refreshTokens[id] = refreshToken
+
+	return true, nil
+}
+
+// RevokeRefreshTokenFamily revokes all the refresh tokens of a family.
+func (u *UserStore) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	u.revokeRefreshTokens(func(rt entities.RefreshToken) bool {
+		return rt.FamilyID == familyID
+	})
+	return nil
+}
+
+// RevokeUserRefreshTokens revokes all the refresh tokens of a user.
+func (u *UserStore) RevokeUserRefreshTokens(ctx context.Context, userID string) error {
+	u.revokeRefreshTokens(func(rt entities.RefreshToken) bool {
+		return rt.UserID == userID
+	})
+	return nil
+}
+
+// revokeRefreshTokens revokes the not revoked refresh tokens selected by the function.
+func (u *UserStore) revokeRefreshTokens(selected func(rt entities.RefreshToken) bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := time.Now().UTC()
+	for id, refreshToken := range u.refreshTokens {
+		if refreshToken.RevokedAt == nil && selected(refreshToken) {
+			refreshToken.RevokedAt = &now
+			u.refreshTokens[id] = refreshToken
+		}
+	}
+}
+
+// RevokeToken adds an access token to the revoked tokens list.
+// Expired revoked tokens are removed at the same time.
+func (u *UserStore) RevokeToken(ctx context.Context, revokedToken entities.RevokedToken) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := time.Now().UTC()
+	for id, t := range u.revokedTokens {
+		if t.ExpiredAt.Before(now) {
+			delete(u.revokedTokens, id)
+		}
+	}
+
+	if _, ok := u.revokedTokens[revokedToken.ID]; !ok {
+		u.revokedTokens[revokedToken.ID] = revokedToken
+	}
+	return nil
+}
+
+// IsTokenRevoked returns true if the access token has been revoked.
+func (u *UserStore) IsTokenRevoked(ctx context.Context, id string) (bool, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	_, ok := u.revokedTokens[id]
+	return ok, nil
+}
+
+// IncrementTokenVersion increments the user token version to invalidate all its access tokens.
+func (u *UserStore) IncrementTokenVersion(ctx context.Context, userID string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if user, ok := u.users[userID]; ok {
+		user.TokenVersion++
+		u.users[userID] = user
+	}
+	return nil
+}
+
+// AssignRoles adds roles to a user.
+// stores.ErrUnknownRole is returned if a role does not exist.
+func (u *UserStore) AssignRoles(ctx context.Context, userID string, roles ...string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, name := range roles {
+		if _, ok := u.roles[name]; !ok {
+			return fmt.Errorf("%w: %s", stores.ErrUnknownRole, name)
+		}
+	}
+
+	for _, name := range roles {
+		assigned := false
+		for _, r := range u.userRoles[userID] {
+			if r == name {
+				assigned = true
+				break
+			}
+		}
+		if !assigned {
+			u.userRoles[userID] = append(u.userRoles[userID], name)
+		}
+	}
+	return nil
+}
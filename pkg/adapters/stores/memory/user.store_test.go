@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/stores"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/repositories"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var _ repositories.UserRepository = (*UserStore)(nil)
+
+func TestUserStore(t *testing.T) {
+	store := NewUserStore(utils.NewPasswordHasher(utils.PasswordAlgoBcrypt))
+
+	user := entities.User{Username: "john@test.com", Password: "00000000", Lastname: "Doe", Firstname: "John"}
+	assert.Nil(t, store.Create(ctx, &user))
+	assert.NotEmpty(t, user.ID)
+	assert.NotEqual(t, "00000000", user.Password)
+
+	// Duplicated username
+	err := store.Create(ctx, &entities.User{Username: "john@test.com", Password: "00000000"})
+	assert.True(t, errors.Is(err, gorm.ErrDuplicatedKey))
+
+	// Roles
+	assert.Nil(t, store.AssignRoles(ctx, user.ID, entities.RoleUser, entities.RoleUser))
+	assert.True(t, errors.Is(store.AssignRoles(ctx, user.ID, "unknown"), stores.ErrUnknownRole))
+
+	// Login
+	logged, err := store.Login(ctx, "john@test.com", "00000000")
+	assert.Nil(t, err)
+	assert.Equal(t, user.ID, logged.ID)
+	assert.Equal(t, []string{entities.RoleUser}, logged.RoleNames())
+	assert.NotEmpty(t, logged.Roles[0].Permissions)
+
+	_, err = store.Login(ctx, "john@test.com", "11111111")
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	_, err = store.Login(ctx, "unknown@test.com", "00000000")
+	assert.True(t, errors.Is(err, gorm.ErrRecordNotFound))
+
+	// List
+	list, _, err := store.GetAll(ctx, requests.Pagination{Filters: requests.Filters{
+		{Field: "lastname", Operator: requests.FilterContains, Values: []string{"DO"}},
+	}})
+	assert.Nil(t, err)
+	assert.Len(t, list, 1)
+
+	// Delete
+	assert.Nil(t, store.Delete(ctx, user.ID))
+	deleted, err := store.GetByID(ctx, user.ID)
+	assert.Nil(t, err)
+	assert.Empty(t, deleted.ID)
+}
@@ -1,13 +1,35 @@
 package stores
 
 import (
+	"context"
 	"database/sql"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/responses"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+// taskSortableFields lists the fields which can be used to sort tasks.
+var taskSortableFields = db.SortableFields{
+	"id":         "id",
+	"name":       "name",
+	"state":      "state",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// taskFilterableFields lists the fields which can be used to filter tasks.
+var taskFilterableFields = db.FilterableFields{
+	"name":        {Column: "name", Type: db.FieldString},
+	"description": {Column: "description", Type: db.FieldString},
+	"state":       {Column: "state", Type: db.FieldString},
+	"created_at":  {Column: "created_at", Type: db.FieldTime},
+	"updated_at":  {Column: "updated_at", Type: db.FieldTime},
+}
+
 // TaskStore ...
 type TaskStore struct {
 	db *db.DB
@@ -18,36 +40,140 @@ func NewTaskStore(db *db.DB) TaskStore {
 	return TaskStore{db: db}
 }
 
-// GetAll gets all users in database.
-func (t TaskStore) GetAll(page, limit, sorts string) (tasks []entities.Task, total int64, err error) {
-	// Total rows
-	t.db.Model(&tasks).Count(&total)
+// GetAll gets a page of the tasks of a user in database, optionally restricted to some states
+// and to the filters. If userID is empty, the tasks of all users are returned.
+func (t TaskStore) GetAll(ctx context.Context, userID string, states []string, pagination requests.Pagination) ([]entities.Task, responses.Pagination, error) {
+	q := t.db.WithContext(ctx).Scopes(ownedBy(userID), inStates(states), db.Filter(pagination.Filters, taskFilterableFields))
 
-	q := t.db.Scopes(db.Paginate(page, limit))
-	q.Scopes(db.Order(sorts))
-	if response := q.Find(&tasks); response.Error != nil {
-		return tasks, total, response.Error
-	}
-	return tasks, total, nil
+	return db.FindPage[entities.Task](q, pagination, taskSortableFields)
 }
 
-// GetAllRows gets all tasks in database.
-func (t TaskStore) GetAllRows() (*sql.Rows, error) {
-	return t.db.Model(&entities.Task{}).Where("deleted_at IS NULL").Rows()
+// GetAllRows gets all tasks of a user in database.
+// If userID is empty, the tasks of all users are returned.
+func (t TaskStore) GetAllRows(ctx context.Context, userID string) (*sql.Rows, error) {
+	return t.db.WithContext(ctx).Model(&entities.Task{}).Scopes(ownedBy(userID)).Where("deleted_at IS NULL").Rows()
 }
 
 // Create a new task in database.
-func (t TaskStore) Create(task *entities.Task) error {
+func (t TaskStore) Create(ctx context.Context, task *entities.Task) error {
 	// UUID
 	// ----
 	task.ID = uuid.NewString()
 
-	if result := t.db.Create(&task); result.Error != nil {
+	if result := t.db.WithContext(ctx).Create(&task); result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// GetByID returns a task from its ID.
+// Transitions are loaded from the oldest to the newest.
+func (t TaskStore) GetByID(ctx context.Context, id string) (task entities.Task, err error) {
+	q := t.db.WithContext(ctx).Preload("Transitions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at, id")
+	})
+	if result := q.Find(&task, "id = ?", id); result.Error != nil {
+		return task, result.Error
+	}
+	return task, err
+}
+
+// GetDeletedByID returns a soft deleted task from its ID.
+func (t TaskStore) GetDeletedByID(ctx context.Context, id string) (task entities.Task, err error) {
+	if result := t.db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").Find(&task, "id = ?", id); result.Error != nil {
+		return task, result.Error
+	}
+	return task, err
+}
+
+// Update updates task information.
+func (t TaskStore) Update(ctx context.Context, task *entities.Task) error {
+	result := t.db.WithContext(ctx).Model(&entities.Task{}).Where("id = ?", task.ID).Select("name", "description").Updates(entities.Task{
+		Name:        task.Name,
+		Description: task.Description,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	taskUpdated, err := t.GetByID(ctx, task.ID)
+	if err != nil {
+		return err
+	}
+
+	*task = taskUpdated
+
+	return nil
+}
+
+// Delete soft deletes a task from database.
+func (t TaskStore) Delete(ctx context.Context, id string) error {
+	result := t.db.WithContext(ctx).Delete(&entities.Task{}, "id = ?", id)
+	if result.Error != nil {
 		return result.Error
 	}
 	return nil
 }
 
-func (t TaskStore) ScanRow(rows *sql.Rows, task *entities.Task) error {
-	return t.db.ScanRows(rows, &task)
+// Restore restores a soft deleted task.
+func (t TaskStore) Restore(ctx context.Context, id string) error {
+	result := t.db.WithContext(ctx).Unscoped().Model(&entities.Task{}).Where("id = ?", id).Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// Transition changes the state of a task and records the transition.
+// The state is only changed if the task is still in the transition initial state,
+// false is returned otherwise.
+func (t TaskStore) Transition(ctx context.Context, transition *entities.TaskTransition) (bool, error) {
+	transition.ID = uuid.NewString()
+
+	updated := false
+	err := t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entities.Task{}).
+			Where("id = ? AND state = ?", transition.TaskID, transition.FromState).
+			Update("state", transition.ToState)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		if result := tx.Create(transition); result.Error != nil {
+			return result.Error
+		}
+		updated = true
+
+		return nil
+	})
+
+	return updated, err
+}
+
+// ScanRow scans a row into a task.
+func (t TaskStore) ScanRow(ctx context.Context, rows *sql.Rows, task *entities.Task) error {
+	return t.db.WithContext(ctx).ScanRows(rows, &task)
+}
+
+// ownedBy restricts the query to the tasks of a user if userID is not empty.
+func ownedBy(userID string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if userID == "" {
+			return db
+		}
+		return db.Where("user_id = ?", userID)
+	}
+}
+
+// inStates restricts the query to the tasks in one of the states if states is not empty.
+func inStates(states []string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(states) == 0 {
+			return db
+		}
+		return db.Where("state IN ?", states)
+	}
 }
@@ -0,0 +1,122 @@
+package stores
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db/migrations"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// ctx is the context of the tests.
+var ctx = context.Background()
+
+// newTestDB returns an in-memory SQLite database with all the migrations applied.
+func newTestDB(t *testing.T) *db.DB {
+	database, err := db.New(&db.DatabaseConfig{Driver: db.DriverSQLite, Database: db.SQLiteMemory})
+	assert.Nil(t, err)
+
+	_, err = db.NewMigrator(database, migrations.All()).Up()
+	assert.Nil(t, err)
+
+	return database
+}
+
+// newTaskStoreWithTasks returns a task store with two tasks of a first user and one task of a second user.
+func newTaskStoreWithTasks(t *testing.T) (TaskStore, []entities.User, []entities.Task) {
+	database := newTestDB(t)
+
+	userStore := NewUserStore(database, utils.NewPasswordHasher(utils.PasswordAlgoBcrypt))
+	users := []entities.User{
+		{Username: "john@test.com", Password: "old-secret-0", Lastname: "Doe", Firstname: "John"},
+		{Username: "jane@test.com", Password: "old-secret-0", Lastname: "Doe", Firstname: "Jane"},
+	}
+	for i := range users {
+		assert.Nil(t, userStore.Create(ctx, &users[i]))
+	}
+
+	store := NewTaskStore(database)
+	tasks := []entities.Task{
+		{Name: "Task 1", State: entities.TaskStateTodo, UserID: &users[0].ID},
+		{Name: "Task 2", State: entities.TaskStateTodo, UserID: &users[0].ID},
+		{Name: "Other task", State: entities.TaskStateTodo, UserID: &users[1].ID},
+	}
+	for i := range tasks {
+		assert.Nil(t, store.Create(ctx, &tasks[i]))
+	}
+
+	return store, users, tasks
+}
+
+func TestTaskStoreGetAllOwnedBy(t *testing.T) {
+	store, users, tasks := newTaskStoreWithTasks(t)
+
+	list, _, err := store.GetAll(ctx, users[0].ID, nil, requests.Pagination{Sorts: "name"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{tasks[0].ID, tasks[1].ID}, taskIDs(list))
+
+	list, _, err = store.GetAll(ctx, users[1].ID, nil, requests.Pagination{Sorts: "name"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{tasks[2].ID}, taskIDs(list))
+
+	// All users
+	list, _, err = store.GetAll(ctx, "", nil, requests.Pagination{Sorts: "name"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{tasks[2].ID, tasks[0].ID, tasks[1].ID}, taskIDs(list))
+
+	// Rows
+	rows, err := store.GetAllRows(ctx, users[1].ID)
+	assert.Nil(t, err)
+	defer rows.Close()
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var task entities.Task
+		assert.Nil(t, store.ScanRow(ctx, rows, &task))
+		ids = append(ids, task.ID)
+	}
+	assert.Equal(t, []string{tasks[2].ID}, ids)
+}
+
+func TestTaskStoreTransition(t *testing.T) {
+	store, users, tasks := newTaskStoreWithTasks(t)
+
+	// The transition only applies if the task is still in the expected state
+	ok, err := store.Transition(ctx, &entities.TaskTransition{TaskID: tasks[0].ID, FromState: entities.TaskStateDone, ToState: entities.TaskStateArchived, UserID: &users[0].ID})
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = store.Transition(ctx, &entities.TaskTransition{TaskID: tasks[0].ID, FromState: entities.TaskStateTodo, ToState: entities.TaskStateInProgress, UserID: &users[0].ID})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// A second request which has read the task before the first transition fails
+	ok, err = store.Transition(ctx, &entities.TaskTransition{TaskID: tasks[0].ID, FromState: entities.TaskStateTodo, ToState: entities.TaskStateArchived, UserID: &users[0].ID})
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	task, err := store.GetByID(ctx, tasks[0].ID)
+	assert.Nil(t, err)
+	assert.Equal(t, entities.TaskStateInProgress, task.State)
+	assert.Len(t, task.Transitions, 1)
+	assert.Equal(t, entities.TaskStateTodo, task.Transitions[0].FromState)
+	assert.Equal(t, entities.TaskStateInProgress, task.Transitions[0].ToState)
+
+	// Deleted tasks cannot change
+	assert.Nil(t, store.Delete(ctx, tasks[1].ID))
+	ok, err = store.Transition(ctx, &entities.TaskTransition{TaskID: tasks[1].ID, FromState: entities.TaskStateTodo, ToState: entities.TaskStateInProgress, UserID: &users[0].ID})
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func taskIDs(tasks []entities.Task) []string {
+	ids := make([]string, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	return ids
+}
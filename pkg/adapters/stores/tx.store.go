@@ -0,0 +1,30 @@
+package stores
+
+import (
+	"context"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/repositories"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+)
+
+// TxManager runs units of work in database transactions.
+type TxManager struct {
+	db     *db.DB
+	hasher utils.PasswordHasher
+}
+
+// NewTxManager returns a new TxManager
+func NewTxManager(db *db.DB, hasher utils.PasswordHasher) TxManager {
+	return TxManager{db: db, hasher: hasher}
+}
+
+// WithinTx runs fn with stores using the same database transaction.
+func (t TxManager) WithinTx(ctx context.Context, fn func(repos repositories.Repositories) error) error {
+	return t.db.WithinTx(ctx, func(tx *db.DB) error {
+		return fn(repositories.Repositories{
+			Users: NewUserStore(tx, t.hasher),
+			Tasks: NewTaskStore(tx),
+		})
+	})
+}
@@ -1,88 +1,137 @@
 package stores
 
 import (
-	"crypto/sha512"
-	"encoding/hex"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/responses"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+// ErrUnknownRole is returned when assigning a role which does not exist.
+var ErrUnknownRole = errors.New("unknown role")
+
+// userSortableFields lists the fields which can be used to sort users.
+var userSortableFields = db.SortableFields{
+	"id":         "id",
+	"username":   "username",
+	"lastname":   "lastname",
+	"firstname":  "firstname",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// userFilterableFields lists the fields which can be used to filter users.
+var userFilterableFields = db.FilterableFields{
+	"username":   {Column: "username", Type: db.FieldString},
+	"lastname":   {Column: "lastname", Type: db.FieldString},
+	"firstname":  {Column: "firstname", Type: db.FieldString},
+	"created_at": {Column: "created_at", Type: db.FieldTime},
+	"updated_at": {Column: "updated_at", Type: db.FieldTime},
+}
+
 // UserStore type
 type UserStore struct {
-	db *db.DB
+	db     *db.DB
+	hasher utils.PasswordHasher
 }
 
 // NewUserStore returns a new UserStore
-func NewUserStore(db *db.DB) UserStore {
-	return UserStore{db: db}
+func NewUserStore(db *db.DB, hasher utils.PasswordHasher) UserStore {
+	return UserStore{db: db, hasher: hasher}
 }
 
 // Login gets user from username and password.
-func (u UserStore) Login(username, password string) (user entities.User, err error) {
-	// Hash password
-	// -------------
-	passwordBytes := sha512.Sum512([]byte(password))
-	password = hex.EncodeToString(passwordBytes[:])
+// The password is verified after fetching the user and legacy hashes
+// are replaced by a hash of the current algorithm.
+func (u UserStore) Login(ctx context.Context, username, password string) (user entities.User, err error) {
+	if result := u.db.WithContext(ctx).Preload("Roles.Permissions").Where("username = ?", username).First(&user); result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			// Hash anyway to not disclose existing usernames through response time
+			_, _ = u.hasher.Hash(password)
+		}
+		return entities.User{}, result.Error
+	}
 
-	if result := u.db.Where(&entities.User{Username: username, Password: password}).First(&user); result.Error != nil {
-		return user, result.Error
+	ok, err := u.hasher.Verify(user.Password, password)
+	if err != nil {
+		return entities.User{}, err
+	}
+	if !ok {
+		return entities.User{}, gorm.ErrRecordNotFound
 	}
-	return user, err
-}
 
-// GetAll gets all users in database.
-func (u UserStore) GetAll(page, limit, sorts string) (users []entities.User, total int64, err error) {
-	// Total rows
-	u.db.Model(&users).Count(&total)
+	// Rehash password
+	// ---------------
+	if u.hasher.NeedsRehash(user.Password) {
+		hashedPassword, err := u.hasher.Hash(password)
+		if err != nil {
+			return user, nil
+		}
 
-	q := u.db.Scopes(db.Paginate(page, limit))
-	q.Scopes(db.Order(sorts))
-	if response := q.Find(&users); response.Error != nil {
-		return users, total, response.Error
+		result := u.db.WithContext(ctx).Model(&entities.User{}).Where("id = ?", user.ID).UpdateColumn("password", hashedPassword)
+		if result.Error == nil {
+			user.Password = hashedPassword
+		}
 	}
-	return users, total, nil
+
+	return user, nil
+}
+
+// GetAll gets a page of users in database matching the filters.
+func (u UserStore) GetAll(ctx context.Context, pagination requests.Pagination) ([]entities.User, responses.Pagination, error) {
+	q := u.db.WithContext(ctx).Scopes(db.Filter(pagination.Filters, userFilterableFields))
+
+	return db.FindPage[entities.User](q, pagination, userSortableFields)
 }
 
 // Create adds user in database.
-func (u UserStore) Create(user *entities.User) error {
+func (u UserStore) Create(ctx context.Context, user *entities.User) error {
 	// UUID
 	// ----
 	user.ID = uuid.NewString()
 
 	// Hash password
 	// -------------
-	passwordBytes := sha512.Sum512([]byte(user.Password))
-	user.Password = hex.EncodeToString(passwordBytes[:])
+	hashedPassword, err := u.hasher.Hash(user.Password)
+	if err != nil {
+		return err
+	}
+	user.Password = hashedPassword
 
-	if result := u.db.Create(&user); result.Error != nil {
+	if result := u.db.WithContext(ctx).Create(&user); result.Error != nil {
 		return result.Error
 	}
 	return nil
 }
 
 // GetByID returns a user from its ID.
-func (u UserStore) GetByID(id string) (user entities.User, err error) {
-	if result := u.db.Find(&user, "id = ?", id); result.Error != nil {
+func (u UserStore) GetByID(ctx context.Context, id string) (user entities.User, err error) {
+	if result := u.db.WithContext(ctx).Preload("Roles.Permissions").Find(&user, "id = ?", id); result.Error != nil {
 		return user, result.Error
 	}
 	return user, err
 }
 
 // GetByUsername returns a user from its username.
-func (u UserStore) GetByUsername(username string) (user entities.User, err error) {
-	if result := u.db.Find(&user, "username = ?", username); result.Error != nil {
+func (u UserStore) GetByUsername(ctx context.Context, username string) (user entities.User, err error) {
+	if result := u.db.WithContext(ctx).Find(&user, "username = ?", username); result.Error != nil {
 		return user, result.Error
 	}
 	return user, err
 }
 
 // Delete deletes a user from database.
-func (u UserStore) Delete(id string) error {
-	result := u.db.Delete(&entities.User{}, "id = ?", id)
+func (u UserStore) Delete(ctx context.Context, id string) error {
+	result := u.db.WithContext(ctx).Delete(&entities.User{}, "id = ?", id)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -90,22 +139,25 @@ func (u UserStore) Delete(id string) error {
 }
 
 // Update updates user information.
-func (u UserStore) Update(user *entities.User) error {
+func (u UserStore) Update(ctx context.Context, user *entities.User) error {
 	// Hash password
 	// -------------
-	hashedPassword := sha512.Sum512([]byte(user.Password))
+	hashedPassword, err := u.hasher.Hash(user.Password)
+	if err != nil {
+		return err
+	}
 
-	result := u.db.Model(&entities.User{}).Where("id = ?", user.ID).Select("lastname", "firstname", "username", "password").Updates(entities.User{
+	result := u.db.WithContext(ctx).Model(&entities.User{}).Where("id = ?", user.ID).Select("lastname", "firstname", "username", "password").Updates(entities.User{
 		Lastname:  user.Lastname,
 		Firstname: user.Firstname,
 		Username:  user.Username,
-		Password:  hex.EncodeToString(hashedPassword[:]),
+		Password:  hashedPassword,
 	})
 	if result.Error != nil {
 		return result.Error
 	}
 
-	userUpdated, err := u.GetByID(user.ID)
+	userUpdated, err := u.GetByID(ctx, user.ID)
 	if err != nil {
 		return err
 	}
@@ -115,42 +167,64 @@ func (u UserStore) Update(user *entities.User) error {
 	return err
 }
 
+// UpdateProfile updates the user profile (lastname and firstname).
+func (u UserStore) UpdateProfile(ctx context.Context, user *entities.User) error {
+	result := u.db.WithContext(ctx).Model(&entities.User{}).Where("id = ?", user.ID).Select("lastname", "firstname").Updates(entities.User{
+		Lastname:  user.Lastname,
+		Firstname: user.Firstname,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	userUpdated, err := u.GetByID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	*user = userUpdated
+
+	return nil
+}
+
 // UpdatePassword updates user passwords.
-func (u UserStore) UpdatePassword(id, currentPassword, password string) error {
+// currentPassword is the current password hash: the password is not updated if it has changed in the meantime
+// and gorm.ErrRecordNotFound is returned.
+func (u UserStore) UpdatePassword(ctx context.Context, id, currentPassword, password string) error {
 	// Hash password
 	// -------------
-	hashedPassword := sha512.Sum512([]byte(password))
-
-	result := u.db.Exec(`
-		UPDATE users
-		SET password = ?, updated_at = ?
-		WHERE id = ?`,
-		hex.EncodeToString(hashedPassword[:]),
-		time.Now().UTC(),
-		id,
-	)
+	hashedPassword, err := u.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	result := u.db.WithContext(ctx).Model(&entities.User{}).Where("id = ? AND password = ?", id, currentPassword).UpdateColumns(map[string]interface{}{
+		"password":   hashedPassword,
+		"updated_at": time.Now().UTC(),
+	})
 	if result.Error != nil {
 		return result.Error
 	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
 
 	return nil
 }
 
-// GetIDFromPasswordReset update user password and delete password_resets line.
-func (u UserStore) GetIDFromPasswordReset(token, password string) (string, string, error) {
+// GetIDFromPasswordReset returns the ID and the password of the user of a valid password reset token hash.
+// Empty values are returned if the token does not exist or has expired.
+func (u UserStore) GetIDFromPasswordReset(ctx context.Context, tokenHash, password string) (string, string, error) {
 	data := struct {
 		ID       string
 		Password string
 	}{}
 
-	result := u.db.Raw(`
-			SELECT u.id AS id, u.password AS password
-			FROM password_resets pr
-				INNER JOIN users u ON u.id = pr.user_id AND u.deleted_at IS NULL
-			WHERE pr.token = ?
-				AND pr.expired_at >= ?`,
-		token,
-		time.Now().UTC()).Scan(&data)
+	result := u.db.WithContext(ctx).Model(&entities.PasswordResets{}).
+		Select("users.id AS id, users.password AS password").
+		Joins("INNER JOIN users ON users.id = password_resets.user_id AND users.deleted_at IS NULL").
+		Where("password_resets.token_hash = ? AND password_resets.expired_at >= ?", tokenHash, time.Now().UTC()).
+		Scan(&data)
 	if result.Error != nil {
 		return "", "", result.Error
 	}
@@ -158,9 +232,44 @@ func (u UserStore) GetIDFromPasswordReset(token, password string) (string, strin
 	return data.ID, data.Password, nil
 }
 
+// IncrementPasswordResetAttempts increments the number of uses of a valid password reset token hash
+// and returns it. 0 is returned if the token does not exist or has expired.
+func (u UserStore) IncrementPasswordResetAttempts(ctx context.Context, tokenHash string) (uint, error) {
+	result := u.db.WithContext(ctx).Model(&entities.PasswordResets{}).
+		Where("token_hash = ? AND expired_at >= ?", tokenHash, time.Now().UTC()).
+		UpdateColumn("attempts", gorm.Expr("attempts + ?", 1))
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, nil
+	}
+
+	var passwordReset entities.PasswordResets
+	if result := u.db.WithContext(ctx).Find(&passwordReset, "token_hash = ?", tokenHash); result.Error != nil {
+		return 0, result.Error
+	}
+	return passwordReset.Attempts, nil
+}
+
+// ConsumePasswordReset deletes the valid password reset of a token hash, so that the token cannot be used again.
+// gorm.ErrRecordNotFound is returned if the token does not exist, has expired or has already been consumed.
+func (u UserStore) ConsumePasswordReset(ctx context.Context, tokenHash string) error {
+	result := u.db.WithContext(ctx).
+		Where("token_hash = ? AND expired_at >= ?", tokenHash, time.Now().UTC()).
+		Delete(&entities.PasswordResets{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
 // DeletePasswordReset deletes user password reset.
-func (u UserStore) DeletePasswordReset(userId string) error {
-	result := u.db.Where("user_id = ?", userId).Delete(&entities.PasswordResets{})
+func (u UserStore) DeletePasswordReset(ctx context.Context, userId string) error {
+	result := u.db.WithContext(ctx).Where("user_id = ?", userId).Delete(&entities.PasswordResets{})
 	if result.Error != nil {
 		return result.Error
 	}
@@ -168,10 +277,162 @@ func (u UserStore) DeletePasswordReset(userId string) error {
 }
 
 // CreateOrUpdatePasswordReset add a reset password request in database or update it if a line already exists.
-func (u UserStore) CreateOrUpdatePasswordReset(passwordReset entities.PasswordResets) error {
-	result := u.db.Clauses(clause.OnConflict{
+func (u UserStore) CreateOrUpdatePasswordReset(ctx context.Context, passwordReset entities.PasswordResets) error {
+	result := u.db.WithContext(ctx).Clauses(clause.OnConflict{
 		UpdateAll: true,
 	}).Create(&passwordReset)
 
 	return result.Error
 }
+
+// GetEmailVerification returns the valid email verification of a token hash.
+// An empty email verification is returned if the token does not exist, has expired or if the user has been deleted.
+func (u UserStore) GetEmailVerification(ctx context.Context, tokenHash string) (emailVerification entities.EmailVerification, err error) {
+	result := u.db.WithContext(ctx).Model(&entities.EmailVerification{}).
+		Select("email_verifications.*").
+		Joins("INNER JOIN users ON users.id = email_verifications.user_id AND users.deleted_at IS NULL").
+		Where("email_verifications.token_hash = ? AND email_verifications.expired_at >= ?", tokenHash, time.Now().UTC()).
+		Scan(&emailVerification)
+	if result.Error != nil {
+		return emailVerification, result.Error
+	}
+	return emailVerification, err
+}
+
+// DeleteEmailVerification deletes user email verification.
+func (u UserStore) DeleteEmailVerification(ctx context.Context, userID string) error {
+	result := u.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&entities.EmailVerification{})
+	if result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// CreateOrUpdateEmailVerification adds an email verification in database or updates it if a line already exists.
+func (u UserStore) CreateOrUpdateEmailVerification(ctx context.Context, emailVerification entities.EmailVerification) error {
+	result := u.db.WithContext(ctx).Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(&emailVerification)
+
+	return result.Error
+}
+
+// VerifyEmail sets the username of a user to the verified email.
+// gorm.ErrDuplicatedKey is returned if the email is already used by another user.
+func (u UserStore) VerifyEmail(ctx context.Context, userID, email string) error {
+	now := time.Now().UTC()
+	result := u.db.WithContext(ctx).Model(&entities.User{}).Where("id = ?", userID).UpdateColumns(map[string]interface{}{
+		"username":          email,
+		"email_verified_at": now,
+		"updated_at":        now,
+	})
+
+	return result.Error
+}
+
+// CreateRefreshToken adds a refresh token in database.
+func (u UserStore) CreateRefreshToken(ctx context.Context, refreshToken *entities.RefreshToken) error {
+	if refreshToken.ID == "" {
+		refreshToken.ID = uuid.NewString()
+	}
+
+	if result := u.db.WithContext(ctx).Create(refreshToken); result.Error != nil {
+		return result.Error
+	}
+	return nil
+}
+
+// GetRefreshToken returns a refresh token from its hash.
+func (u UserStore) GetRefreshToken(ctx context.Context, tokenHash string) (refreshToken entities.RefreshToken, err error) {
+	if result := u.db.WithContext(ctx).Find(&refreshToken, "token_hash = ?", tokenHash); result.Error != nil {
+		return refreshToken, result.Error
+	}
+	return refreshToken, err
+}
+
+// UseRefreshToken marks a refresh token as used.
+// It returns false if the token had already been used or revoked.
+func (u UserStore) UseRefreshToken(ctx context.Context, id string) (bool, error) {
+	result := u.db.WithContext(ctx).Model(&entities.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		UpdateColumn("used_at", time.Now().UTC())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeRefreshTokenFamily revokes all the refresh tokens of a family.
+func (u UserStore) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	result := u.db.WithContext(ctx).Model(&entities.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		UpdateColumn("revoked_at", time.Now().UTC())
+
+	return result.Error
+}
+
+// RevokeUserRefreshTokens revokes all the refresh tokens of a user.
+func (u UserStore) RevokeUserRefreshTokens(ctx context.Context, userID string) error {
+	result := u.db.WithContext(ctx).Model(&entities.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		UpdateColumn("revoked_at", time.Now().UTC())
+
+	return result.Error
+}
+
+// RevokeToken adds an access token to the revoked tokens list.
+// Expired revoked tokens are removed at the same time.
+func (u UserStore) RevokeToken(ctx context.Context, revokedToken entities.RevokedToken) error {
+	result := u.db.WithContext(ctx).Where("expired_at < ?", time.Now().UTC()).Delete(&entities.RevokedToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	result = u.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&revokedToken)
+
+	return result.Error
+}
+
+// IsTokenRevoked returns true if the access token has been revoked.
+func (u UserStore) IsTokenRevoked(ctx context.Context, id string) (bool, error) {
+	var count int64
+	if result := u.db.WithContext(ctx).Model(&entities.RevokedToken{}).Where("id = ?", id).Count(&count); result.Error != nil {
+		return false, result.Error
+	}
+	return count > 0, nil
+}
+
+// IncrementTokenVersion increments the user token version to invalidate all its access tokens.
+func (u UserStore) IncrementTokenVersion(ctx context.Context, userID string) error {
+	result := u.db.WithContext(ctx).Model(&entities.User{}).
+		Where("id = ?", userID).
+		UpdateColumn("token_version", gorm.Expr("token_version + ?", 1))
+
+	return result.Error
+}
+
+// AssignRoles adds roles to a user.
+func (u UserStore) AssignRoles(ctx context.Context, userID string, roles ...string) error {
+	if len(roles) == 0 {
+		return nil
+	}
+
+	var existingRoles []entities.Role
+	if result := u.db.WithContext(ctx).Where("name IN ?", roles).Find(&existingRoles); result.Error != nil {
+		return result.Error
+	}
+	for _, name := range roles {
+		found := false
+		for _, r := range existingRoles {
+			if r.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: %s", ErrUnknownRole, name)
+		}
+	}
+
+	return u.db.WithContext(ctx).Model(&entities.User{ID: userID}).Omit("Roles.*").Association("Roles").Append(existingRoles)
+}
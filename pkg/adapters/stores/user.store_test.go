@@ -1 +1,83 @@
 package stores
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/repositories"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestUserStoreConsumePasswordReset(t *testing.T) {
+	store := NewUserStore(newTestDB(t), utils.NewPasswordHasher(utils.PasswordAlgoBcrypt))
+
+	users := []entities.User{
+		{Username: "john@test.com", Password: "old-secret-0", Lastname: "Doe", Firstname: "John"},
+		{Username: "jane@test.com", Password: "old-secret-0", Lastname: "Doe", Firstname: "Jane"},
+	}
+	for i := range users {
+		assert.Nil(t, store.Create(ctx, &users[i]))
+	}
+
+	passwordReset, token, err := entities.NewPasswordReset(users[0].ID, time.Hour)
+	assert.Nil(t, err)
+	assert.Nil(t, store.CreateOrUpdatePasswordReset(ctx, passwordReset))
+	assert.Nil(t, store.CreateOrUpdatePasswordReset(ctx, entities.PasswordResets{
+		UserID:    users[1].ID,
+		TokenHash: utils.HashToken("expired-token"),
+		ExpiredAt: time.Now().Add(-time.Minute).UTC(),
+	}))
+
+	// Only one of the concurrent uses of the token consumes it
+	var wg sync.WaitGroup
+	results := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- store.ConsumePasswordReset(ctx, utils.HashToken(token))
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	consumed := 0
+	for err := range results {
+		if err == nil {
+			consumed++
+		} else {
+			assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		}
+	}
+	assert.Equal(t, 1, consumed)
+
+	userID, _, err := store.GetIDFromPasswordReset(ctx, utils.HashToken(token), "")
+	assert.Nil(t, err)
+	assert.Empty(t, userID)
+
+	// Expired token
+	assert.ErrorIs(t, store.ConsumePasswordReset(ctx, utils.HashToken("expired-token")), gorm.ErrRecordNotFound)
+}
+
+func TestUserStoreCreateWithUnknownRole(t *testing.T) {
+	database := newTestDB(t)
+	hasher := utils.NewPasswordHasher(utils.PasswordAlgoBcrypt)
+
+	// An unknown role rolls the user creation back
+	user := entities.User{Username: "john@test.com", Password: "old-secret-0", Lastname: "Doe", Firstname: "John"}
+	err := NewTxManager(database, hasher).WithinTx(ctx, func(repos repositories.Repositories) error {
+		if err := repos.Users.Create(ctx, &user); err != nil {
+			return err
+		}
+		return repos.Users.AssignRoles(ctx, user.ID, entities.RoleUser, "admn")
+	})
+	assert.ErrorIs(t, err, ErrUnknownRole)
+
+	found, err := NewUserStore(database, hasher).GetByUsername(ctx, "john@test.com")
+	assert.Nil(t, err)
+	assert.Empty(t, found.ID)
+}
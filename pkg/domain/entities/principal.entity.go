@@ -0,0 +1,44 @@
+package entities
+
+import "time"
+
+// Principal represents the authenticated user of a request.
+type Principal struct {
+	ID           string
+	Username     string
+	Roles        []string
+	Permissions  []string
+	TokenID      string
+	TokenVersion uint
+	ExpiresAt    time.Time
+}
+
+// IsAuthenticated returns true if the principal represents a user.
+func (p Principal) IsAuthenticated() bool {
+	return p.ID != ""
+}
+
+// HasRole returns true if the principal has the role.
+func (p Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission returns true if the principal has the permission.
+func (p Principal) HasPermission(permission string) bool {
+	for _, perm := range p.Permissions {
+		if perm == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Is returns true if the principal is the user with this ID.
+func (p Principal) Is(userID string) bool {
+	return p.IsAuthenticated() && p.ID == userID
+}
@@ -0,0 +1,56 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/google/uuid"
+)
+
+// RefreshTokenSize is the number of random bytes of a refresh token.
+const RefreshTokenSize = 32
+
+// RefreshToken is used to get a new access token without authenticating again.
+// Only the token hash is stored. All the tokens obtained by rotation from the same login
+// share the same family ID, so that the whole family can be revoked when a token is reused.
+type RefreshToken struct {
+	ID        string     `json:"id" xml:"id" form:"id" gorm:"primaryKey;size:36"`
+	UserID    string     `json:"user_id" xml:"user_id" form:"user_id" gorm:"size:36;not null;index"`
+	FamilyID  string     `json:"family_id" xml:"family_id" form:"family_id" gorm:"size:36;not null;index"`
+	TokenHash string     `json:"-" xml:"-" form:"-" gorm:"size:64;not null;uniqueIndex"`
+	ExpiredAt time.Time  `json:"expired_at" xml:"expired_at" form:"expired_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at" xml:"used_at" form:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at" xml:"revoked_at" form:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at" xml:"created_at" form:"created_at" gorm:"not null;autoCreateTime"`
+}
+
+// NewRefreshToken returns a new refresh token for a user and its clear value.
+// If familyID is empty, a new family is created.
+func NewRefreshToken(userID, familyID string, lifetime time.Duration) (RefreshToken, string, error) {
+	token, err := utils.GenerateRandomToken(RefreshTokenSize)
+	if err != nil {
+		return RefreshToken{}, "", err
+	}
+
+	if familyID == "" {
+		familyID = uuid.NewString()
+	}
+
+	return RefreshToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashToken(token),
+		ExpiredAt: time.Now().Add(lifetime).UTC(),
+	}, token, nil
+}
+
+// IsExpired returns true if the refresh token has expired.
+func (rt *RefreshToken) IsExpired() bool {
+	return time.Now().After(rt.ExpiredAt)
+}
+
+// IsUsable returns true if the refresh token has neither been used nor revoked.
+func (rt *RefreshToken) IsUsable() bool {
+	return rt.UsedAt == nil && rt.RevokedAt == nil
+}
@@ -0,0 +1,10 @@
+package entities
+
+import "time"
+
+// RevokedToken is an access token revoked before its expiration (logout).
+// The ID is the jti claim of the token and the line can be removed once the token has expired.
+type RevokedToken struct {
+	ID        string    `json:"id" xml:"id" form:"id" gorm:"primaryKey;size:36"`
+	ExpiredAt time.Time `json:"expired_at" xml:"expired_at" form:"expired_at" gorm:"not null;index"`
+}
@@ -0,0 +1,54 @@
+package entities
+
+// Roles
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// Permissions
+const (
+	PermissionUsersRead   = "users:read"
+	PermissionUsersCreate = "users:create"
+	PermissionUsersUpdate = "users:update"
+	PermissionUsersDelete = "users:delete"
+	PermissionTasksRead   = "tasks:read"
+	PermissionTasksCreate = "tasks:create"
+	PermissionTasksUpdate = "tasks:update"
+	PermissionTasksDelete = "tasks:delete"
+	PermissionTasksAll    = "tasks:all" // Access to the tasks of all users
+)
+
+// DefaultRolesPermissions lists the roles created by default and their permissions.
+var DefaultRolesPermissions = map[string][]string{
+	RoleAdmin: {
+		PermissionUsersRead,
+		PermissionUsersCreate,
+		PermissionUsersUpdate,
+		PermissionUsersDelete,
+		PermissionTasksRead,
+		PermissionTasksCreate,
+		PermissionTasksUpdate,
+		PermissionTasksDelete,
+		PermissionTasksAll,
+	},
+	RoleUser: {
+		PermissionTasksRead,
+		PermissionTasksCreate,
+		PermissionTasksUpdate,
+		PermissionTasksDelete,
+	},
+}
+
+// Role represents a role in database.
+type Role struct {
+	Name        string       `json:"name" xml:"name" form:"name" gorm:"primaryKey;size:63" validate:"required"`
+	Description string       `json:"description" xml:"description" form:"description" gorm:"size:255"`
+	Permissions []Permission `json:"permissions,omitempty" xml:"permissions,omitempty" form:"permissions" gorm:"many2many:role_permissions;constraint:OnDelete:CASCADE"`
+}
+
+// Permission represents a permission in database.
+type Permission struct {
+	Name        string `json:"name" xml:"name" form:"name" gorm:"primaryKey;size:63" validate:"required"`
+	Description string `json:"description" xml:"description" form:"description" gorm:"size:255"`
+}
@@ -6,12 +6,63 @@ import (
 	"gorm.io/gorm"
 )
 
+// Task states
+const (
+	TaskStateTodo       = "todo"
+	TaskStateInProgress = "in_progress"
+	TaskStateBlocked    = "blocked"
+	TaskStateDone       = "done"
+	TaskStateArchived   = "archived"
+)
+
+// TaskStates lists the task states in their lifecycle order.
+var TaskStates = []string{TaskStateTodo, TaskStateInProgress, TaskStateBlocked, TaskStateDone, TaskStateArchived}
+
+// TaskTransitions lists for each state the states a task can go to.
+// An archived task cannot change state anymore.
+var TaskTransitions = map[string][]string{
+	TaskStateTodo:       {TaskStateInProgress, TaskStateArchived},
+	TaskStateInProgress: {TaskStateTodo, TaskStateBlocked, TaskStateDone},
+	TaskStateBlocked:    {TaskStateTodo, TaskStateInProgress},
+	TaskStateDone:       {TaskStateInProgress, TaskStateArchived},
+	TaskStateArchived:   {},
+}
+
 // Task represents a task in database.
 type Task struct {
-	ID          string         `json:"id" xml:"id" form:"id" gorm:"primaryKey"`
-	Name        string         `json:"name" xml:"name" form:"not null;name" gorm:"size:127" validate:"required,min=3,max=127"`
-	Description string         `json:"description" xml:"description" form:"description" gorm:"size:127"`
-	CreatedAt   time.Time      `json:"created_at" xml:"created_at" form:"created_at" gorm:"not null;autoCreateTime"`
-	UpdatedAt   time.Time      `json:"updated_at" xml:"updated_at" form:"updated_at" gorm:"not null;autoUpdateTime"`
-	DeletedAt   gorm.DeletedAt `json:"-" xml:"-" form:"deleted_at" gorm:"index"`
+	ID          string           `json:"id" xml:"id" form:"id" gorm:"primaryKey"`
+	Name        string           `json:"name" xml:"name" form:"not null;name" gorm:"size:127" validate:"required,min=3,max=127"`
+	Description string           `json:"description" xml:"description" form:"description" gorm:"size:255"`
+	State       string           `json:"state" xml:"state" form:"state" gorm:"size:31;not null;default:todo;index"`
+	UserID      *string          `json:"user_id" xml:"user_id" form:"user_id" gorm:"index"` // Owner, NULL for tasks created before ownership
+	CreatedAt   time.Time        `json:"created_at" xml:"created_at" form:"created_at" gorm:"not null;autoCreateTime"`
+	UpdatedAt   time.Time        `json:"updated_at" xml:"updated_at" form:"updated_at" gorm:"not null;autoUpdateTime"`
+	DeletedAt   gorm.DeletedAt   `json:"-" xml:"-" form:"deleted_at" gorm:"index"`
+	Transitions []TaskTransition `json:"transitions,omitempty" xml:"transitions,omitempty" form:"transitions" gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// CanTransitionTo returns true if the task can go from its current state to the new state.
+func (t Task) CanTransitionTo(state string) bool {
+	for _, s := range TaskTransitions[t.State] {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidTaskState returns true if the state exists.
+func IsValidTaskState(state string) bool {
+	_, ok := TaskTransitions[state]
+	return ok
+}
+
+// TaskTransition represents a change of state of a task in database.
+type TaskTransition struct {
+	ID        string    `json:"id" xml:"id" form:"id" gorm:"primaryKey;size:36"`
+	TaskID    string    `json:"task_id" xml:"task_id" form:"task_id" gorm:"size:191;not null;index"`
+	FromState string    `json:"from_state" xml:"from_state" form:"from_state" gorm:"size:31;not null"`
+	ToState   string    `json:"to_state" xml:"to_state" form:"to_state" gorm:"size:31;not null"`
+	UserID    *string   `json:"user_id" xml:"user_id" form:"user_id" gorm:"index"` // User who made the transition
+	CreatedAt time.Time `json:"created_at" xml:"created_at" form:"created_at" gorm:"not null;autoCreateTime"`
 }
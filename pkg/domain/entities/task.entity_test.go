@@ -0,0 +1,39 @@
+package entities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		name   string
+		from   string
+		to     string
+		wanted bool
+	}{
+		{"Todo to in progress", TaskStateTodo, TaskStateInProgress, true},
+		{"Todo to done", TaskStateTodo, TaskStateDone, false},
+		{"In progress to blocked", TaskStateInProgress, TaskStateBlocked, true},
+		{"Blocked to done", TaskStateBlocked, TaskStateDone, false},
+		{"Done to archived", TaskStateDone, TaskStateArchived, true},
+		{"Archived to todo", TaskStateArchived, TaskStateTodo, false},
+		{"Same state", TaskStateTodo, TaskStateTodo, false},
+		{"Unknown state", TaskStateTodo, "unknown", false},
+		{"From unknown state", "unknown", TaskStateTodo, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := Task{State: tt.from}
+			assert.Equal(t, tt.wanted, task.CanTransitionTo(tt.to))
+		})
+	}
+}
+
+func TestIsValidTaskState(t *testing.T) {
+	assert.True(t, IsValidTaskState(TaskStateBlocked))
+	assert.False(t, IsValidTaskState("unknown"))
+	assert.False(t, IsValidTaskState(""))
+}
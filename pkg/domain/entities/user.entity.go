@@ -3,7 +3,9 @@ package entities
 import (
 	"time"
 
+	values_objects "github.com/fabienbellanger/fiber-boilerplate/pkg/domain/value_objects"
 	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/google/uuid"
 	"github.com/spf13/viper"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -12,25 +14,108 @@ import (
 
 // User represents a user in database.
 type User struct {
-	ID            string         `json:"id" xml:"id" form:"id" gorm:"primaryKey" validate:"required,uuid"`
-	Username      string         `json:"username" xml:"username" form:"username" gorm:"not null;unique;size:127" validate:"required,email"`
-	Password      string         `json:"-" xml:"-" form:"password" gorm:"not null;index;size:128" validate:"required,min=8"` // SHA512
-	Lastname      string         `json:"lastname" xml:"lastname" form:"lastname" gorm:"size:63" validate:"required"`
-	Firstname     string         `json:"firstname" xml:"firstname" form:"firstname" gorm:"size:63" validate:"required"`
-	CreatedAt     time.Time      `json:"created_at" xml:"created_at" form:"created_at" gorm:"not null;autoCreateTime"`
-	UpdatedAt     time.Time      `json:"updated_at" xml:"updated_at" form:"updated_at" gorm:"not null;autoUpdateTime"`
-	DeletedAt     gorm.DeletedAt `json:"-" xml:"-" form:"deleted_at" gorm:"index"`
-	PasswordReset PasswordResets `json:"-" xml:"-" form:"-" gorm:"constraint:OnDelete:CASCADE"`
+	ID                string               `json:"id" xml:"id" form:"id" gorm:"primaryKey" validate:"required,uuid"`
+	Username          values_objects.Email `json:"username" xml:"username" form:"username" gorm:"not null;unique;size:127" validate:"required,email"` // Normalized email
+	Password          string               `json:"-" xml:"-" form:"password" gorm:"not null;size:255" validate:"required"`                            // Argon2id or bcrypt encoded hash
+	Lastname          string               `json:"lastname" xml:"lastname" form:"lastname" gorm:"size:63" validate:"required"`
+	Firstname         string               `json:"firstname" xml:"firstname" form:"firstname" gorm:"size:63" validate:"required"`
+	TokenVersion      uint                 `json:"-" xml:"-" form:"-" gorm:"not null;default:0"`                       // Incremented to invalidate all access tokens
+	EmailVerifiedAt   *time.Time           `json:"email_verified_at" xml:"email_verified_at" form:"email_verified_at"` // Nil until the user proves the ownership of its email
+	CreatedAt         time.Time            `json:"created_at" xml:"created_at" form:"created_at" gorm:"not null;autoCreateTime"`
+	UpdatedAt         time.Time            `json:"updated_at" xml:"updated_at" form:"updated_at" gorm:"not null;autoUpdateTime"`
+	DeletedAt         gorm.DeletedAt       `json:"-" xml:"-" form:"deleted_at" gorm:"index"`
+	Roles             []Role               `json:"roles,omitempty" xml:"roles,omitempty" form:"roles" gorm:"many2many:user_roles;constraint:OnDelete:CASCADE"`
+	PasswordReset     PasswordResets       `json:"-" xml:"-" form:"-" gorm:"constraint:OnDelete:CASCADE"`
+	EmailVerification EmailVerification    `json:"-" xml:"-" form:"-" gorm:"constraint:OnDelete:CASCADE"`
+	RefreshTokens     []RefreshToken       `json:"-" xml:"-" form:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Tasks             []Task               `json:"-" xml:"-" form:"-" gorm:"constraint:OnDelete:SET NULL"`
 }
 
+// PasswordResetTokenSize is the number of random bytes of a password reset token.
+const PasswordResetTokenSize = 32
+
 // PasswordResets is used to reset user password.
+// Only the token hash is stored and the number of uses of the token is counted.
 type PasswordResets struct {
 	UserID    string    `json:"user_id" xml:"user_id" form:"user_id" gorm:"primaryKey" validate:"required,uuid"`
-	Token     string    `json:"token" xml:"token" form:"token" gorm:"size:36;not null" validate:"required,uuid"`
+	TokenHash string    `json:"-" xml:"-" form:"-" gorm:"size:64;not null;uniqueIndex"`
+	Attempts  uint      `json:"-" xml:"-" form:"-" gorm:"not null;default:0"`
 	ExpiredAt time.Time `json:"expired_at" xml:"expired_at" gorm:"not null" form:"expired_at"`
 }
 
-// GenerateJWT returns a token
+// NewPasswordReset returns a new password reset for a user and its clear token.
+func NewPasswordReset(userID string, lifetime time.Duration) (PasswordResets, string, error) {
+	token, err := utils.GenerateRandomToken(PasswordResetTokenSize)
+	if err != nil {
+		return PasswordResets{}, "", err
+	}
+
+	return PasswordResets{
+		UserID:    userID,
+		TokenHash: utils.HashToken(token),
+		ExpiredAt: time.Now().Add(lifetime).UTC(),
+	}, token, nil
+}
+
+// EmailVerificationTokenSize is the number of random bytes of an email verification token.
+const EmailVerificationTokenSize = 32
+
+// EmailVerification is used to verify the ownership of a user email.
+// Email is the address to verify: the current username on registration or the new one on email change.
+// Only the token hash is stored.
+type EmailVerification struct {
+	UserID    string               `json:"user_id" xml:"user_id" form:"user_id" gorm:"primaryKey" validate:"required,uuid"`
+	Email     values_objects.Email `json:"email" xml:"email" form:"email" gorm:"size:127;not null" validate:"required,email"`
+	TokenHash string               `json:"-" xml:"-" form:"-" gorm:"size:64;not null;uniqueIndex"`
+	ExpiredAt time.Time            `json:"expired_at" xml:"expired_at" gorm:"not null" form:"expired_at"`
+}
+
+// NewEmailVerification returns a new verification of an email for a user and its clear token.
+func NewEmailVerification(userID string, email values_objects.Email, lifetime time.Duration) (EmailVerification, string, error) {
+	token, err := utils.GenerateRandomToken(EmailVerificationTokenSize)
+	if err != nil {
+		return EmailVerification{}, "", err
+	}
+
+	return EmailVerification{
+		UserID:    userID,
+		Email:     email,
+		TokenHash: utils.HashToken(token),
+		ExpiredAt: time.Now().Add(lifetime).UTC(),
+	}, token, nil
+}
+
+// IsEmailVerified returns true if the user has verified its email.
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// RoleNames returns the names of the user roles.
+func (u *User) RoleNames() []string {
+	names := make([]string, 0, len(u.Roles))
+	for _, r := range u.Roles {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+// PermissionNames returns the names of the permissions granted by the user roles.
+// Roles must have been loaded with their permissions.
+func (u *User) PermissionNames() []string {
+	names := make([]string, 0)
+	found := make(map[string]bool)
+	for _, r := range u.Roles {
+		for _, p := range r.Permissions {
+			if !found[p.Name] {
+				found[p.Name] = true
+				names = append(names, p.Name)
+			}
+		}
+	}
+	return names
+}
+
+// GenerateJWT returns a token valid for lifetime
 func (u *User) GenerateJWT(lifetime time.Duration, algo, secret string) (string, time.Time, error) {
 	// Create token and key
 	token, key, err := utils.GetTokenAndKeyFromAlgo(algo, secret, viper.GetString("JWT_PRIVATE_KEY_PATH"))
@@ -40,7 +125,7 @@ func (u *User) GenerateJWT(lifetime time.Duration, algo, secret string) (string,
 
 	// Expiration time
 	now := time.Now()
-	expiresAt := now.Add(time.Hour * lifetime)
+	expiresAt := now.Add(lifetime)
 
 	// Set claims
 	claims := token.Claims.(jwt.MapClaims)
@@ -49,6 +134,10 @@ func (u *User) GenerateJWT(lifetime time.Duration, algo, secret string) (string,
 	claims["lastname"] = u.Lastname
 	claims["firstname"] = u.Firstname
 	claims["createdAt"] = u.CreatedAt
+	claims["roles"] = u.RoleNames()
+	claims["permissions"] = u.PermissionNames()
+	claims["tokenVersion"] = u.TokenVersion
+	claims["jti"] = uuid.NewString()
 	claims["exp"] = expiresAt.Unix()
 	claims["iat"] = now.Unix()
 	claims["nbf"] = now.Unix()
@@ -22,7 +22,7 @@ func TestGenerateJWT(t *testing.T) {
 		err       error
 	}
 
-	lifetime := time.Duration(2)
+	lifetime := 2 * time.Hour
 
 	tests := []struct {
 		name   string
@@ -67,7 +67,7 @@ func TestGenerateJWT(t *testing.T) {
 			},
 			wanted: result{
 				token:     "",
-				expiredAt: time.Now().Add(lifetime * time.Hour),
+				expiredAt: time.Now().Add(lifetime),
 				err:       nil,
 			},
 		},
@@ -86,8 +86,8 @@ func TestGenerateJWT(t *testing.T) {
 				assert.Equal(t, got.token, tt.wanted.token)
 			} else {
 				assert.Greater(t, len(got.token), 0)
-				assert.Greater(t, got.expiredAt, time.Now().Add(lifetime*time.Hour-time.Minute))
-				assert.Less(t, got.expiredAt, time.Now().Add(lifetime*time.Hour+time.Minute))
+				assert.Greater(t, got.expiredAt, time.Now().Add(lifetime-time.Minute))
+				assert.Less(t, got.expiredAt, time.Now().Add(lifetime+time.Minute))
 			}
 			assert.Equal(t, got.err, tt.wanted.err)
 		})
@@ -0,0 +1,100 @@
+package errs
+
+// Kind represents the kind of a domain error.
+type Kind int
+
+// Kinds of domain errors
+const (
+	KindInternal Kind = iota
+	KindValidation
+	KindUnauthorized
+	KindForbidden
+	KindNotFound
+	KindConflict
+)
+
+// String returns the name of the kind.
+func (k Kind) String() string {
+	switch k {
+	case KindValidation:
+		return "validation"
+	case KindUnauthorized:
+		return "unauthorized"
+	case KindForbidden:
+		return "forbidden"
+	case KindNotFound:
+		return "not found"
+	case KindConflict:
+		return "conflict"
+	default:
+		return "internal"
+	}
+}
+
+// Error represents a domain error.
+type Error struct {
+	Kind    Kind
+	Message string      // Message for the client
+	Details interface{} // Details for the client or, for internal errors, a description for the logs
+	Err     error       // Cause of the error
+}
+
+// Error returns the message of the error and its cause.
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the cause of the error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is a domain error of the same kind.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Message == "" && t.Kind == e.Kind
+}
+
+// Internal returns an unexpected error (database, email, etc.).
+// The description is only logged.
+func Internal(message, description string, err error) *Error {
+	return &Error{Kind: KindInternal, Message: message, Details: description, Err: err}
+}
+
+// Validation returns an error for invalid input data.
+func Validation(message string, details interface{}) *Error {
+	return &Error{Kind: KindValidation, Message: message, Details: details}
+}
+
+// Unauthorized returns an authentication error.
+func Unauthorized() *Error {
+	return &Error{Kind: KindUnauthorized, Message: "Unauthorized"}
+}
+
+// Forbidden returns an error when the principal is not allowed to perform an action.
+func Forbidden() *Error {
+	return &Error{Kind: KindForbidden, Message: "Forbidden"}
+}
+
+// NotFound returns an error when a resource does not exist.
+func NotFound(message string) *Error {
+	return &Error{Kind: KindNotFound, Message: message}
+}
+
+// Conflict returns an error when an action conflicts with the current state of a resource.
+func Conflict(message string, details interface{}) *Error {
+	return &Error{Kind: KindConflict, Message: message, Details: details}
+}
+
+// Sentinel errors to check the kind of an error with errors.Is
+var (
+	ErrInternal     = &Error{Kind: KindInternal}
+	ErrValidation   = &Error{Kind: KindValidation}
+	ErrUnauthorized = &Error{Kind: KindUnauthorized}
+	ErrForbidden    = &Error{Kind: KindForbidden}
+	ErrNotFound     = &Error{Kind: KindNotFound}
+	ErrConflict     = &Error{Kind: KindConflict}
+)
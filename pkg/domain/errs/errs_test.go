@@ -0,0 +1,24 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := Internal("Database error", "Error when getting user by id", cause)
+
+	assert.Equal(t, "Database error: connection refused", err.Error())
+	assert.True(t, errors.Is(err, cause))
+	assert.True(t, errors.Is(err, ErrInternal))
+	assert.False(t, errors.Is(err, ErrNotFound))
+
+	var e *Error
+	assert.True(t, errors.As(fmt.Errorf("wrapped: %w", NotFound("No user found")), &e))
+	assert.Equal(t, KindNotFound, e.Kind)
+	assert.Equal(t, "No user found", e.Error())
+}
@@ -1,14 +1,23 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/responses"
 )
 
 // TaskRepository is the interface that wraps the basic task repository methods.
 type TaskRepository interface {
-	GetAll(page, limit, sorts string) ([]entities.Task, int64, error)
-	GetAllRows() (*sql.Rows, error)
-	Create(task *entities.Task) error
-	ScanRow(rows *sql.Rows, task *entities.Task) error
+	GetAll(ctx context.Context, userID string, states []string, pagination requests.Pagination) ([]entities.Task, responses.Pagination, error)
+	GetAllRows(ctx context.Context, userID string) (*sql.Rows, error)
+	GetByID(ctx context.Context, id string) (entities.Task, error)
+	GetDeletedByID(ctx context.Context, id string) (entities.Task, error)
+	Create(ctx context.Context, task *entities.Task) error
+	Update(ctx context.Context, task *entities.Task) error
+	Delete(ctx context.Context, id string) error
+	Restore(ctx context.Context, id string) error
+	Transition(ctx context.Context, transition *entities.TaskTransition) (bool, error)
+	ScanRow(ctx context.Context, rows *sql.Rows, task *entities.Task) error
 }
@@ -0,0 +1,16 @@
+package repositories
+
+import "context"
+
+// Repositories groups the repositories available in a unit of work.
+type Repositories struct {
+	Users UserRepository
+	Tasks TaskRepository
+}
+
+// TxManager is the interface that wraps the unit of work method.
+type TxManager interface {
+	// WithinTx runs fn with repositories sharing the same transaction.
+	// The transaction is committed if fn returns nil and rolled back otherwise.
+	WithinTx(ctx context.Context, fn func(repos Repositories) error) error
+}
@@ -1,20 +1,39 @@
 package repositories
 
 import (
+	"context"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/responses"
 )
 
 // UserRepository is the interface that wraps the basic user repository methods.
 type UserRepository interface {
-	Login(username, password string) (entities.User, error)
-	Create(user *entities.User) error
-	GetAll(page, limit, sorts string) (users []entities.User, total int64, err error)
-	GetByID(id string) (entities.User, error)
-	GetByUsername(username string) (entities.User, error)
-	Delete(id string) error
-	Update(user *entities.User) error
-	UpdatePassword(id, currentPassword, password string) error
-	GetIDFromPasswordReset(token, password string) (string, string, error)
-	DeletePasswordReset(userId string) error
-	CreateOrUpdatePasswordReset(passwordReset entities.PasswordResets) error
+	Login(ctx context.Context, username, password string) (entities.User, error)
+	Create(ctx context.Context, user *entities.User) error
+	GetAll(ctx context.Context, pagination requests.Pagination) ([]entities.User, responses.Pagination, error)
+	GetByID(ctx context.Context, id string) (entities.User, error)
+	GetByUsername(ctx context.Context, username string) (entities.User, error)
+	Delete(ctx context.Context, id string) error
+	Update(ctx context.Context, user *entities.User) error
+	UpdateProfile(ctx context.Context, user *entities.User) error
+	UpdatePassword(ctx context.Context, id, currentPassword, password string) error
+	GetIDFromPasswordReset(ctx context.Context, tokenHash, password string) (string, string, error)
+	IncrementPasswordResetAttempts(ctx context.Context, tokenHash string) (uint, error)
+	ConsumePasswordReset(ctx context.Context, tokenHash string) error
+	DeletePasswordReset(ctx context.Context, userId string) error
+	CreateOrUpdatePasswordReset(ctx context.Context, passwordReset entities.PasswordResets) error
+	GetEmailVerification(ctx context.Context, tokenHash string) (entities.EmailVerification, error)
+	DeleteEmailVerification(ctx context.Context, userID string) error
+	CreateOrUpdateEmailVerification(ctx context.Context, emailVerification entities.EmailVerification) error
+	VerifyEmail(ctx context.Context, userID, email string) error
+	CreateRefreshToken(ctx context.Context, refreshToken *entities.RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (entities.RefreshToken, error)
+	UseRefreshToken(ctx context.Context, id string) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID string) error
+	RevokeToken(ctx context.Context, revokedToken entities.RevokedToken) error
+	IsTokenRevoked(ctx context.Context, id string) (bool, error)
+	IncrementTokenVersion(ctx context.Context, userID string) error
+	AssignRoles(ctx context.Context, userID string, roles ...string) error
 }
@@ -0,0 +1,105 @@
+package requests
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+)
+
+// FilterOperator is a comparison operator of a filter.
+type FilterOperator string
+
+// Filter operators
+const (
+	FilterEq         FilterOperator = "eq"
+	FilterNe         FilterOperator = "ne"
+	FilterGt         FilterOperator = "gt"
+	FilterGte        FilterOperator = "gte"
+	FilterLt         FilterOperator = "lt"
+	FilterLte        FilterOperator = "lte"
+	FilterContains   FilterOperator = "contains"
+	FilterStartsWith FilterOperator = "starts_with"
+	FilterIn         FilterOperator = "in"
+)
+
+// filterOperators lists the known operators.
+var filterOperators = map[FilterOperator]bool{
+	FilterEq:         true,
+	FilterNe:         true,
+	FilterGt:         true,
+	FilterGte:        true,
+	FilterLt:         true,
+	FilterLte:        true,
+	FilterContains:   true,
+	FilterStartsWith: true,
+	FilterIn:         true,
+}
+
+// filterKeyRegexp matches filter[<field>] and filter[<field>][<operator>] query parameters.
+var filterKeyRegexp = regexp.MustCompile(`^filter\[([a-zA-Z0-9_]+)\](?:\[([a-z_]+)\])?$`)
+
+// Filter is a condition on a field.
+// Query parameter: filter[<field>][<operator>]=<value>, the default operator is eq.
+type Filter struct {
+	Field    string
+	Operator FilterOperator
+	Values   []string // Comma separated values for the in operator, one value otherwise
+}
+
+// Filters is a list of conditions which must all be true.
+type Filters []Filter
+
+// ParseFilters returns the filters from the query parameters.
+// Other parameters are ignored. Filters are sorted by field and operator.
+// Example: filter[name][contains]=foo&filter[created_at][gte]=2024-01-01
+func ParseFilters(queries map[string]string) (Filters, utils.ValidatorErrors) {
+	filters := make(Filters, 0)
+	var errs utils.ValidatorErrors
+
+	for key, value := range queries {
+		if !strings.HasPrefix(key, "filter[") {
+			continue
+		}
+
+		matches := filterKeyRegexp.FindStringSubmatch(key)
+		if matches == nil {
+			errs = append(errs, utils.ValidatorError{FailedField: key, Tag: "filter"})
+			continue
+		}
+
+		operator := FilterEq
+		if matches[2] != "" {
+			operator = FilterOperator(matches[2])
+		}
+		if !filterOperators[operator] {
+			errs = append(errs, utils.ValidatorError{FailedField: key, Tag: "operator", Value: string(operator)})
+			continue
+		}
+
+		values := []string{value}
+		if operator == FilterIn {
+			values = strings.Split(value, ",")
+		}
+
+		filters = append(filters, Filter{
+			Field:    matches[1],
+			Operator: operator,
+			Values:   values,
+		})
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	sort.Slice(filters, func(i, j int) bool {
+		if filters[i].Field == filters[j].Field {
+			return filters[i].Operator < filters[j].Operator
+		}
+		return filters[i].Field < filters[j].Field
+	})
+
+	return filters, nil
+}
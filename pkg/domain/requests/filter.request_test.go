@@ -0,0 +1,64 @@
+package requests
+
+import (
+	"testing"
+
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFilters(t *testing.T) {
+	type result struct {
+		filters Filters
+		errs    utils.ValidatorErrors
+	}
+
+	tests := []struct {
+		name    string
+		queries map[string]string
+		wanted  result
+	}{
+		{
+			name:    "No filters",
+			queries: map[string]string{"p": "1", "s": "+name"},
+			wanted:  result{filters: Filters{}},
+		},
+		{
+			name: "Many filters",
+			queries: map[string]string{
+				"filter[name][contains]":  "foo",
+				"filter[created_at][gte]": "2024-01-01",
+				"filter[state]":           "todo",
+				"filter[state][in]":       "todo,done",
+			},
+			wanted: result{filters: Filters{
+				{Field: "created_at", Operator: FilterGte, Values: []string{"2024-01-01"}},
+				{Field: "name", Operator: FilterContains, Values: []string{"foo"}},
+				{Field: "state", Operator: FilterEq, Values: []string{"todo"}},
+				{Field: "state", Operator: FilterIn, Values: []string{"todo", "done"}},
+			}},
+		},
+		{
+			name:    "Unknown operator",
+			queries: map[string]string{"filter[name][like]": "foo"},
+			wanted:  result{errs: utils.ValidatorErrors{{FailedField: "filter[name][like]", Tag: "operator", Value: "like"}}},
+		},
+		{
+			name:    "Invalid syntax",
+			queries: map[string]string{"filter[name][contains][0]": "foo"},
+			wanted:  result{errs: utils.ValidatorErrors{{FailedField: "filter[name][contains][0]", Tag: "filter"}}},
+		},
+		{
+			name:    "Invalid field name",
+			queries: map[string]string{"filter[name;--]": "foo"},
+			wanted:  result{errs: utils.ValidatorErrors{{FailedField: "filter[name;--]", Tag: "filter"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters, errs := ParseFilters(tt.queries)
+			assert.Equal(t, tt.wanted, result{filters, errs})
+		})
+	}
+}
@@ -2,7 +2,10 @@ package requests
 
 // Pagination request
 type Pagination struct {
-	Page  string `query:"p"`
-	Limit string `query:"l"`
-	Sorts string `query:"s"`
+	Page    string  `query:"p"`
+	Limit   string  `query:"l"`
+	Sorts   string  `query:"s"`
+	Cursor  string  `query:"cursor"` // Cursor returned by a previous list (next_cursor or prev_cursor), p is ignored if set
+	Total   bool    `query:"total"`  // Count the total number of items
+	Filters Filters `query:"-"`      // Parsed from filter[<field>][<operator>] parameters
 }
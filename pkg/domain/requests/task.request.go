@@ -1,7 +1,65 @@
 package requests
 
+import (
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	err := utils.RegisterValidation("task_state", func(fl validator.FieldLevel) bool {
+		return entities.IsValidTaskState(fl.Field().String())
+	}, map[string]string{
+		"en": "{field} must be a valid task state",
+		"fr": "{field} doit être un état de tâche valide",
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
 // TaskCreation request to create a task
 type TaskCreation struct {
 	Name        string `json:"name" xml:"name" form:"name" validate:"required,min=3,max=127"`
 	Description string `json:"description" xml:"description" form:"description"`
 }
+
+// TaskByID request
+type TaskByID struct {
+	ID string `json:"id" xml:"id" form:"id" validate:"required,uuid"`
+}
+
+// TaskUpdate request to update a task
+type TaskUpdate struct {
+	ID          string `json:"id" xml:"id" form:"id" validate:"required,uuid"`
+	Name        string `json:"name" xml:"name" form:"name" validate:"required,min=3,max=127"`
+	Description string `json:"description" xml:"description" form:"description"`
+}
+
+// TaskPatch request to partially update a task, nil fields are not updated
+type TaskPatch struct {
+	ID          string  `json:"id" xml:"id" form:"id" validate:"required,uuid"`
+	Name        *string `json:"name" xml:"name" form:"name" validate:"omitempty,min=3,max=127"`
+	Description *string `json:"description" xml:"description" form:"description"`
+}
+
+// TaskTransition request to change the state of a task
+type TaskTransition struct {
+	ID    string `json:"id" xml:"id" form:"id" validate:"required,uuid"`
+	State string `json:"state" xml:"state" form:"state" validate:"required,task_state"`
+}
+
+// TaskOwnerAll is the owner filter value to list the tasks of all users
+const TaskOwnerAll = "all"
+
+// TaskOwner request to choose the owner of the listed tasks
+type TaskOwner struct {
+	Owner string `query:"owner"` // User ID or "all" (Default: authenticated user)
+}
+
+// TaskList request to list tasks
+type TaskList struct {
+	Pagination
+	TaskOwner
+	States []string `query:"state"` // Ex.: state=todo,in_progress or state=todo&state=in_progress
+}
@@ -1,9 +1,61 @@
 package requests
 
+import (
+	values_objects "github.com/fabienbellanger/fiber-boilerplate/pkg/domain/value_objects"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	utils.RegisterStructValidation(func(sl validator.StructLevel) {
+		req := sl.Current().Interface().(UserCreation)
+		validatePassword(sl, req.Password, req.Username.String(), req.Lastname, req.Firstname)
+	}, UserCreation{})
+	utils.RegisterStructValidation(func(sl validator.StructLevel) {
+		req := sl.Current().Interface().(UserUpdate)
+		validatePassword(sl, req.Password, req.Username.String(), req.Lastname, req.Firstname)
+	}, UserUpdate{})
+	utils.RegisterStructValidation(func(sl validator.StructLevel) {
+		validatePassword(sl, sl.Current().Interface().(UserPasswordUpdate).Password)
+	}, UserPasswordUpdate{})
+	utils.RegisterStructValidation(func(sl validator.StructLevel) {
+		validatePassword(sl, sl.Current().Interface().(UserMePasswordUpdate).Password)
+	}, UserMePasswordUpdate{})
+}
+
+// validatePassword reports the password policy errors of the password field.
+// An empty password is already reported by the required tag.
+func validatePassword(sl validator.StructLevel, password values_objects.Password, personal ...string) {
+	if password == "" {
+		return
+	}
+
+	for _, e := range values_objects.CurrentPasswordPolicy().Validate("password", password.String(), personal...) {
+		sl.ReportError(password, "password", "Password", e.Tag, e.Value)
+	}
+}
+
 // UserLogin request
 type UserLogin struct {
-	Username string `json:"username" xml:"username" form:"username" validate:"required,email"`
-	Password string `json:"password" xml:"password" form:"password" validate:"required,min=8"`
+	Username values_objects.Email `json:"username" xml:"username" form:"username" validate:"required,email"`
+	Password string               `json:"password" xml:"password" form:"password" validate:"required"`
+}
+
+// TokenRefresh request to get a new access token
+type TokenRefresh struct {
+	RefreshToken string `json:"refresh_token" xml:"refresh_token" form:"refresh_token" validate:"required"`
+}
+
+// UserLogout request to revoke the current access token and optionally a refresh token family
+type UserLogout struct {
+	RefreshToken string `json:"refresh_token" xml:"refresh_token" form:"refresh_token"`
+}
+
+// UserToken request to check that an access token has not been revoked
+type UserToken struct {
+	UserID       string `validate:"required,uuid"`
+	TokenID      string `validate:"required"`
+	TokenVersion uint
 }
 
 // UserByID request
@@ -11,30 +63,54 @@ type UserByID struct {
 	ID string `json:"id" xml:"id" form:"id" validate:"required,uuid"`
 }
 
-// UserCreation request to create a user
+// UserCreation request to create a user.
+// The password must respect the password policy and must not contain the username, lastname or firstname.
 type UserCreation struct {
-	Username  string `json:"username" xml:"username" form:"username" validate:"required,email"`
-	Password  string `json:"password" xml:"password" form:"password" validate:"required,min=8"`
-	Lastname  string `json:"lastname" xml:"lastname" form:"lastname" validate:"required"`
-	Firstname string `json:"firstname" xml:"firstname" form:"firstname" validate:"required"`
+	Username  values_objects.Email    `json:"username" xml:"username" form:"username" validate:"required,email"`
+	Password  values_objects.Password `json:"password" xml:"password" form:"password" validate:"required"`
+	Lastname  string                  `json:"lastname" xml:"lastname" form:"lastname" validate:"required"`
+	Firstname string                  `json:"firstname" xml:"firstname" form:"firstname" validate:"required"`
 }
 
-// UserUpdate request to update a user
+// UserUpdate request to update a user.
+// The password must respect the password policy and must not contain the username, lastname or firstname.
 type UserUpdate struct {
-	ID        string `json:"id" xml:"id" form:"id" validate:"required,uuid"`
-	Username  string `json:"username" xml:"username" form:"username" validate:"required,email"`
-	Password  string `json:"password" xml:"password" form:"password" validate:"required,min=8"`
-	Lastname  string `json:"lastname" xml:"lastname" form:"lastname" validate:"required"`
-	Firstname string `json:"firstname" xml:"firstname" form:"firstname" validate:"required"`
+	ID        string                  `json:"id" xml:"id" form:"id" validate:"required,uuid"`
+	Username  values_objects.Email    `json:"username" xml:"username" form:"username" validate:"required,email"`
+	Password  values_objects.Password `json:"password" xml:"password" form:"password" validate:"required"`
+	Lastname  string                  `json:"lastname" xml:"lastname" form:"lastname" validate:"required"`
+	Firstname string                  `json:"firstname" xml:"firstname" form:"firstname" validate:"required"`
 }
 
 // UserPasswordUpdate request to update a user password
 type UserPasswordUpdate struct {
-	Token    string `json:"token" xml:"token" form:"token" validate:"required"`
-	Password string `json:"password" xml:"password" form:"password" validate:"required,min=8"`
+	Token    string                  `json:"token" xml:"token" form:"token" validate:"required"`
+	Password values_objects.Password `json:"password" xml:"password" form:"password" validate:"required"`
+}
+
+// UserEmailVerification request to verify a user email
+type UserEmailVerification struct {
+	Token string `json:"token" xml:"token" form:"token" validate:"required"`
 }
 
 // UserForgotPassword request to reset user password
 type UserForgotPassword struct {
-	Email string `json:"email" xml:"email" form:"email" validate:"required,email"`
+	Email values_objects.Email `json:"email" xml:"email" form:"email" validate:"required,email"`
+}
+
+// UserProfileUpdate request to partially update the profile of the authenticated user, nil fields are not updated
+type UserProfileUpdate struct {
+	Lastname  *string `json:"lastname" xml:"lastname" form:"lastname" validate:"omitnil,min=1,max=63"`
+	Firstname *string `json:"firstname" xml:"firstname" form:"firstname" validate:"omitnil,min=1,max=63"`
+}
+
+// UserMePasswordUpdate request to change the password of the authenticated user
+type UserMePasswordUpdate struct {
+	CurrentPassword string                  `json:"current_password" xml:"current_password" form:"current_password" validate:"required"`
+	Password        values_objects.Password `json:"password" xml:"password" form:"password" validate:"required"`
+}
+
+// UserMeDeletion request to delete the authenticated user account, confirmed by the user password
+type UserMeDeletion struct {
+	Password string `json:"password" xml:"password" form:"password" validate:"required"`
 }
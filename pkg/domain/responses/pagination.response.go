@@ -0,0 +1,8 @@
+package responses
+
+// Pagination response
+type Pagination struct {
+	Total      *int64 `json:"total,omitempty"`       // Only returned if requested
+	NextCursor string `json:"next_cursor,omitempty"` // Empty if there is no next page
+	PrevCursor string `json:"prev_cursor,omitempty"` // Empty if there is no previous page
+}
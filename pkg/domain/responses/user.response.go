@@ -5,12 +5,14 @@ import "github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
 // UserLogin response
 type UserLogin struct {
 	entities.User
-	Token     string `json:"token" xml:"token" form:"token"`
-	ExpiresAt string `json:"expires_at" xml:"expires_at" form:"expires_at"`
+	Token                 string `json:"token" xml:"token" form:"token"`
+	ExpiresAt             string `json:"expires_at" xml:"expires_at" form:"expires_at"`
+	RefreshToken          string `json:"refresh_token" xml:"refresh_token" form:"refresh_token"`
+	RefreshTokenExpiresAt string `json:"refresh_token_expires_at" xml:"refresh_token_expires_at" form:"refresh_token_expires_at"`
 }
 
 // UsersListPaginated response
 type UsersListPaginated struct {
-	Data  []entities.User `json:"data"`
-	Total int64           `json:"total"`
+	Data []entities.User `json:"data"`
+	Pagination
 }
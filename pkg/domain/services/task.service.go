@@ -1,8 +1,13 @@
 package services
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"strings"
+
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/errs"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/repositories"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/responses"
@@ -10,10 +15,16 @@ import (
 )
 
 type TaskService interface {
-	GetAll(req requests.Pagination) (responses.TasksListPaginated, *utils.HTTPError)
-	Create(req requests.TaskCreation) (entities.Task, *utils.HTTPError)
-	GetAllStream() (*sql.Rows, *utils.HTTPError)
-	ScanTask(rows *sql.Rows, task *entities.Task) *utils.HTTPError
+	GetAll(ctx context.Context, p entities.Principal, req requests.TaskList) (responses.TasksListPaginated, *errs.Error)
+	Create(ctx context.Context, p entities.Principal, req requests.TaskCreation) (entities.Task, *errs.Error)
+	GetByID(ctx context.Context, p entities.Principal, req requests.TaskByID) (entities.Task, *errs.Error)
+	Update(ctx context.Context, p entities.Principal, req requests.TaskUpdate) (entities.Task, *errs.Error)
+	Patch(ctx context.Context, p entities.Principal, req requests.TaskPatch) (entities.Task, *errs.Error)
+	Delete(ctx context.Context, p entities.Principal, req requests.TaskByID) *errs.Error
+	Restore(ctx context.Context, p entities.Principal, req requests.TaskByID) (entities.Task, *errs.Error)
+	Transition(ctx context.Context, p entities.Principal, req requests.TaskTransition) (entities.Task, *errs.Error)
+	GetAllStream(ctx context.Context, p entities.Principal, req requests.TaskOwner) (*sql.Rows, *errs.Error)
+	ScanTask(ctx context.Context, rows *sql.Rows, task *entities.Task) *errs.Error
 }
 
 type taskService struct {
@@ -26,56 +37,270 @@ func NewTask(repo repositories.TaskRepository) TaskService {
 }
 
 // GetAll tasks
-func (ts taskService) GetAll(req requests.Pagination) (responses.TasksListPaginated, *utils.HTTPError) {
-	tasks, total, err := ts.taskRepository.GetAll(req.Page, req.Limit, req.Sorts)
+func (ts taskService) GetAll(ctx context.Context, p entities.Principal, req requests.TaskList) (responses.TasksListPaginated, *errs.Error) {
+	userID, errOwner := ownerFilter(p, req.Owner)
+	if errOwner != nil {
+		return responses.TasksListPaginated{}, errOwner
+	}
+
+	states, errStates := parseStates(req.States)
+	if errStates != nil {
+		return responses.TasksListPaginated{}, errStates
+	}
+
+	tasks, pagination, err := ts.taskRepository.GetAll(ctx, userID, states, req.Pagination)
+	var invalidParams utils.ValidatorErrors
+	if errors.As(err, &invalidParams) {
+		return responses.TasksListPaginated{}, errs.Validation("Invalid parameters", invalidParams)
+	}
 	if err != nil {
-		return responses.TasksListPaginated{}, utils.NewHTTPError(utils.StatusInternalServerError, "Database error", "Error during tasks list", err)
+		return responses.TasksListPaginated{}, errs.Internal("Database error", "Error during tasks list", err)
 	}
 
 	return responses.TasksListPaginated{
-		Data:  tasks,
-		Total: total,
+		Data:       tasks,
+		Pagination: pagination,
 	}, nil
 }
 
 // Create task
-func (ts taskService) Create(req requests.TaskCreation) (entities.Task, *utils.HTTPError) {
+func (ts taskService) Create(ctx context.Context, p entities.Principal, req requests.TaskCreation) (entities.Task, *errs.Error) {
 	validateReq := utils.ValidateStruct(req)
 	if validateReq != nil {
-		return entities.Task{}, utils.NewHTTPError(utils.StatusBadRequest, "Invalid parameters", validateReq, nil)
-	}
-
-	if req.Name == "" {
-		return entities.Task{}, utils.NewHTTPError(utils.StatusBadRequest, "Name cannot be empty", validateReq, nil)
+		return entities.Task{}, errs.Validation("Invalid parameters", validateReq)
 	}
 
 	newTask := entities.Task{
 		Name:        req.Name,
 		Description: req.Description,
+		State:       entities.TaskStateTodo,
+		UserID:      &p.ID,
 	}
 
-	if err := ts.taskRepository.Create(&newTask); err != nil {
-		return entities.Task{}, utils.NewHTTPError(utils.StatusInternalServerError, "Database error", "Error during task creation", err)
+	if err := ts.taskRepository.Create(ctx, &newTask); err != nil {
+		return entities.Task{}, errs.Internal("Database error", "Error during task creation", err)
 	}
 
 	return newTask, nil
 }
 
+// GetByID returns a task from its ID
+func (ts taskService) GetByID(ctx context.Context, p entities.Principal, req requests.TaskByID) (entities.Task, *errs.Error) {
+	validateReq := utils.ValidateStruct(req)
+	if validateReq != nil {
+		return entities.Task{}, errs.Validation("Invalid parameters", validateReq)
+	}
+
+	return ts.getAccessibleTask(ctx, p, req.ID, false)
+}
+
+// Update task
+func (ts taskService) Update(ctx context.Context, p entities.Principal, req requests.TaskUpdate) (entities.Task, *errs.Error) {
+	validateReq := utils.ValidateStruct(req)
+	if validateReq != nil {
+		return entities.Task{}, errs.Validation("Invalid parameters", validateReq)
+	}
+
+	task, errTask := ts.getAccessibleTask(ctx, p, req.ID, false)
+	if errTask != nil {
+		return entities.Task{}, errTask
+	}
+
+	task.Name = req.Name
+	task.Description = req.Description
+
+	if err := ts.taskRepository.Update(ctx, &task); err != nil {
+		return entities.Task{}, errs.Internal("Database error", "Error during task update", err)
+	}
+
+	return task, nil
+}
+
+// Patch partially updates a task, only the fields present in the request are updated
+func (ts taskService) Patch(ctx context.Context, p entities.Principal, req requests.TaskPatch) (entities.Task, *errs.Error) {
+	validateReq := utils.ValidateStruct(req)
+	if validateReq != nil {
+		return entities.Task{}, errs.Validation("Invalid parameters", validateReq)
+	}
+
+	task, errTask := ts.getAccessibleTask(ctx, p, req.ID, false)
+	if errTask != nil {
+		return entities.Task{}, errTask
+	}
+
+	if req.Name != nil {
+		task.Name = *req.Name
+	}
+	if req.Description != nil {
+		task.Description = *req.Description
+	}
+
+	if err := ts.taskRepository.Update(ctx, &task); err != nil {
+		return entities.Task{}, errs.Internal("Database error", "Error during task update", err)
+	}
+
+	return task, nil
+}
+
+// Delete soft deletes a task
+func (ts taskService) Delete(ctx context.Context, p entities.Principal, req requests.TaskByID) *errs.Error {
+	validateReq := utils.ValidateStruct(req)
+	if validateReq != nil {
+		return errs.Validation("Invalid parameters", validateReq)
+	}
+
+	if _, errTask := ts.getAccessibleTask(ctx, p, req.ID, false); errTask != nil {
+		return errTask
+	}
+
+	if err := ts.taskRepository.Delete(ctx, req.ID); err != nil {
+		return errs.Internal("Database error", "Error when deleting the task", err)
+	}
+
+	return nil
+}
+
+// Restore restores a soft deleted task
+func (ts taskService) Restore(ctx context.Context, p entities.Principal, req requests.TaskByID) (entities.Task, *errs.Error) {
+	validateReq := utils.ValidateStruct(req)
+	if validateReq != nil {
+		return entities.Task{}, errs.Validation("Invalid parameters", validateReq)
+	}
+
+	if _, errTask := ts.getAccessibleTask(ctx, p, req.ID, true); errTask != nil {
+		return entities.Task{}, errTask
+	}
+
+	if err := ts.taskRepository.Restore(ctx, req.ID); err != nil {
+		return entities.Task{}, errs.Internal("Database error", "Error when restoring the task", err)
+	}
+
+	return ts.getAccessibleTask(ctx, p, req.ID, false)
+}
+
+// Transition changes the state of a task if the transition is allowed
+func (ts taskService) Transition(ctx context.Context, p entities.Principal, req requests.TaskTransition) (entities.Task, *errs.Error) {
+	validateReq := utils.ValidateStruct(req)
+	if validateReq != nil {
+		return entities.Task{}, errs.Validation("Invalid parameters", validateReq)
+	}
+
+	task, errTask := ts.getAccessibleTask(ctx, p, req.ID, false)
+	if errTask != nil {
+		return entities.Task{}, errTask
+	}
+
+	if !task.CanTransitionTo(req.State) {
+		return entities.Task{}, errs.Conflict("Transition not allowed", map[string]interface{}{
+			"from":    task.State,
+			"to":      req.State,
+			"allowed": entities.TaskTransitions[task.State],
+		})
+	}
+
+	transition := entities.TaskTransition{
+		TaskID:    task.ID,
+		FromState: task.State,
+		ToState:   req.State,
+		UserID:    &p.ID,
+	}
+	updated, err := ts.taskRepository.Transition(ctx, &transition)
+	if err != nil {
+		return entities.Task{}, errs.Internal("Database error", "Error during task transition", err)
+	}
+	if !updated {
+		return entities.Task{}, errs.Conflict("Task state has been changed by another request", nil)
+	}
+
+	return ts.getAccessibleTask(ctx, p, req.ID, false)
+}
+
 // GetAllStream tasks list
-func (ts taskService) GetAllStream() (*sql.Rows, *utils.HTTPError) {
-	rows, err := ts.taskRepository.GetAllRows()
+func (ts taskService) GetAllStream(ctx context.Context, p entities.Principal, req requests.TaskOwner) (*sql.Rows, *errs.Error) {
+	userID, errOwner := ownerFilter(p, req.Owner)
+	if errOwner != nil {
+		return nil, errOwner
+	}
+
+	rows, err := ts.taskRepository.GetAllRows(ctx, userID)
 	if err != nil {
-		return nil, utils.NewHTTPError(utils.StatusInternalServerError, "Database error", "Error during tasks list with stream", err)
+		return nil, errs.Internal("Database error", "Error during tasks list with stream", err)
 	}
 
 	return rows, nil
 }
 
 // ScanTask scans a row
-func (ts taskService) ScanTask(rows *sql.Rows, task *entities.Task) *utils.HTTPError {
-	if err := ts.taskRepository.ScanRow(rows, task); err != nil {
-		return utils.NewHTTPError(utils.StatusInternalServerError, "Database error", "Error during task scan", err)
+func (ts taskService) ScanTask(ctx context.Context, rows *sql.Rows, task *entities.Task) *errs.Error {
+	if err := ts.taskRepository.ScanRow(ctx, rows, task); err != nil {
+		return errs.Internal("Database error", "Error during task scan", err)
 	}
 
 	return nil
 }
+
+// ownerFilter returns the ID of the user whose tasks are listed, or an empty string for all users.
+// By default, only the tasks of the authenticated user are listed.
+// Listing the tasks of other users requires the tasks:all permission.
+func ownerFilter(p entities.Principal, owner string) (string, *errs.Error) {
+	if owner == "" || p.Is(owner) {
+		return p.ID, nil
+	}
+
+	if !p.HasPermission(entities.PermissionTasksAll) {
+		return "", errs.Forbidden()
+	}
+
+	if owner == requests.TaskOwnerAll {
+		return "", nil
+	}
+	return owner, nil
+}
+
+// getAccessibleTask returns a task, or a soft deleted task if deleted is true, if the principal can access it.
+// The task must belong to the authenticated user, unless he has the tasks:all permission.
+// Tasks of other users are reported as not found to avoid disclosing their existence.
+func (ts taskService) getAccessibleTask(ctx context.Context, p entities.Principal, id string, deleted bool) (entities.Task, *errs.Error) {
+	var task entities.Task
+	var err error
+	if deleted {
+		task, err = ts.taskRepository.GetDeletedByID(ctx, id)
+	} else {
+		task, err = ts.taskRepository.GetByID(ctx, id)
+	}
+	if err != nil {
+		return entities.Task{}, errs.Internal("Database error", "Error when getting task by id", err)
+	}
+
+	isOwner := task.UserID != nil && p.Is(*task.UserID)
+	if task.ID == "" || (!isOwner && !p.HasPermission(entities.PermissionTasksAll)) {
+		return entities.Task{}, errs.NotFound("No task found")
+	}
+
+	return task, nil
+}
+
+// parseStates returns the list of states to filter on.
+// Each value can contain several states separated by commas.
+func parseStates(values []string) ([]string, *errs.Error) {
+	states := make([]string, 0, len(values))
+	for _, value := range values {
+		for _, state := range strings.Split(value, ",") {
+			state = strings.TrimSpace(state)
+			if state == "" {
+				continue
+			}
+			if !entities.IsValidTaskState(state) {
+				return nil, errs.Validation("Invalid parameters", utils.ValidatorErrors{{
+					FailedField: "state",
+					Tag:         "oneof",
+					Value:       state,
+					Kind:        "string",
+					Allowed:     entities.TaskStates,
+				}})
+			}
+			states = append(states, state)
+		}
+	}
+	return states, nil
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/stores/memory"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/errs"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	taskOwner = entities.Principal{ID: "5d9c1c2e-6a3b-4f7d-8e1f-2a3b4c5d6e7f"}
+	taskOther = entities.Principal{ID: "7e8f9a0b-1c2d-4e3f-9a4b-5c6d7e8f9a0b"}
+	taskAdmin = entities.Principal{ID: "9a0b1c2d-3e4f-4a5b-8c6d-7e8f9a0b1c2d", Permissions: []string{entities.PermissionTasksAll}}
+)
+
+// newTestTaskService returns a task service using an in-memory store with three tasks of the owner
+// and one task of the other user.
+func newTestTaskService(t *testing.T) (TaskService, []entities.Task) {
+	service := NewTask(memory.NewTaskStore())
+
+	tasks := make([]entities.Task, 0, 4)
+	for _, c := range []struct {
+		p    entities.Principal
+		name string
+	}{
+		{taskOwner, "Task 1"},
+		{taskOwner, "Task 2"},
+		{taskOwner, "Task 3"},
+		{taskOther, "Other task"},
+	} {
+		task, err := service.Create(ctx, c.p, requests.TaskCreation{Name: c.name})
+		assert.Nil(t, err)
+		tasks = append(tasks, task)
+	}
+	return service, tasks
+}
+
+func TestTaskServiceGetAll(t *testing.T) {
+	service, tasks := newTestTaskService(t)
+
+	// Own tasks by default
+	list, err := service.GetAll(ctx, taskOwner, requests.TaskList{Pagination: requests.Pagination{Sorts: "-name", Total: true}})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), *list.Total)
+	assert.Equal(t, "Task 3", list.Data[0].Name)
+
+	// Pages
+	list, err = service.GetAll(ctx, taskOwner, requests.TaskList{Pagination: requests.Pagination{Sorts: "name", Limit: "2"}})
+	assert.Nil(t, err)
+	assert.Len(t, list.Data, 2)
+	list, err = service.GetAll(ctx, taskOwner, requests.TaskList{Pagination: requests.Pagination{Sorts: "name", Limit: "2", Cursor: list.NextCursor}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(list.Data))
+	assert.Equal(t, "Task 3", list.Data[0].Name)
+
+	// States
+	_, err = service.Transition(ctx, taskOwner, requests.TaskTransition{ID: tasks[0].ID, State: entities.TaskStateInProgress})
+	assert.Nil(t, err)
+	list, err = service.GetAll(ctx, taskOwner, requests.TaskList{States: []string{"in_progress,done"}})
+	assert.Nil(t, err)
+	assert.Len(t, list.Data, 1)
+
+	// Other owners
+	_, err = service.GetAll(ctx, taskOther, requests.TaskList{TaskOwner: requests.TaskOwner{Owner: taskOwner.ID}})
+	assert.Equal(t, errs.KindForbidden, err.Kind)
+
+	list, err = service.GetAll(ctx, taskAdmin, requests.TaskList{TaskOwner: requests.TaskOwner{Owner: requests.TaskOwnerAll}})
+	assert.Nil(t, err)
+	assert.Len(t, list.Data, 4)
+}
+
+func TestTaskServiceGetAllInvalidParameters(t *testing.T) {
+	service, _ := newTestTaskService(t)
+
+	_, err := service.GetAll(ctx, taskOwner, requests.TaskList{States: []string{"todo,unknown"}})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+	assert.Equal(t, utils.ValidatorErrors{{
+		FailedField: "state",
+		Tag:         "oneof",
+		Value:       "unknown",
+		Kind:        "string",
+		Allowed:     entities.TaskStates,
+	}}, err.Details)
+
+	_, err = service.GetAll(ctx, taskOwner, requests.TaskList{Pagination: requests.Pagination{Sorts: "description"}})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+	assert.Equal(t, "Invalid parameters", err.Message)
+
+	_, err = service.GetAll(ctx, taskOwner, requests.TaskList{Pagination: requests.Pagination{Cursor: "invalid"}})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+}
+
+func TestTaskServiceAccess(t *testing.T) {
+	service, tasks := newTestTaskService(t)
+
+	_, err := service.GetByID(ctx, taskOther, requests.TaskByID{ID: tasks[0].ID})
+	assert.Equal(t, errs.KindNotFound, err.Kind)
+
+	task, err := service.GetByID(ctx, taskAdmin, requests.TaskByID{ID: tasks[0].ID})
+	assert.Nil(t, err)
+	assert.Equal(t, tasks[0].ID, task.ID)
+
+	name := "Task 1 renamed"
+	task, err = service.Patch(ctx, taskOwner, requests.TaskPatch{ID: tasks[0].ID, Name: &name})
+	assert.Nil(t, err)
+	assert.Equal(t, name, task.Name)
+
+	assert.Nil(t, service.Delete(ctx, taskOwner, requests.TaskByID{ID: tasks[0].ID}))
+	_, err = service.GetByID(ctx, taskOwner, requests.TaskByID{ID: tasks[0].ID})
+	assert.Equal(t, errs.KindNotFound, err.Kind)
+
+	task, err = service.Restore(ctx, taskOwner, requests.TaskByID{ID: tasks[0].ID})
+	assert.Nil(t, err)
+	assert.Equal(t, name, task.Name)
+}
+
+func TestTaskServiceUpdate(t *testing.T) {
+	service, tasks := newTestTaskService(t)
+
+	task, err := service.Update(ctx, taskOwner, requests.TaskUpdate{ID: tasks[0].ID, Name: "Task 1 updated", Description: "Description"})
+	assert.Nil(t, err)
+	assert.Equal(t, "Task 1 updated", task.Name)
+	assert.Equal(t, "Description", task.Description)
+
+	// The description is cleared, unlike a patch
+	task, err = service.Update(ctx, taskOwner, requests.TaskUpdate{ID: tasks[0].ID, Name: "Task 1 updated"})
+	assert.Nil(t, err)
+	assert.Empty(t, task.Description)
+
+	_, err = service.Update(ctx, taskOwner, requests.TaskUpdate{ID: tasks[0].ID, Name: "ab"})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+
+	_, err = service.Update(ctx, taskOwner, requests.TaskUpdate{ID: "invalid", Name: "Task 1 updated"})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+
+	_, err = service.Update(ctx, taskOwner, requests.TaskUpdate{ID: "00000000-0000-0000-0000-000000000000", Name: "Task 1 updated"})
+	assert.Equal(t, errs.KindNotFound, err.Kind)
+}
+
+func TestTaskServiceRestore(t *testing.T) {
+	service, tasks := newTestTaskService(t)
+
+	// Only deleted tasks can be restored
+	_, err := service.Restore(ctx, taskOwner, requests.TaskByID{ID: tasks[0].ID})
+	assert.Equal(t, errs.KindNotFound, err.Kind)
+
+	assert.Nil(t, service.Delete(ctx, taskOwner, requests.TaskByID{ID: tasks[0].ID}))
+	err = service.Delete(ctx, taskOwner, requests.TaskByID{ID: tasks[0].ID})
+	assert.Equal(t, errs.KindNotFound, err.Kind)
+
+	list, errList := service.GetAll(ctx, taskOwner, requests.TaskList{})
+	assert.Nil(t, errList)
+	assert.Len(t, list.Data, 2)
+
+	task, err := service.Restore(ctx, taskOwner, requests.TaskByID{ID: tasks[0].ID})
+	assert.Nil(t, err)
+	assert.Equal(t, tasks[0].ID, task.ID)
+	assert.Equal(t, entities.TaskStateTodo, task.State)
+
+	list, errList = service.GetAll(ctx, taskOwner, requests.TaskList{})
+	assert.Nil(t, errList)
+	assert.Len(t, list.Data, 3)
+}
+
+func TestTaskServiceOwnership(t *testing.T) {
+	service, tasks := newTestTaskService(t)
+	name := "Renamed task"
+
+	// Tasks of other users are reported as not found
+	_, err := service.Update(ctx, taskOther, requests.TaskUpdate{ID: tasks[0].ID, Name: name})
+	assert.Equal(t, errs.KindNotFound, err.Kind)
+
+	_, err = service.Patch(ctx, taskOther, requests.TaskPatch{ID: tasks[0].ID, Name: &name})
+	assert.Equal(t, errs.KindNotFound, err.Kind)
+
+	_, err = service.Transition(ctx, taskOther, requests.TaskTransition{ID: tasks[0].ID, State: entities.TaskStateInProgress})
+	assert.Equal(t, errs.KindNotFound, err.Kind)
+
+	err = service.Delete(ctx, taskOther, requests.TaskByID{ID: tasks[0].ID})
+	assert.Equal(t, errs.KindNotFound, err.Kind)
+
+	assert.Nil(t, service.Delete(ctx, taskOwner, requests.TaskByID{ID: tasks[0].ID}))
+	_, err = service.Restore(ctx, taskOther, requests.TaskByID{ID: tasks[0].ID})
+	assert.Equal(t, errs.KindNotFound, err.Kind)
+
+	// The tasks:all permission gives access to the tasks of all users
+	task, err := service.Restore(ctx, taskAdmin, requests.TaskByID{ID: tasks[0].ID})
+	assert.Nil(t, err)
+	assert.Equal(t, "Task 1", task.Name)
+
+	task, err = service.Update(ctx, taskAdmin, requests.TaskUpdate{ID: tasks[0].ID, Name: name})
+	assert.Nil(t, err)
+	assert.Equal(t, name, task.Name)
+	assert.Equal(t, taskOwner.ID, *task.UserID)
+
+	// The tasks of another user can be listed
+	list, err := service.GetAll(ctx, taskAdmin, requests.TaskList{TaskOwner: requests.TaskOwner{Owner: taskOther.ID}})
+	assert.Nil(t, err)
+	assert.Len(t, list.Data, 1)
+	assert.Equal(t, tasks[3].ID, list.Data[0].ID)
+}
+
+func TestTaskServiceTransition(t *testing.T) {
+	service, tasks := newTestTaskService(t)
+
+	task, err := service.Transition(ctx, taskOwner, requests.TaskTransition{ID: tasks[0].ID, State: entities.TaskStateInProgress})
+	assert.Nil(t, err)
+	assert.Equal(t, entities.TaskStateInProgress, task.State)
+	assert.Len(t, task.Transitions, 1)
+
+	_, err = service.Transition(ctx, taskOwner, requests.TaskTransition{ID: tasks[0].ID, State: entities.TaskStateArchived})
+	assert.Equal(t, errs.KindConflict, err.Kind)
+	assert.Equal(t, map[string]interface{}{
+		"from":    entities.TaskStateInProgress,
+		"to":      entities.TaskStateArchived,
+		"allowed": entities.TaskTransitions[entities.TaskStateInProgress],
+	}, err.Details)
+
+	// Transition to the same state
+	_, err = service.Transition(ctx, taskOwner, requests.TaskTransition{ID: tasks[0].ID, State: entities.TaskStateInProgress})
+	assert.Equal(t, errs.KindConflict, err.Kind)
+
+	// The history keeps the successful transitions in order
+	_, err = service.Transition(ctx, taskOwner, requests.TaskTransition{ID: tasks[0].ID, State: entities.TaskStateDone})
+	assert.Nil(t, err)
+	task, err = service.Transition(ctx, taskOwner, requests.TaskTransition{ID: tasks[0].ID, State: entities.TaskStateArchived})
+	assert.Nil(t, err)
+	assert.Equal(t, entities.TaskStateArchived, task.State)
+	assert.Len(t, task.Transitions, 3)
+	assert.Equal(t, entities.TaskStateDone, task.Transitions[2].FromState)
+	assert.Equal(t, taskOwner.ID, *task.Transitions[2].UserID)
+
+	// Archived tasks cannot change anymore
+	_, err = service.Transition(ctx, taskOwner, requests.TaskTransition{ID: tasks[0].ID, State: entities.TaskStateTodo})
+	assert.Equal(t, errs.KindConflict, err.Kind)
+
+	_, err = service.Transition(ctx, taskOwner, requests.TaskTransition{ID: tasks[0].ID, State: "unknown"})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+}
+
+// racingTaskStore changes the state of a task between its reading and its transition,
+// as a concurrent request would do.
+type racingTaskStore struct {
+	*memory.TaskStore
+}
+
+func (s racingTaskStore) Transition(ctx context.Context, transition *entities.TaskTransition) (bool, error) {
+	_, err := s.TaskStore.Transition(ctx, &entities.TaskTransition{
+		TaskID:    transition.TaskID,
+		FromState: transition.FromState,
+		ToState:   entities.TaskStateArchived,
+	})
+	if err != nil {
+		return false, err
+	}
+	return s.TaskStore.Transition(ctx, transition)
+}
+
+func TestTaskServiceTransitionConflict(t *testing.T) {
+	store := memory.NewTaskStore()
+	service := NewTask(racingTaskStore{store})
+
+	task, err := service.Create(ctx, taskOwner, requests.TaskCreation{Name: "Task 1"})
+	assert.Nil(t, err)
+
+	_, err = service.Transition(ctx, taskOwner, requests.TaskTransition{ID: task.ID, State: entities.TaskStateInProgress})
+	assert.Equal(t, errs.KindConflict, err.Kind)
+	assert.Equal(t, "Task state has been changed by another request", err.Message)
+
+	// Only the concurrent transition has been saved
+	task, errTask := store.GetByID(ctx, task.ID)
+	assert.Nil(t, errTask)
+	assert.Equal(t, entities.TaskStateArchived, task.State)
+	assert.Len(t, task.Transitions, 1)
+}
@@ -2,257 +2,856 @@ package services
 
 import (
 	"bytes"
-	"crypto/sha512"
-	"encoding/hex"
+	"context"
 	"errors"
 	"fmt"
 	"html/template"
 	"time"
 
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/errs"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/repositories"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/responses"
+	values_objects "github.com/fabienbellanger/fiber-boilerplate/pkg/domain/value_objects"
 	"github.com/fabienbellanger/fiber-boilerplate/utils"
 	"github.com/fabienbellanger/goutils/mail"
-	"github.com/google/uuid"
 	"github.com/spf13/viper"
 	"gorm.io/gorm"
 )
 
+const (
+	// DefaultAccessTokenLifetime is used when JWT_ACCESS_LIFETIME is not set
+	DefaultAccessTokenLifetime = 15 * time.Minute
+
+	// DefaultRefreshTokenLifetime is used when JWT_REFRESH_LIFETIME is not set
+	DefaultRefreshTokenLifetime = 7 * 24 * time.Hour
+
+	// DefaultEmailVerificationLifetime is used when EMAIL_VERIFICATION_EXPIRATION_DURATION is not set
+	DefaultEmailVerificationLifetime = 48 * time.Hour
+
+	// DefaultPasswordResetLifetime is used when FORGOTTEN_PASSWORD_EXPIRATION_DURATION is not set
+	DefaultPasswordResetLifetime = 24 * time.Hour
+
+	// DefaultPasswordResetMaxAttempts is used when FORGOTTEN_PASSWORD_MAX_ATTEMPTS is not set
+	DefaultPasswordResetMaxAttempts = 5
+)
+
 type UserService interface {
-	Login(req requests.UserLogin) (responses.UserLogin, *utils.HTTPError)
-	Create(req requests.UserCreation) (entities.User, *utils.HTTPError)
-	GetAll(req requests.Pagination) (responses.UsersListPaginated, *utils.HTTPError)
-	GetByID(id requests.UserByID) (entities.User, *utils.HTTPError)
-	Delete(id requests.UserByID) *utils.HTTPError
-	Update(req requests.UserUpdate) (entities.User, *utils.HTTPError)
-	UpdatePassword(req requests.UserPasswordUpdate) *utils.HTTPError
-	ForgottenPassword(req requests.UserForgotPassword) (entities.PasswordResets, *utils.HTTPError)
+	Login(ctx context.Context, req requests.UserLogin) (responses.UserLogin, *errs.Error)
+	RefreshToken(ctx context.Context, req requests.TokenRefresh) (responses.UserLogin, *errs.Error)
+	CheckToken(ctx context.Context, req requests.UserToken) *errs.Error
+	Logout(ctx context.Context, p entities.Principal, req requests.UserLogout) *errs.Error
+	LogoutAll(ctx context.Context, p entities.Principal) *errs.Error
+	Create(ctx context.Context, p entities.Principal, req requests.UserCreation) (entities.User, *errs.Error)
+	GetAll(ctx context.Context, p entities.Principal, req requests.Pagination) (responses.UsersListPaginated, *errs.Error)
+	GetByID(ctx context.Context, p entities.Principal, id requests.UserByID) (entities.User, *errs.Error)
+	Delete(ctx context.Context, p entities.Principal, id requests.UserByID) *errs.Error
+	Update(ctx context.Context, p entities.Principal, req requests.UserUpdate) (entities.User, *errs.Error)
+	UpdatePassword(ctx context.Context, req requests.UserPasswordUpdate) *errs.Error
+	ForgottenPassword(ctx context.Context, req requests.UserForgotPassword) *errs.Error
+	Me(ctx context.Context, p entities.Principal) (entities.User, *errs.Error)
+	UpdateProfile(ctx context.Context, p entities.Principal, req requests.UserProfileUpdate) (entities.User, *errs.Error)
+	ChangePassword(ctx context.Context, p entities.Principal, req requests.UserMePasswordUpdate) (responses.UserLogin, *errs.Error)
+	DeleteAccount(ctx context.Context, p entities.Principal, req requests.UserMeDeletion) *errs.Error
+	VerifyEmail(ctx context.Context, req requests.UserEmailVerification) *errs.Error
 }
 
 type userService struct {
 	userRepository repositories.UserRepository
+	txManager      repositories.TxManager
+	passwordHasher utils.PasswordHasher
 }
 
 // NewUser returns a new user service
-func NewUser(repo repositories.UserRepository) UserService {
-	return &userService{repo}
+func NewUser(repo repositories.UserRepository, txManager repositories.TxManager, hasher utils.PasswordHasher) UserService {
+	return &userService{repo, txManager, hasher}
+}
+
+// withinTx runs fn in a unit of work.
+// A *errs.Error returned by fn is returned as is, other errors are returned as database errors.
+func (us userService) withinTx(ctx context.Context, fn func(repos repositories.Repositories) error, details string) *errs.Error {
+	err := us.txManager.WithinTx(ctx, fn)
+	if err == nil {
+		return nil
+	}
+
+	var e *errs.Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return errs.Internal("Database error", details, err)
 }
 
 // Login user
-func (us userService) Login(req requests.UserLogin) (responses.UserLogin, *utils.HTTPError) {
+func (us userService) Login(ctx context.Context, req requests.UserLogin) (responses.UserLogin, *errs.Error) {
 	loginErrors := utils.ValidateStruct(req)
 	if loginErrors != nil {
-		return responses.UserLogin{}, utils.NewHTTPError(utils.StatusBadRequest, "Invalid body", loginErrors, nil)
+		return responses.UserLogin{}, errs.Validation("Invalid body", loginErrors)
 	}
 
-	user, err := us.userRepository.Login(req.Username, req.Password)
+	user, err := us.userRepository.Login(ctx, req.Username.String(), req.Password)
 	if err != nil {
-		var e *utils.HTTPError
+		var e *errs.Error
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			e = utils.NewHTTPError(utils.StatusUnauthorized, "Unauthorized", nil, nil)
+			e = errs.Unauthorized()
 		} else {
-			e = utils.NewHTTPError(utils.StatusInternalServerError, "Internal server error", "Error during authentication", err)
+			e = errs.Internal("Internal server error", "Error during authentication", err)
 		}
 		return responses.UserLogin{}, e
 	}
 
-	// Create token
+	if viper.GetBool("EMAIL_VERIFICATION_REQUIRED") && !user.IsEmailVerified() {
+		return responses.UserLogin{}, &errs.Error{Kind: errs.KindForbidden, Message: "Email address not verified"}
+	}
+
+	return us.generateTokens(ctx, user, "")
+}
+
+// RefreshToken rotates a refresh token and returns a new access token.
+// If an already used token is presented, even expired, the whole token family is revoked.
+func (us userService) RefreshToken(ctx context.Context, req requests.TokenRefresh) (responses.UserLogin, *errs.Error) {
+	refreshErrors := utils.ValidateStruct(req)
+	if refreshErrors != nil {
+		return responses.UserLogin{}, errs.Validation("Invalid body", refreshErrors)
+	}
+
+	refreshToken, err := us.userRepository.GetRefreshToken(ctx, utils.HashToken(req.RefreshToken))
+	if err != nil {
+		return responses.UserLogin{}, errs.Internal("Database error", "Error when getting refresh token", err)
+	}
+	if refreshToken.ID == "" {
+		return responses.UserLogin{}, errs.Unauthorized()
+	}
+
+	// Reuse detection
+	// ---------------
+	// An expired token which has never been used is only rejected,
+	// whereas an already used one is a replay, even if it has expired.
+	if refreshToken.IsExpired() && refreshToken.UsedAt == nil {
+		return responses.UserLogin{}, errs.Unauthorized()
+	}
+	used := false
+	if refreshToken.IsUsable() {
+		used, err = us.userRepository.UseRefreshToken(ctx, refreshToken.ID)
+		if err != nil {
+			return responses.UserLogin{}, errs.Internal("Database error", "Error when using refresh token", err)
+		}
+	}
+	if !used {
+		err = us.userRepository.RevokeRefreshTokenFamily(ctx, refreshToken.FamilyID)
+		if err != nil {
+			return responses.UserLogin{}, errs.Internal("Database error", "Error when revoking refresh tokens", err)
+		}
+		return responses.UserLogin{}, errs.Unauthorized()
+	}
+
+	user, err := us.userRepository.GetByID(ctx, refreshToken.UserID)
+	if err != nil {
+		return responses.UserLogin{}, errs.Internal("Database error", "Error when getting user by id", err)
+	}
+	if user.ID == "" {
+		return responses.UserLogin{}, errs.Unauthorized()
+	}
+
+	return us.generateTokens(ctx, user, refreshToken.FamilyID)
+}
+
+// CheckToken checks that an access token has not been revoked.
+// The token is revoked if it is in the revoked tokens list, if the user no longer exists
+// or if the user token version has changed since the token generation.
+func (us userService) CheckToken(ctx context.Context, req requests.UserToken) *errs.Error {
+	validateReq := utils.ValidateStruct(req)
+	if validateReq != nil {
+		return errs.Unauthorized()
+	}
+
+	user, err := us.userRepository.GetByID(ctx, req.UserID)
+	if err != nil {
+		return errs.Internal("Database error", "Error when getting user by id", err)
+	}
+	if user.ID == "" || user.TokenVersion != req.TokenVersion {
+		return errs.Unauthorized()
+	}
+
+	revoked, err := us.userRepository.IsTokenRevoked(ctx, req.TokenID)
+	if err != nil {
+		return errs.Internal("Database error", "Error when checking revoked token", err)
+	}
+	if revoked {
+		return errs.Unauthorized()
+	}
+
+	return nil
+}
+
+// Logout revokes the current access token and the refresh token family if a refresh token is given.
+func (us userService) Logout(ctx context.Context, p entities.Principal, req requests.UserLogout) *errs.Error {
+	if !p.IsAuthenticated() {
+		return errs.Unauthorized()
+	}
+
+	err := us.userRepository.RevokeToken(ctx, entities.RevokedToken{
+		ID:        p.TokenID,
+		ExpiredAt: p.ExpiresAt.UTC(),
+	})
+	if err != nil {
+		return errs.Internal("Database error", "Error when revoking token", err)
+	}
+
+	if req.RefreshToken != "" {
+		refreshToken, err := us.userRepository.GetRefreshToken(ctx, utils.HashToken(req.RefreshToken))
+		if err != nil {
+			return errs.Internal("Database error", "Error when getting refresh token", err)
+		}
+		if refreshToken.ID != "" && refreshToken.UserID == p.ID {
+			if err = us.userRepository.RevokeRefreshTokenFamily(ctx, refreshToken.FamilyID); err != nil {
+				return errs.Internal("Database error", "Error when revoking refresh tokens", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// LogoutAll revokes all the access and refresh tokens of the user.
+func (us userService) LogoutAll(ctx context.Context, p entities.Principal) *errs.Error {
+	if !p.IsAuthenticated() {
+		return errs.Unauthorized()
+	}
+
+	return us.withinTx(ctx, func(repos repositories.Repositories) error {
+		return revokeAllTokens(ctx, repos.Users, p.ID)
+	}, "Error when revoking user tokens")
+}
+
+// revokeAllTokens invalidates all the access tokens and revokes all the refresh tokens of a user.
+func revokeAllTokens(ctx context.Context, repo repositories.UserRepository, userID string) error {
+	if err := repo.IncrementTokenVersion(ctx, userID); err != nil {
+		return errs.Internal("Database error", "Error when incrementing user token version", err)
+	}
+
+	if err := repo.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		return errs.Internal("Database error", "Error when revoking user refresh tokens", err)
+	}
+
+	return nil
+}
+
+// generateTokens returns a new access token and a new refresh token of the family.
+func (us userService) generateTokens(ctx context.Context, user entities.User, familyID string) (responses.UserLogin, *errs.Error) {
+	// Access token
+	accessLifetime := viper.GetDuration("JWT_ACCESS_LIFETIME") * time.Minute
+	if accessLifetime <= 0 {
+		accessLifetime = DefaultAccessTokenLifetime
+	}
+
 	token, expiresAt, err := user.GenerateJWT(
-		viper.GetDuration("JWT_LIFETIME"),
+		accessLifetime,
 		viper.GetString("JWT_ALGO"),
 		viper.GetString("JWT_SECRET"))
 	if err != nil {
-		return responses.UserLogin{}, utils.NewHTTPError(utils.StatusInternalServerError, "Internal server error", "Error during token generation", err)
+		return responses.UserLogin{}, errs.Internal("Internal server error", "Error during token generation", err)
+	}
+
+	// Refresh token
+	refreshLifetime := viper.GetDuration("JWT_REFRESH_LIFETIME") * time.Hour
+	if refreshLifetime <= 0 {
+		refreshLifetime = DefaultRefreshTokenLifetime
+	}
+
+	refreshToken, refreshTokenValue, err := entities.NewRefreshToken(user.ID, familyID, refreshLifetime)
+	if err != nil {
+		return responses.UserLogin{}, errs.Internal("Internal server error", "Error during refresh token generation", err)
+	}
+	if err = us.userRepository.CreateRefreshToken(ctx, &refreshToken); err != nil {
+		return responses.UserLogin{}, errs.Internal("Database error", "Error during refresh token creation", err)
 	}
 
 	return responses.UserLogin{
-		User:      user,
-		Token:     token,
-		ExpiresAt: expiresAt.Format(time.RFC3339),
+		User:                  user,
+		Token:                 token,
+		ExpiresAt:             expiresAt.Format(time.RFC3339),
+		RefreshToken:          refreshTokenValue,
+		RefreshTokenExpiresAt: refreshToken.ExpiredAt.Format(time.RFC3339),
 	}, nil
 }
 
 // Create user
-func (us userService) Create(req requests.UserCreation) (entities.User, *utils.HTTPError) {
+func (us userService) Create(ctx context.Context, p entities.Principal, req requests.UserCreation) (entities.User, *errs.Error) {
 	creationErrors := utils.ValidateStruct(req)
 	if creationErrors != nil {
-		return entities.User{}, utils.NewHTTPError(utils.StatusBadRequest, "Invalid body", creationErrors, nil)
+		return entities.User{}, errs.Validation("Invalid body", creationErrors)
 	}
 
 	newUser := entities.User{
 		Lastname:  req.Lastname,
 		Firstname: req.Firstname,
-		Password:  req.Password,
+		Password:  req.Password.String(),
 		Username:  req.Username,
 	}
 
-	if err := us.userRepository.Create(&newUser); err != nil {
-		return entities.User{}, utils.NewHTTPError(utils.StatusInternalServerError, "Database error", "Error during user creation", err)
+	// The user is not created if the default role cannot be assigned
+	var verificationToken string
+	errTx := us.withinTx(ctx, func(repos repositories.Repositories) error {
+		err := repos.Users.Create(ctx, &newUser)
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return errs.Conflict("Username already exists", nil)
+		}
+		if err != nil {
+			return errs.Internal("Database error", "Error during user creation", err)
+		}
+
+		if err := repos.Users.AssignRoles(ctx, newUser.ID, entities.RoleUser); err != nil {
+			return errs.Internal("Database error", "Error during user role assignment", err)
+		}
+
+		verificationToken, err = us.createEmailVerification(ctx, repos.Users, newUser.ID, newUser.Username)
+		return err
+	}, "Error during user creation")
+	if errTx != nil {
+		return entities.User{}, errTx
 	}
+	newUser.Roles = []entities.Role{{Name: entities.RoleUser}}
+
+	us.sendEmailVerification(newUser.Username, verificationToken)
 
 	return newUser, nil
 }
 
 // GetAll returns all users
-func (us userService) GetAll(req requests.Pagination) (responses.UsersListPaginated, *utils.HTTPError) {
-	users, total, err := us.userRepository.GetAll(req.Page, req.Limit, req.Sorts)
+func (us userService) GetAll(ctx context.Context, p entities.Principal, req requests.Pagination) (responses.UsersListPaginated, *errs.Error) {
+	users, pagination, err := us.userRepository.GetAll(ctx, req)
+	var invalidParams utils.ValidatorErrors
+	if errors.As(err, &invalidParams) {
+		return responses.UsersListPaginated{}, errs.Validation("Invalid parameters", invalidParams)
+	}
 	if err != nil {
-		return responses.UsersListPaginated{}, utils.NewHTTPError(utils.StatusInternalServerError, "Database error", "Error when getting all users", err)
+		return responses.UsersListPaginated{}, errs.Internal("Database error", "Error when getting all users", err)
 	}
 
 	return responses.UsersListPaginated{
-		Data:  users,
-		Total: total,
+		Data:       users,
+		Pagination: pagination,
 	}, nil
 }
 
 // GetByID returns a user from its ID
-func (us userService) GetByID(req requests.UserByID) (entities.User, *utils.HTTPError) {
+// It requires the users:read permission, users manage their own account with the /me endpoints.
+func (us userService) GetByID(ctx context.Context, p entities.Principal, req requests.UserByID) (entities.User, *errs.Error) {
 	validateID := utils.ValidateStruct(req)
 	if validateID != nil {
-		return entities.User{}, utils.NewHTTPError(utils.StatusBadRequest, "Invalid parameters", validateID, nil)
+		return entities.User{}, errs.Validation("Invalid parameters", validateID)
+	}
+
+	if !p.HasPermission(entities.PermissionUsersRead) {
+		return entities.User{}, errs.Forbidden()
 	}
 
-	user, err := us.userRepository.GetByID(req.ID)
+	user, err := us.userRepository.GetByID(ctx, req.ID)
 	if err != nil {
-		return entities.User{}, utils.NewHTTPError(utils.StatusInternalServerError, "Database error", "Error when getting user by id", err)
+		return entities.User{}, errs.Internal("Database error", "Error when getting user by id", err)
 	}
 
 	if user.ID == "" {
-		return entities.User{}, utils.NewHTTPError(utils.StatusNotFound, "No user found", nil, nil)
+		return entities.User{}, errs.NotFound("No user found")
 	}
 
 	return user, nil
 }
 
 // Delete user
-func (us userService) Delete(req requests.UserByID) *utils.HTTPError {
+// It requires the users:delete permission, users manage their own account with the /me endpoints.
+func (us userService) Delete(ctx context.Context, p entities.Principal, req requests.UserByID) *errs.Error {
 	validateID := utils.ValidateStruct(req)
 	if validateID != nil {
-		return utils.NewHTTPError(utils.StatusBadRequest, "Invalid parameters", validateID, nil)
+		return errs.Validation("Invalid parameters", validateID)
 	}
 
-	if err := us.userRepository.Delete(req.ID); err != nil {
-		return utils.NewHTTPError(utils.StatusInternalServerError, "Database error", "Error when deleting the user", err)
+	if !p.HasPermission(entities.PermissionUsersDelete) {
+		return errs.Forbidden()
 	}
 
-	return nil
+	return us.withinTx(ctx, func(repos repositories.Repositories) error {
+		if err := revokeAllTokens(ctx, repos.Users, req.ID); err != nil {
+			return err
+		}
+
+		if err := repos.Users.Delete(ctx, req.ID); err != nil {
+			return errs.Internal("Database error", "Error when deleting the user", err)
+		}
+		return nil
+	}, "Error when deleting the user")
 }
 
 // Update user
-func (us userService) Update(req requests.UserUpdate) (entities.User, *utils.HTTPError) {
+// It requires the users:update permission, users manage their own account with the /me endpoints.
+func (us userService) Update(ctx context.Context, p entities.Principal, req requests.UserUpdate) (entities.User, *errs.Error) {
 	validateID := utils.ValidateStruct(req)
 	if validateID != nil {
-		return entities.User{}, utils.NewHTTPError(utils.StatusBadRequest, "Invalid parameters", validateID, nil)
+		return entities.User{}, errs.Validation("Invalid parameters", validateID)
+	}
+
+	if !p.HasPermission(entities.PermissionUsersUpdate) {
+		return entities.User{}, errs.Forbidden()
+	}
+
+	current, err := us.userRepository.GetByID(ctx, req.ID)
+	if err != nil {
+		return entities.User{}, errs.Internal("Database error", "Error when getting user by id", err)
+	}
+	if current.ID == "" {
+		return entities.User{}, errs.NotFound("No user found")
+	}
+
+	// The username is only changed once the new email has been verified
+	emailChanged := req.Username != current.Username
+	if emailChanged {
+		existing, err := us.userRepository.GetByUsername(ctx, req.Username.String())
+		if err != nil {
+			return entities.User{}, errs.Internal("Database error", "Error when retrieving user", err)
+		}
+		if existing.ID != "" {
+			return entities.User{}, errs.Conflict("Username already exists", nil)
+		}
+	}
+
+	// The tokens issued with the old password are invalidated
+	samePassword, err := us.passwordHasher.Verify(current.Password, req.Password.String())
+	if err != nil {
+		return entities.User{}, errs.Internal("Internal server error", "Error when verifying user password", err)
 	}
 
 	user := entities.User{
 		ID:        req.ID,
 		Lastname:  req.Lastname,
 		Firstname: req.Firstname,
-		Password:  req.Password,
-		Username:  req.Username,
+		Password:  req.Password.String(),
+		Username:  current.Username,
 	}
 
-	if err := us.userRepository.Update(&user); err != nil {
-		return entities.User{}, utils.NewHTTPError(utils.StatusInternalServerError, "Database error", "Error during user update", err)
+	var verificationToken string
+	errTx := us.withinTx(ctx, func(repos repositories.Repositories) error {
+		if err := repos.Users.Update(ctx, &user); err != nil {
+			return errs.Internal("Database error", "Error during user update", err)
+		}
+
+		if !samePassword {
+			if err := revokeAllTokens(ctx, repos.Users, user.ID); err != nil {
+				return err
+			}
+		}
+
+		if emailChanged {
+			var err error
+			verificationToken, err = us.createEmailVerification(ctx, repos.Users, user.ID, req.Username)
+			return err
+		}
+		return nil
+	}, "Error during user update")
+	if errTx != nil {
+		return entities.User{}, errTx
 	}
 
-	if user.ID == "" {
-		return entities.User{}, utils.NewHTTPError(utils.StatusNotFound, "No user found", nil, nil)
+	if emailChanged {
+		us.sendEmailVerification(req.Username, verificationToken)
 	}
 
 	return user, nil
 }
 
 // UpdatePassword updates user password
-func (us userService) UpdatePassword(req requests.UserPasswordUpdate) *utils.HTTPError {
+func (us userService) UpdatePassword(ctx context.Context, req requests.UserPasswordUpdate) *errs.Error {
 	validateReq := utils.ValidateStruct(req)
 	if validateReq != nil {
-		return utils.NewHTTPError(utils.StatusBadRequest, "Invalid parameters", validateReq, nil)
+		return errs.Validation("Invalid parameters", validateReq)
 	}
 
-	// Update user password
-	userID, currentPassword, err := us.userRepository.GetIDFromPasswordReset(req.Token, req.Password)
+	// Each use of a token is counted, even if it fails, so that a token can only be tried a few times
+	tokenHash := utils.HashToken(req.Token)
+	attempts, err := us.userRepository.IncrementPasswordResetAttempts(ctx, tokenHash)
 	if err != nil {
-		return utils.NewHTTPError(utils.StatusInternalServerError, "Database error", "Error when searching user", err)
+		return errs.Internal("Database error", "Error when searching user", err)
+	}
+	maxAttempts := viper.GetUint("FORGOTTEN_PASSWORD_MAX_ATTEMPTS")
+	if maxAttempts == 0 {
+		maxAttempts = DefaultPasswordResetMaxAttempts
 	}
-	if userID == "" {
-		return utils.NewHTTPError(utils.StatusNotFound, "No user found", nil, nil)
+	if attempts == 0 || attempts > maxAttempts {
+		return errs.NotFound("No user found")
+	}
+
+	// The reset token is consumed in the transaction of the password update and of the tokens revocation:
+	// concurrent requests with the same token wait for the deletion, and only one of them deletes it.
+	// If the update fails, the token is kept and can be used again.
+	return us.withinTx(ctx, func(repos repositories.Repositories) error {
+		userID, currentPassword, err := repos.Users.GetIDFromPasswordReset(ctx, tokenHash, req.Password.String())
+		if err != nil {
+			return errs.Internal("Database error", "Error when searching user", err)
+		}
+		if userID == "" {
+			return errs.NotFound("No user found")
+		}
+
+		err = repos.Users.ConsumePasswordReset(ctx, tokenHash)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errs.NotFound("No user found")
+		}
+		if err != nil {
+			return errs.Internal("Database error", "Error when deleting user password reset", err)
+		}
+
+		user, err := repos.Users.GetByID(ctx, userID)
+		if err != nil {
+			return errs.Internal("Database error", "Error when searching user", err)
+		}
+
+		return us.changePassword(ctx, repos, user, currentPassword, req.Password)
+	}, "Error when updating user password")
+}
+
+// changePassword checks the new password against the password policy and replaces the current password hash.
+// All the tokens issued with the old password and the password reset request are revoked.
+func (us userService) changePassword(ctx context.Context, repos repositories.Repositories, user entities.User, currentPassword string, password values_objects.Password) error {
+	// The password must not contain the user personal information
+	policyErrors := values_objects.CurrentPasswordPolicy().Validate("password", password.String(),
+		user.Username.String(), user.Lastname, user.Firstname)
+	if policyErrors != nil {
+		return errs.Validation("Invalid parameters", policyErrors)
 	}
 
 	// Change by the same password is forbidden
-	hashedPassword := sha512.Sum512([]byte(req.Password))
-	if hex.EncodeToString(hashedPassword[:]) == currentPassword {
-		return utils.NewHTTPError(utils.StatusBadRequest, "New password cannot be the same as the current one", nil, nil)
+	samePassword, err := us.passwordHasher.Verify(currentPassword, password.String())
+	if err != nil {
+		return errs.Internal("Internal server error", "Error when verifying user password", err)
+	}
+	if samePassword {
+		return errs.Validation("New password cannot be the same as the current one", nil)
 	}
 
-	err = us.userRepository.UpdatePassword(userID, currentPassword, req.Password)
+	err = repos.Users.UpdatePassword(ctx, user.ID, currentPassword, password.String())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errs.Conflict("Password has been changed in the meantime", nil)
+	}
 	if err != nil {
-		return utils.NewHTTPError(utils.StatusInternalServerError, "Database error", "Error when updating user password", err)
+		return errs.Internal("Database error", "Error when updating user password", err)
+	}
+
+	// Invalidate all the tokens issued with the old password
+	if err := revokeAllTokens(ctx, repos.Users, user.ID); err != nil {
+		return err
 	}
 
 	// Delete password reset
-	err = us.userRepository.DeletePasswordReset(userID)
+	err = repos.Users.DeletePasswordReset(ctx, user.ID)
 	if err != nil {
-		return utils.NewHTTPError(utils.StatusInternalServerError, "Database error", "Error when deleting user password reset", err)
+		return errs.Internal("Database error", "Error when deleting user password reset", err)
 	}
 
 	return nil
 }
 
-// ForgottenPassword save a forgotten password request
-func (us userService) ForgottenPassword(req requests.UserForgotPassword) (entities.PasswordResets, *utils.HTTPError) {
+// verifyPassword returns the user if the password is its current password.
+// field is the name of the request field reported in the validation error.
+func (us userService) verifyPassword(ctx context.Context, repo repositories.UserRepository, userID, password, field string) (entities.User, error) {
+	user, err := repo.GetByID(ctx, userID)
+	if err != nil {
+		return entities.User{}, errs.Internal("Database error", "Error when searching user", err)
+	}
+	if user.ID == "" {
+		return entities.User{}, errs.NotFound("No user found")
+	}
+
+	ok, err := us.passwordHasher.Verify(user.Password, password)
+	if err != nil {
+		return entities.User{}, errs.Internal("Internal server error", "Error when verifying user password", err)
+	}
+	if !ok {
+		return entities.User{}, errs.Validation("Invalid parameters", utils.ValidatorErrors{
+			{FailedField: field, Tag: "password_mismatch", Kind: "string"},
+		})
+	}
+
+	return user, nil
+}
+
+// Me returns the authenticated user
+func (us userService) Me(ctx context.Context, p entities.Principal) (entities.User, *errs.Error) {
+	if !p.IsAuthenticated() {
+		return entities.User{}, errs.Unauthorized()
+	}
+
+	user, err := us.userRepository.GetByID(ctx, p.ID)
+	if err != nil {
+		return entities.User{}, errs.Internal("Database error", "Error when getting user by id", err)
+	}
+	if user.ID == "" {
+		return entities.User{}, errs.NotFound("No user found")
+	}
+
+	return user, nil
+}
+
+// UpdateProfile partially updates the profile of the authenticated user, only the fields present in the request are updated
+func (us userService) UpdateProfile(ctx context.Context, p entities.Principal, req requests.UserProfileUpdate) (entities.User, *errs.Error) {
+	if !p.IsAuthenticated() {
+		return entities.User{}, errs.Unauthorized()
+	}
+
+	validateReq := utils.ValidateStruct(req)
+	if validateReq != nil {
+		return entities.User{}, errs.Validation("Invalid body", validateReq)
+	}
+
+	user, err := us.userRepository.GetByID(ctx, p.ID)
+	if err != nil {
+		return entities.User{}, errs.Internal("Database error", "Error when getting user by id", err)
+	}
+	if user.ID == "" {
+		return entities.User{}, errs.NotFound("No user found")
+	}
+
+	if req.Lastname != nil {
+		user.Lastname = *req.Lastname
+	}
+	if req.Firstname != nil {
+		user.Firstname = *req.Firstname
+	}
+
+	if err := us.userRepository.UpdateProfile(ctx, &user); err != nil {
+		return entities.User{}, errs.Internal("Database error", "Error during user update", err)
+	}
+
+	return user, nil
+}
+
+// ChangePassword changes the password of the authenticated user who must provide the current password.
+// All the tokens of the user are revoked and new tokens are returned.
+func (us userService) ChangePassword(ctx context.Context, p entities.Principal, req requests.UserMePasswordUpdate) (responses.UserLogin, *errs.Error) {
+	if !p.IsAuthenticated() {
+		return responses.UserLogin{}, errs.Unauthorized()
+	}
+
+	validateReq := utils.ValidateStruct(req)
+	if validateReq != nil {
+		return responses.UserLogin{}, errs.Validation("Invalid body", validateReq)
+	}
+
+	errTx := us.withinTx(ctx, func(repos repositories.Repositories) error {
+		user, err := us.verifyPassword(ctx, repos.Users, p.ID, req.CurrentPassword, "current_password")
+		if err != nil {
+			return err
+		}
+
+		return us.changePassword(ctx, repos, user, user.Password, req.Password)
+	}, "Error when updating user password")
+	if errTx != nil {
+		return responses.UserLogin{}, errTx
+	}
+
+	user, err := us.userRepository.GetByID(ctx, p.ID)
+	if err != nil {
+		return responses.UserLogin{}, errs.Internal("Database error", "Error when getting user by id", err)
+	}
+
+	return us.generateTokens(ctx, user, "")
+}
+
+// DeleteAccount deletes the authenticated user account, the deletion is confirmed by the user password.
+func (us userService) DeleteAccount(ctx context.Context, p entities.Principal, req requests.UserMeDeletion) *errs.Error {
+	if !p.IsAuthenticated() {
+		return errs.Unauthorized()
+	}
+
+	validateReq := utils.ValidateStruct(req)
+	if validateReq != nil {
+		return errs.Validation("Invalid body", validateReq)
+	}
+
+	return us.withinTx(ctx, func(repos repositories.Repositories) error {
+		if _, err := us.verifyPassword(ctx, repos.Users, p.ID, req.Password, "password"); err != nil {
+			return err
+		}
+
+		if err := revokeAllTokens(ctx, repos.Users, p.ID); err != nil {
+			return err
+		}
+
+		if err := repos.Users.Delete(ctx, p.ID); err != nil {
+			return errs.Internal("Database error", "Error when deleting the user", err)
+		}
+		return nil
+	}, "Error when deleting the user")
+}
+
+// ForgottenPassword saves a forgotten password request and sends the reset link by email.
+// Only the request is validated before returning, the request is processed in background, so that
+// neither the result nor the response time disclose the existing emails.
+func (us userService) ForgottenPassword(ctx context.Context, req requests.UserForgotPassword) *errs.Error {
 	validateReq := utils.ValidateStruct(req)
 	if validateReq != nil {
-		return entities.PasswordResets{}, utils.NewHTTPError(utils.StatusBadRequest, "Invalid parameters", validateReq, nil)
+		return errs.Validation("Invalid body", validateReq)
 	}
 
+	// The work must not be cancelled at the end of the request
+	ctx = context.WithoutCancel(ctx)
+	RunInBackground(func() *errs.Error {
+		return us.requestPasswordReset(ctx, req.Email)
+	})
+
+	return nil
+}
+
+// requestPasswordReset saves a password reset for the user of the email and sends the reset link to the email.
+// Nothing is done if the user does not exist.
+func (us userService) requestPasswordReset(ctx context.Context, email values_objects.Email) *errs.Error {
 	// Find user
-	user, err := us.userRepository.GetByUsername(req.Email)
+	user, err := us.userRepository.GetByUsername(ctx, email.String())
 	if err != nil {
-		return entities.PasswordResets{}, utils.NewHTTPError(utils.StatusInternalServerError, "Database error", "Error when retrieving user", err)
+		return errs.Internal("Database error", "Error when retrieving user", err)
 	}
 	if user.ID == "" {
-		return entities.PasswordResets{}, utils.NewHTTPError(utils.StatusNotFound, "No user found", nil, nil)
+		return nil
 	}
 
-	// Create password reset
-	passwordReset := entities.PasswordResets{
-		UserID:    user.ID,
-		Token:     uuid.NewString(),
-		ExpiredAt: time.Now().Add(viper.GetDuration("FORGOTTEN_PASSWORD_EXPIRATION_DURATION") * time.Hour).UTC(),
+	// Create password reset, only the token hash is stored
+	lifetime := viper.GetDuration("FORGOTTEN_PASSWORD_EXPIRATION_DURATION") * time.Hour
+	if lifetime <= 0 {
+		lifetime = DefaultPasswordResetLifetime
+	}
+	passwordReset, token, err := entities.NewPasswordReset(user.ID, lifetime)
+	if err != nil {
+		return errs.Internal("Internal server error", "Error during password reset token generation", err)
 	}
-	err = us.userRepository.CreateOrUpdatePasswordReset(passwordReset)
+	err = us.userRepository.CreateOrUpdatePasswordReset(ctx, passwordReset)
 	if err != nil {
-		return entities.PasswordResets{}, utils.NewHTTPError(utils.StatusInternalServerError, "Database error", "Error when requesting new password", err)
+		return errs.Internal("Database error", "Error when requesting new password", err)
 	}
 
 	// Send email with link
-	to := make([]string, 1)
-	to[0] = user.Username
-	subject := fmt.Sprintf("[%s] Forgotten password", viper.GetString("APP_NAME"))
-	var body bytes.Buffer
+	err = SendEmail(
+		viper.GetString("FORGOTTEN_PASSWORD_EMAIL_FROM"),
+		[]string{user.Username.String()},
+		fmt.Sprintf("[%s] Forgotten password", viper.GetString("APP_NAME")),
+		"templates/forgotten_password.gohtml",
+		emailTemplateData{
+			Title: fmt.Sprintf("%s - Forgotten password", viper.GetString("APP_NAME")),
+			Link:  fmt.Sprintf("%s/%s", viper.GetString("FORGOTTEN_PASSWORD_BASE_URL"), token),
+		})
+	if err != nil {
+		return errs.Internal("Email error", "Error when sending password reset email", err)
+	}
 
-	tp, err := template.ParseFiles("templates/forgotten_password.gohtml")
+	return nil
+}
+
+// VerifyEmail verifies the email of a user with the token sent by email.
+// On email change, the username is replaced by the verified email.
+func (us userService) VerifyEmail(ctx context.Context, req requests.UserEmailVerification) *errs.Error {
+	validateReq := utils.ValidateStruct(req)
+	if validateReq != nil {
+		return errs.Validation("Invalid parameters", validateReq)
+	}
+
+	// The token cannot be used twice
+	return us.withinTx(ctx, func(repos repositories.Repositories) error {
+		emailVerification, err := repos.Users.GetEmailVerification(ctx, utils.HashToken(req.Token))
+		if err != nil {
+			return errs.Internal("Database error", "Error when searching email verification", err)
+		}
+		if emailVerification.UserID == "" {
+			return errs.NotFound("No email verification found")
+		}
+
+		err = repos.Users.VerifyEmail(ctx, emailVerification.UserID, emailVerification.Email.String())
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return errs.Conflict("Username already exists", nil)
+		}
+		if err != nil {
+			return errs.Internal("Database error", "Error when verifying user email", err)
+		}
+
+		if err := repos.Users.DeleteEmailVerification(ctx, emailVerification.UserID); err != nil {
+			return errs.Internal("Database error", "Error when deleting user email verification", err)
+		}
+		return nil
+	}, "Error when verifying user email")
+}
+
+// createEmailVerification saves a new email verification for the email and returns the clear token.
+// Only the token hash is stored.
+func (us userService) createEmailVerification(ctx context.Context, repo repositories.UserRepository, userID string, email values_objects.Email) (string, error) {
+	lifetime := viper.GetDuration("EMAIL_VERIFICATION_EXPIRATION_DURATION") * time.Hour
+	if lifetime <= 0 {
+		lifetime = DefaultEmailVerificationLifetime
+	}
+
+	emailVerification, token, err := entities.NewEmailVerification(userID, email, lifetime)
 	if err != nil {
-		return entities.PasswordResets{}, utils.NewHTTPError(utils.StatusInternalServerError, "Email error", "Error when creating password reset email", err)
-	}
-	err = tp.Execute(&body, struct {
-		Title string
-		Link  string
-	}{
-		Title: fmt.Sprintf("%s - Forgotten password", viper.GetString("APP_NAME")),
-		Link:  fmt.Sprintf("%s/%s", viper.GetString("FORGOTTEN_PASSWORD_BASE_URL"), passwordReset.Token),
+		return "", errs.Internal("Internal server error", "Error during email verification token generation", err)
+	}
+	if err := repo.CreateOrUpdateEmailVerification(ctx, emailVerification); err != nil {
+		return "", errs.Internal("Database error", "Error when requesting email verification", err)
+	}
+
+	return token, nil
+}
+
+// sendEmailVerification sends the verification link to the email in background, once the email verification
+// has been saved, so that the transaction is not kept open during the sending and the user creation or the email
+// change does not fail if the SMTP server is down. Sending errors are only logged.
+func (us userService) sendEmailVerification(email values_objects.Email, token string) {
+	RunInBackground(func() *errs.Error {
+		err := SendEmail(
+			viper.GetString("EMAIL_VERIFICATION_EMAIL_FROM"),
+			[]string{email.String()},
+			fmt.Sprintf("[%s] Email verification", viper.GetString("APP_NAME")),
+			"templates/email_verification.gohtml",
+			emailTemplateData{
+				Title: fmt.Sprintf("%s - Email verification", viper.GetString("APP_NAME")),
+				Link:  fmt.Sprintf("%s/%s", viper.GetString("EMAIL_VERIFICATION_BASE_URL"), token),
+			})
+		if err != nil {
+			return errs.Internal("Email error", "Error when sending email verification", err)
+		}
+		return nil
 	})
+}
+
+// emailTemplateData is the data of the email templates.
+type emailTemplateData struct {
+	Title string
+	Link  string
+}
+
+// RunInBackground runs a work after the response, like sending emails. Errors are passed to LogError.
+// It is a variable so that it can be replaced, in tests for example.
+var RunInBackground = func(work func() *errs.Error) {
+	go func() {
+		if err := work(); err != nil {
+			LogError(err)
+		}
+	}()
+}
+
+// LogError logs the errors of the works run in background. It does nothing until it is set by the server.
+var LogError = func(err *errs.Error) {}
+
+// SendEmail renders an email template and sends it with the SMTP server.
+// It is a variable so that it can be replaced, in tests for example.
+var SendEmail = func(from string, to []string, subject, templateFile string, data interface{}) error {
+	tp, err := template.ParseFiles(templateFile)
 	if err != nil {
-		return entities.PasswordResets{}, utils.NewHTTPError(utils.StatusInternalServerError, "Email error", "Error when creating password reset email", err)
+		return err
 	}
 
-	err = mail.Send(
-		viper.GetString("FORGOTTEN_PASSWORD_EMAIL_FROM"),
+	var body bytes.Buffer
+	if err := tp.Execute(&body, data); err != nil {
+		return err
+	}
+
+	return mail.Send(
+		from,
 		to,
 		nil,
 		nil,
@@ -262,9 +861,4 @@ func (us userService) ForgottenPassword(req requests.UserForgotPassword) (entiti
 		"",
 		viper.GetString("SMTP_HOST"),
 		viper.GetInt("SMTP_PORT"))
-	if err != nil {
-		return entities.PasswordResets{}, utils.NewHTTPError(utils.StatusInternalServerError, "Email error", "Error when sending password reset email", err)
-	}
-
-	return passwordReset, nil
 }
@@ -0,0 +1,631 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/stores/memory"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/errs"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/repositories"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
+	values_objects "github.com/fabienbellanger/fiber-boilerplate/pkg/domain/value_objects"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// ctx is the context of the tests.
+var ctx = context.Background()
+
+// sentEmails records the emails sent by the tests.
+var sentEmails []sentEmail
+
+// sentEmail is an email sent by a test.
+type sentEmail struct {
+	to   []string
+	data emailTemplateData
+}
+
+// lastEmailToken returns the token of the link of the last sent email.
+func lastEmailToken(t *testing.T) string {
+	if !assert.NotEmpty(t, sentEmails) {
+		return ""
+	}
+	return path.Base(sentEmails[len(sentEmails)-1].data.Link)
+}
+
+// newTestUserService returns a user service using an in-memory store with a user.
+// Emails are recorded in sentEmails instead of being sent.
+func newTestUserService(t *testing.T) (UserService, *memory.UserStore, entities.User) {
+	viper.Set("JWT_ALGO", "HS512")
+	viper.Set("JWT_SECRET", "mySecretForTest")
+
+	sentEmails = nil
+	sendEmail := SendEmail
+	SendEmail = func(from string, to []string, subject, templateFile string, data interface{}) error {
+		sentEmails = append(sentEmails, sentEmail{to: to, data: data.(emailTemplateData)})
+		return nil
+	}
+	t.Cleanup(func() { SendEmail = sendEmail })
+
+	runInBackground := RunInBackground
+	RunInBackground = func(work func() *errs.Error) {
+		assert.Nil(t, work())
+	}
+	t.Cleanup(func() { RunInBackground = runInBackground })
+
+	hasher := utils.NewPasswordHasher(utils.PasswordAlgoBcrypt)
+	store := memory.NewUserStore(hasher)
+
+	user := entities.User{Username: "john@test.com", Password: "old-secret-0", Lastname: "Doe", Firstname: "John"}
+	assert.Nil(t, store.Create(ctx, &user))
+	assert.Nil(t, store.AssignRoles(ctx, user.ID, entities.RoleUser))
+
+	return NewUser(store, memory.NewTxManager(store, memory.NewTaskStore()), hasher), store, user
+}
+
+func TestUserServiceLogin(t *testing.T) {
+	service, _, user := newTestUserService(t)
+
+	res, err := service.Login(ctx, requests.UserLogin{Username: "john@test.com", Password: "old-secret-0"})
+	assert.Nil(t, err)
+	assert.Equal(t, user.ID, res.User.ID)
+	assert.NotEmpty(t, res.Token)
+	assert.NotEmpty(t, res.RefreshToken)
+
+	_, err = service.Login(ctx, requests.UserLogin{Username: "john@test.com", Password: "new-secret-1"})
+	assert.Equal(t, errs.KindUnauthorized, err.Kind)
+
+	_, err = service.Login(ctx, requests.UserLogin{Username: "john", Password: "old-secret-0"})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+}
+
+func TestUserServiceRefreshToken(t *testing.T) {
+	service, _, _ := newTestUserService(t)
+
+	login, err := service.Login(ctx, requests.UserLogin{Username: "john@test.com", Password: "old-secret-0"})
+	assert.Nil(t, err)
+
+	refreshed, err := service.RefreshToken(ctx, requests.TokenRefresh{RefreshToken: login.RefreshToken})
+	assert.Nil(t, err)
+	assert.NotEqual(t, login.RefreshToken, refreshed.RefreshToken)
+
+	// Reuse of a refresh token revokes the whole family
+	_, err = service.RefreshToken(ctx, requests.TokenRefresh{RefreshToken: login.RefreshToken})
+	assert.Equal(t, errs.KindUnauthorized, err.Kind)
+	_, err = service.RefreshToken(ctx, requests.TokenRefresh{RefreshToken: refreshed.RefreshToken})
+	assert.Equal(t, errs.KindUnauthorized, err.Kind)
+
+	_, err = service.RefreshToken(ctx, requests.TokenRefresh{RefreshToken: "unknown"})
+	assert.Equal(t, errs.KindUnauthorized, err.Kind)
+
+	_, err = service.RefreshToken(ctx, requests.TokenRefresh{})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+}
+
+func TestUserServiceRefreshTokenRotation(t *testing.T) {
+	service, store, _ := newTestUserService(t)
+
+	login, err := service.Login(ctx, requests.UserLogin{Username: "john@test.com", Password: "old-secret-0"})
+	assert.Nil(t, err)
+
+	// Each rotation issues a new pair in the same family
+	refreshed, err := service.RefreshToken(ctx, requests.TokenRefresh{RefreshToken: login.RefreshToken})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, refreshed.Token)
+	assert.NotEqual(t, login.Token, refreshed.Token)
+	assert.NotEqual(t, login.RefreshToken, refreshed.RefreshToken)
+
+	first, _ := store.GetRefreshToken(ctx, utils.HashToken(login.RefreshToken))
+	second, _ := store.GetRefreshToken(ctx, utils.HashToken(refreshed.RefreshToken))
+	assert.NotNil(t, first.UsedAt)
+	assert.Nil(t, second.UsedAt)
+	assert.Equal(t, first.FamilyID, second.FamilyID)
+
+	// Other families are not revoked by a replay
+	other, err := service.Login(ctx, requests.UserLogin{Username: "john@test.com", Password: "old-secret-0"})
+	assert.Nil(t, err)
+
+	_, err = service.RefreshToken(ctx, requests.TokenRefresh{RefreshToken: login.RefreshToken})
+	assert.Equal(t, errs.KindUnauthorized, err.Kind)
+
+	second, _ = store.GetRefreshToken(ctx, utils.HashToken(refreshed.RefreshToken))
+	assert.NotNil(t, second.RevokedAt)
+
+	_, err = service.RefreshToken(ctx, requests.TokenRefresh{RefreshToken: other.RefreshToken})
+	assert.Nil(t, err)
+
+	// Revoked token
+	login, err = service.Login(ctx, requests.UserLogin{Username: "john@test.com", Password: "old-secret-0"})
+	assert.Nil(t, err)
+	revoked, _ := store.GetRefreshToken(ctx, utils.HashToken(login.RefreshToken))
+	assert.Nil(t, store.RevokeRefreshTokenFamily(ctx, revoked.FamilyID))
+
+	_, err = service.RefreshToken(ctx, requests.TokenRefresh{RefreshToken: login.RefreshToken})
+	assert.Equal(t, errs.KindUnauthorized, err.Kind)
+}
+
+func TestUserServiceRefreshTokenExpired(t *testing.T) {
+	service, store, user := newTestUserService(t)
+
+	// An expired token which has never been used is rejected without revoking its family
+	expired, expiredValue, err := entities.NewRefreshToken(user.ID, "", -time.Minute)
+	assert.Nil(t, err)
+	assert.Nil(t, store.CreateRefreshToken(ctx, &expired))
+	sibling, siblingValue, err := entities.NewRefreshToken(user.ID, expired.FamilyID, time.Hour)
+	assert.Nil(t, err)
+	assert.Nil(t, store.CreateRefreshToken(ctx, &sibling))
+
+	_, errRefresh := service.RefreshToken(ctx, requests.TokenRefresh{RefreshToken: expiredValue})
+	assert.Equal(t, errs.KindUnauthorized, errRefresh.Kind)
+
+	sibling, _ = store.GetRefreshToken(ctx, sibling.TokenHash)
+	assert.Nil(t, sibling.RevokedAt)
+
+	// The replay of an expired token which has already been used revokes its family
+	rotated, err := service.RefreshToken(ctx, requests.TokenRefresh{RefreshToken: siblingValue})
+	assert.Nil(t, err)
+
+	sibling, _ = store.GetRefreshToken(ctx, sibling.TokenHash)
+	sibling.ExpiredAt = time.Now().Add(-time.Minute).UTC()
+	assert.Nil(t, store.CreateRefreshToken(ctx, &sibling))
+
+	_, errRefresh = service.RefreshToken(ctx, requests.TokenRefresh{RefreshToken: siblingValue})
+	assert.Equal(t, errs.KindUnauthorized, errRefresh.Kind)
+
+	_, errRefresh = service.RefreshToken(ctx, requests.TokenRefresh{RefreshToken: rotated.RefreshToken})
+	assert.Equal(t, errs.KindUnauthorized, errRefresh.Kind)
+}
+
+func TestUserServiceCreate(t *testing.T) {
+	service, store, _ := newTestUserService(t)
+
+	user, err := service.Create(ctx, entities.Principal{}, requests.UserCreation{
+		Username:  "jane@test.com",
+		Password:  "old-secret-0",
+		Lastname:  "Doe",
+		Firstname: "Jane",
+	})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, user.ID)
+
+	created, _ := store.GetByID(ctx, user.ID)
+	assert.Equal(t, []string{entities.RoleUser}, created.RoleNames())
+	assert.False(t, created.IsEmailVerified())
+
+	// A verification email is sent
+	assert.Len(t, sentEmails, 1)
+	assert.Equal(t, []string{"jane@test.com"}, sentEmails[0].to)
+
+	_, err = service.Create(ctx, entities.Principal{}, requests.UserCreation{Username: "jane@test.com", Password: "0000"})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+
+	// Password containing the user name
+	_, err = service.Create(ctx, entities.Principal{}, requests.UserCreation{
+		Username:  "jack@test.com",
+		Password:  "secret-Jack-0",
+		Lastname:  "Doe",
+		Firstname: "Jack",
+	})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+	assert.Equal(t, "password_personal", err.Details.(utils.ValidatorErrors)[0].Tag)
+
+	// Duplicated username
+	_, err = service.Create(ctx, entities.Principal{}, requests.UserCreation{
+		Username:  "john@test.com",
+		Password:  "old-secret-0",
+		Lastname:  "Doe",
+		Firstname: "John",
+	})
+	assert.Equal(t, errs.KindConflict, err.Kind)
+	assert.True(t, errors.Is(err, errs.ErrConflict))
+}
+
+func TestUserServiceCreateWithEmailError(t *testing.T) {
+	service, store, _ := newTestUserService(t)
+
+	// The SMTP server is down
+	SendEmail = func(from string, to []string, subject, templateFile string, data interface{}) error {
+		return errors.New("connection refused")
+	}
+	var backgroundErrors []*errs.Error
+	RunInBackground = func(work func() *errs.Error) {
+		if err := work(); err != nil {
+			backgroundErrors = append(backgroundErrors, err)
+		}
+	}
+
+	// The user is created and the email error is only reported in background
+	user, err := service.Create(ctx, entities.Principal{}, requests.UserCreation{
+		Username:  "jane@test.com",
+		Password:  "old-secret-0",
+		Lastname:  "Doe",
+		Firstname: "Jane",
+	})
+	assert.Nil(t, err)
+
+	created, _ := store.GetByID(ctx, user.ID)
+	assert.Equal(t, user.ID, created.ID)
+	if assert.Len(t, backgroundErrors, 1) {
+		assert.Equal(t, "Email error", backgroundErrors[0].Message)
+	}
+}
+
+func TestUserServiceUpdate(t *testing.T) {
+	service, store, user := newTestUserService(t)
+	req := requests.UserUpdate{
+		ID:        user.ID,
+		Username:  "john.doe@test.com",
+		Password:  "new-secret-1",
+		Lastname:  "Doe",
+		Firstname: "Johnny",
+	}
+
+	admin := entities.Principal{ID: "2c1a7b0e-5f0e-4f4c-9a63-2a1f1e3b7c9d", Permissions: []string{entities.PermissionUsersUpdate}}
+
+	// Users update their own account with the /me endpoints
+	_, err := service.Update(ctx, entities.Principal{ID: user.ID}, req)
+	assert.Equal(t, errs.KindForbidden, err.Kind)
+
+	updated, err := service.Update(ctx, admin, req)
+	assert.Nil(t, err)
+	assert.Equal(t, "Johnny", updated.Firstname)
+
+	// The tokens issued with the old password are revoked
+	updated, _ = store.GetByID(ctx, user.ID)
+	assert.Equal(t, user.TokenVersion+1, updated.TokenVersion)
+
+	// The username is changed once the new email has been verified
+	assert.Equal(t, "john@test.com", updated.Username.String())
+	_, err = service.Login(ctx, requests.UserLogin{Username: "john@test.com", Password: "new-secret-1"})
+	assert.Nil(t, err)
+
+	assert.Len(t, sentEmails, 1)
+	assert.Equal(t, []string{"john.doe@test.com"}, sentEmails[0].to)
+	assert.Nil(t, service.VerifyEmail(ctx, requests.UserEmailVerification{Token: lastEmailToken(t)}))
+
+	_, err = service.Login(ctx, requests.UserLogin{Username: "john.doe@test.com", Password: "new-secret-1"})
+	assert.Nil(t, err)
+
+	// No email is sent if the email does not change and the tokens are kept if the password does not change
+	_, err = service.Update(ctx, admin, req)
+	assert.Nil(t, err)
+	assert.Len(t, sentEmails, 1)
+	notRevoked, _ := store.GetByID(ctx, user.ID)
+	assert.Equal(t, user.TokenVersion+1, notRevoked.TokenVersion)
+
+	// Other user without permission
+	_, err = service.Update(ctx, entities.Principal{ID: "2c1a7b0e-5f0e-4f4c-9a63-2a1f1e3b7c9d"}, req)
+	assert.Equal(t, errs.KindForbidden, err.Kind)
+
+	// Unknown user
+	req.ID = "2c1a7b0e-5f0e-4f4c-9a63-2a1f1e3b7c9d"
+	_, err = service.Update(ctx, admin, req)
+	assert.Equal(t, errs.KindNotFound, err.Kind)
+}
+
+func TestUserServiceVerifyEmail(t *testing.T) {
+	service, store, user := newTestUserService(t)
+
+	_, err := service.Create(ctx, entities.Principal{}, requests.UserCreation{
+		Username:  "jane@test.com",
+		Password:  "old-secret-0",
+		Lastname:  "Doe",
+		Firstname: "Jane",
+	})
+	assert.Nil(t, err)
+	token := lastEmailToken(t)
+
+	// Login is blocked for unverified accounts if required
+	viper.Set("EMAIL_VERIFICATION_REQUIRED", true)
+	t.Cleanup(func() { viper.Set("EMAIL_VERIFICATION_REQUIRED", false) })
+
+	_, err = service.Login(ctx, requests.UserLogin{Username: "jane@test.com", Password: "old-secret-0"})
+	assert.Equal(t, errs.KindForbidden, err.Kind)
+
+	err = service.VerifyEmail(ctx, requests.UserEmailVerification{Token: ""})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+	err = service.VerifyEmail(ctx, requests.UserEmailVerification{Token: "bad"})
+	assert.Equal(t, errs.KindNotFound, err.Kind)
+
+	// Only the token hash is stored
+	emailVerification, errStore := store.GetEmailVerification(ctx, token)
+	assert.Nil(t, errStore)
+	assert.Empty(t, emailVerification.UserID)
+
+	assert.Nil(t, service.VerifyEmail(ctx, requests.UserEmailVerification{Token: token}))
+
+	_, err = service.Login(ctx, requests.UserLogin{Username: "jane@test.com", Password: "old-secret-0"})
+	assert.Nil(t, err)
+
+	// The token cannot be used twice
+	err = service.VerifyEmail(ctx, requests.UserEmailVerification{Token: token})
+	assert.Equal(t, errs.KindNotFound, err.Kind)
+
+	// The new email has been taken by another user in the meantime
+	_, err = service.Update(ctx, entities.Principal{ID: user.ID, Permissions: []string{entities.PermissionUsersUpdate}}, requests.UserUpdate{
+		ID:        user.ID,
+		Username:  "jack@test.com",
+		Password:  "new-secret-1",
+		Lastname:  "Doe",
+		Firstname: "John",
+	})
+	assert.Nil(t, err)
+	token = lastEmailToken(t)
+
+	assert.Nil(t, store.Create(ctx, &entities.User{Username: "jack@test.com", Password: "old-secret-0", Lastname: "Doe", Firstname: "Jack"}))
+	err = service.VerifyEmail(ctx, requests.UserEmailVerification{Token: token})
+	assert.Equal(t, errs.KindConflict, err.Kind)
+}
+
+func TestUserServicePasswordReset(t *testing.T) {
+	service, store, user := newTestUserService(t)
+
+	// Unknown emails are not disclosed
+	assert.Nil(t, service.ForgottenPassword(ctx, requests.UserForgotPassword{Email: "unknown@test.com"}))
+	assert.Empty(t, sentEmails)
+
+	err := service.ForgottenPassword(ctx, requests.UserForgotPassword{Email: "unknown"})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+
+	assert.Nil(t, service.ForgottenPassword(ctx, requests.UserForgotPassword{Email: "john@test.com"}))
+	assert.Equal(t, []string{"john@test.com"}, sentEmails[0].to)
+	token := lastEmailToken(t)
+
+	// Only the token hash is stored
+	userID, _, errReset := store.GetIDFromPasswordReset(ctx, token, "")
+	assert.Nil(t, errReset)
+	assert.Empty(t, userID)
+
+	// Password containing the user email
+	err = service.UpdatePassword(ctx, requests.UserPasswordUpdate{Token: token, Password: "john-secret-1"})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+	assert.Equal(t, "password_personal", err.Details.(utils.ValidatorErrors)[0].Tag)
+
+	// Same password
+	err = service.UpdatePassword(ctx, requests.UserPasswordUpdate{Token: token, Password: "old-secret-0"})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+
+	err = service.UpdatePassword(ctx, requests.UserPasswordUpdate{Token: token, Password: "new-secret-1"})
+	assert.Nil(t, err)
+
+	_, err = service.Login(ctx, requests.UserLogin{Username: "john@test.com", Password: "new-secret-1"})
+	assert.Nil(t, err)
+
+	// Tokens issued before are invalidated
+	updated, _ := store.GetByID(ctx, user.ID)
+	assert.Equal(t, user.TokenVersion+1, updated.TokenVersion)
+
+	// The token cannot be used twice
+	err = service.UpdatePassword(ctx, requests.UserPasswordUpdate{Token: token, Password: "other-secret-2"})
+	assert.Equal(t, errs.KindNotFound, err.Kind)
+
+	// Expired token
+	assert.Nil(t, store.CreateOrUpdatePasswordReset(ctx, entities.PasswordResets{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken("expired-token"),
+		ExpiredAt: time.Now().Add(-time.Minute).UTC(),
+	}))
+	err = service.UpdatePassword(ctx, requests.UserPasswordUpdate{Token: "expired-token", Password: "other-secret-2"})
+	assert.Equal(t, errs.KindNotFound, err.Kind)
+}
+
+func TestUserServiceForgottenPasswordSameWork(t *testing.T) {
+	service, _, _ := newTestUserService(t)
+
+	var works []func() *errs.Error
+	RunInBackground = func(work func() *errs.Error) {
+		works = append(works, work)
+	}
+
+	// Whether the email exists or not, the request is only validated before returning
+	assert.Nil(t, service.ForgottenPassword(ctx, requests.UserForgotPassword{Email: "unknown@test.com"}))
+	assert.Nil(t, service.ForgottenPassword(ctx, requests.UserForgotPassword{Email: "john@test.com"}))
+	assert.Len(t, works, 2)
+	assert.Empty(t, sentEmails)
+
+	// The email is sent in background, only to the existing user
+	for _, work := range works {
+		assert.Nil(t, work())
+	}
+	if assert.Len(t, sentEmails, 1) {
+		assert.Equal(t, []string{"john@test.com"}, sentEmails[0].to)
+	}
+}
+
+func TestUserServicePasswordResetMaxAttempts(t *testing.T) {
+	service, _, _ := newTestUserService(t)
+	viper.Set("FORGOTTEN_PASSWORD_MAX_ATTEMPTS", 2)
+	t.Cleanup(func() { viper.Set("FORGOTTEN_PASSWORD_MAX_ATTEMPTS", nil) })
+
+	assert.Nil(t, service.ForgottenPassword(ctx, requests.UserForgotPassword{Email: "john@test.com"}))
+	token := lastEmailToken(t)
+
+	// Failed attempts are counted
+	for i := 0; i < 2; i++ {
+		err := service.UpdatePassword(ctx, requests.UserPasswordUpdate{Token: token, Password: "old-secret-0"})
+		assert.Equal(t, errs.KindValidation, err.Kind)
+	}
+
+	err := service.UpdatePassword(ctx, requests.UserPasswordUpdate{Token: token, Password: "new-secret-1"})
+	assert.Equal(t, errs.KindNotFound, err.Kind)
+
+	// A new request gives a new token
+	assert.Nil(t, service.ForgottenPassword(ctx, requests.UserForgotPassword{Email: "john@test.com"}))
+	assert.NotEqual(t, token, lastEmailToken(t))
+	assert.Nil(t, service.UpdatePassword(ctx, requests.UserPasswordUpdate{Token: lastEmailToken(t), Password: "new-secret-1"}))
+}
+
+func TestUserServicePasswordResetConcurrentUses(t *testing.T) {
+	service, store, user := newTestUserService(t)
+
+	assert.Nil(t, service.ForgottenPassword(ctx, requests.UserForgotPassword{Email: "john@test.com"}))
+	token := lastEmailToken(t)
+
+	// Only one of the concurrent uses of the token changes the password
+	passwords := []values_objects.Password{"new-secret-1", "new-secret-2", "new-secret-3", "new-secret-4", "new-secret-5"}
+	var wg sync.WaitGroup
+	results := make(chan *errs.Error, len(passwords))
+	for _, password := range passwords {
+		wg.Add(1)
+		go func(password values_objects.Password) {
+			defer wg.Done()
+			results <- service.UpdatePassword(ctx, requests.UserPasswordUpdate{Token: token, Password: password})
+		}(password)
+	}
+	wg.Wait()
+	close(results)
+
+	updated := 0
+	for err := range results {
+		if err == nil {
+			updated++
+		} else {
+			assert.Equal(t, errs.KindNotFound, err.Kind)
+		}
+	}
+	assert.Equal(t, 1, updated)
+
+	current, _ := store.GetByID(ctx, user.ID)
+	assert.Equal(t, user.TokenVersion+1, current.TokenVersion)
+}
+
+func TestUserServiceMe(t *testing.T) {
+	service, _, user := newTestUserService(t)
+	p := entities.Principal{ID: user.ID}
+
+	me, err := service.Me(ctx, p)
+	assert.Nil(t, err)
+	assert.Equal(t, user.ID, me.ID)
+
+	_, err = service.Me(ctx, entities.Principal{})
+	assert.Equal(t, errs.KindUnauthorized, err.Kind)
+
+	// Only the fields present in the request are updated
+	lastname := "Smith"
+	updated, err := service.UpdateProfile(ctx, p, requests.UserProfileUpdate{Lastname: &lastname})
+	assert.Nil(t, err)
+	assert.Equal(t, "Smith", updated.Lastname)
+	assert.Equal(t, "John", updated.Firstname)
+	assert.Equal(t, user.Username, updated.Username)
+
+	empty := ""
+	_, err = service.UpdateProfile(ctx, p, requests.UserProfileUpdate{Firstname: &empty})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+
+	// The password is not changed
+	_, err = service.Login(ctx, requests.UserLogin{Username: "john@test.com", Password: "old-secret-0"})
+	assert.Nil(t, err)
+}
+
+func TestUserServiceChangePassword(t *testing.T) {
+	service, store, user := newTestUserService(t)
+	p := entities.Principal{ID: user.ID}
+
+	// Wrong current password
+	_, err := service.ChangePassword(ctx, p, requests.UserMePasswordUpdate{CurrentPassword: "wrong-secret", Password: "new-secret-1"})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+	assert.Equal(t, utils.ValidatorErrors{{FailedField: "current_password", Tag: "password_mismatch", Kind: "string"}}, err.Details)
+
+	// Same password
+	_, err = service.ChangePassword(ctx, p, requests.UserMePasswordUpdate{CurrentPassword: "old-secret-0", Password: "old-secret-0"})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+
+	// Password policy
+	_, err = service.ChangePassword(ctx, p, requests.UserMePasswordUpdate{CurrentPassword: "old-secret-0", Password: "password"})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+
+	assert.Nil(t, store.CreateOrUpdatePasswordReset(ctx, entities.PasswordResets{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken("c0f0d5a4-7b4e-4a53-8d6a-1f3e2b5c7d9e"),
+		ExpiredAt: time.Now().Add(time.Hour).UTC(),
+	}))
+
+	res, err := service.ChangePassword(ctx, p, requests.UserMePasswordUpdate{CurrentPassword: "old-secret-0", Password: "new-secret-1"})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, res.Token)
+	assert.NotEmpty(t, res.RefreshToken)
+
+	_, err = service.Login(ctx, requests.UserLogin{Username: "john@test.com", Password: "new-secret-1"})
+	assert.Nil(t, err)
+
+	// Tokens issued before are invalidated and the password reset is deleted
+	updated, _ := store.GetByID(ctx, user.ID)
+	assert.Equal(t, user.TokenVersion+1, updated.TokenVersion)
+
+	userID, _, errReset := store.GetIDFromPasswordReset(ctx, utils.HashToken("c0f0d5a4-7b4e-4a53-8d6a-1f3e2b5c7d9e"), "")
+	assert.Nil(t, errReset)
+	assert.Empty(t, userID)
+}
+
+func TestUserServiceDeleteAccount(t *testing.T) {
+	service, store, user := newTestUserService(t)
+	p := entities.Principal{ID: user.ID}
+
+	err := service.DeleteAccount(ctx, p, requests.UserMeDeletion{})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+
+	err = service.DeleteAccount(ctx, p, requests.UserMeDeletion{Password: "wrong-secret"})
+	assert.Equal(t, errs.KindValidation, err.Kind)
+
+	err = service.DeleteAccount(ctx, p, requests.UserMeDeletion{Password: "old-secret-0"})
+	assert.Nil(t, err)
+
+	deleted, _ := store.GetByID(ctx, user.ID)
+	assert.Empty(t, deleted.ID)
+
+	_, err = service.Login(ctx, requests.UserLogin{Username: "john@test.com", Password: "old-secret-0"})
+	assert.Equal(t, errs.KindUnauthorized, err.Kind)
+}
+
+// failingDeletePasswordResetStore is a user store whose password resets cannot be deleted.
+type failingDeletePasswordResetStore struct {
+	*memory.UserStore
+}
+
+func (s failingDeletePasswordResetStore) DeletePasswordReset(ctx context.Context, userId string) error {
+	return errors.New("delete failure")
+}
+
+// failingTxManager runs units of work with a failingDeletePasswordResetStore.
+type failingTxManager struct {
+	*memory.TxManager
+}
+
+func (t failingTxManager) WithinTx(ctx context.Context, fn func(repos repositories.Repositories) error) error {
+	return t.TxManager.WithinTx(ctx, func(repos repositories.Repositories) error {
+		repos.Users = failingDeletePasswordResetStore{repos.Users.(*memory.UserStore)}
+		return fn(repos)
+	})
+}
+
+func TestUserServicePasswordResetRollback(t *testing.T) {
+	_, store, user := newTestUserService(t)
+	hasher := utils.NewPasswordHasher(utils.PasswordAlgoBcrypt)
+	service := NewUser(store, failingTxManager{memory.NewTxManager(store, memory.NewTaskStore())}, hasher)
+
+	assert.Nil(t, store.CreateOrUpdatePasswordReset(ctx, entities.PasswordResets{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken("c0f0d5a4-7b4e-4a53-8d6a-1f3e2b5c7d9e"),
+		ExpiredAt: time.Now().Add(time.Hour).UTC(),
+	}))
+
+	err := service.UpdatePassword(ctx, requests.UserPasswordUpdate{Token: "c0f0d5a4-7b4e-4a53-8d6a-1f3e2b5c7d9e", Password: "new-secret-1"})
+	assert.Equal(t, errs.KindInternal, err.Kind)
+
+	// Nothing has changed
+	_, err = service.Login(ctx, requests.UserLogin{Username: "john@test.com", Password: "old-secret-0"})
+	assert.Nil(t, err)
+
+	notUpdated, _ := store.GetByID(ctx, user.ID)
+	assert.Equal(t, user.TokenVersion, notUpdated.TokenVersion)
+
+	userID, _, errReset := store.GetIDFromPasswordReset(ctx, utils.HashToken("c0f0d5a4-7b4e-4a53-8d6a-1f3e2b5c7d9e"), "")
+	assert.Nil(t, errReset)
+	assert.Equal(t, user.ID, userID)
+}
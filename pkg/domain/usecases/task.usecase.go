@@ -1,19 +1,26 @@
 package usecases
 
 import (
+	"context"
 	"database/sql"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/errs"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/responses"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/services"
-	"github.com/fabienbellanger/fiber-boilerplate/utils"
 )
 
 type Task interface {
-	GetAll(req requests.Pagination) (responses.TasksListPaginated, *utils.HTTPError)
-	Create(req requests.TaskCreation) (entities.Task, *utils.HTTPError)
-	GetAllStream() (*sql.Rows, *utils.HTTPError)
-	ScanTask(rows *sql.Rows, task *entities.Task) *utils.HTTPError
+	GetAll(ctx context.Context, p entities.Principal, req requests.TaskList) (responses.TasksListPaginated, *errs.Error)
+	Create(ctx context.Context, p entities.Principal, req requests.TaskCreation) (entities.Task, *errs.Error)
+	GetByID(ctx context.Context, p entities.Principal, req requests.TaskByID) (entities.Task, *errs.Error)
+	Update(ctx context.Context, p entities.Principal, req requests.TaskUpdate) (entities.Task, *errs.Error)
+	Patch(ctx context.Context, p entities.Principal, req requests.TaskPatch) (entities.Task, *errs.Error)
+	Delete(ctx context.Context, p entities.Principal, req requests.TaskByID) *errs.Error
+	Restore(ctx context.Context, p entities.Principal, req requests.TaskByID) (entities.Task, *errs.Error)
+	Transition(ctx context.Context, p entities.Principal, req requests.TaskTransition) (entities.Task, *errs.Error)
+	GetAllStream(ctx context.Context, p entities.Principal, req requests.TaskOwner) (*sql.Rows, *errs.Error)
+	ScanTask(ctx context.Context, rows *sql.Rows, task *entities.Task) *errs.Error
 }
 
 type taskUseCase struct {
@@ -26,21 +33,51 @@ func NewTask(taskService services.TaskService) Task {
 }
 
 // GetAll tasks
-func (uc *taskUseCase) GetAll(req requests.Pagination) (responses.TasksListPaginated, *utils.HTTPError) {
-	return uc.taskService.GetAll(req)
+func (uc *taskUseCase) GetAll(ctx context.Context, p entities.Principal, req requests.TaskList) (responses.TasksListPaginated, *errs.Error) {
+	return uc.taskService.GetAll(ctx, p, req)
 }
 
 // Create task
-func (uc *taskUseCase) Create(req requests.TaskCreation) (entities.Task, *utils.HTTPError) {
-	return uc.taskService.Create(req)
+func (uc *taskUseCase) Create(ctx context.Context, p entities.Principal, req requests.TaskCreation) (entities.Task, *errs.Error) {
+	return uc.taskService.Create(ctx, p, req)
+}
+
+// GetByID returns a task
+func (uc *taskUseCase) GetByID(ctx context.Context, p entities.Principal, req requests.TaskByID) (entities.Task, *errs.Error) {
+	return uc.taskService.GetByID(ctx, p, req)
+}
+
+// Update task
+func (uc *taskUseCase) Update(ctx context.Context, p entities.Principal, req requests.TaskUpdate) (entities.Task, *errs.Error) {
+	return uc.taskService.Update(ctx, p, req)
+}
+
+// Patch task
+func (uc *taskUseCase) Patch(ctx context.Context, p entities.Principal, req requests.TaskPatch) (entities.Task, *errs.Error) {
+	return uc.taskService.Patch(ctx, p, req)
+}
+
+// Delete task
+func (uc *taskUseCase) Delete(ctx context.Context, p entities.Principal, req requests.TaskByID) *errs.Error {
+	return uc.taskService.Delete(ctx, p, req)
+}
+
+// Restore task
+func (uc *taskUseCase) Restore(ctx context.Context, p entities.Principal, req requests.TaskByID) (entities.Task, *errs.Error) {
+	return uc.taskService.Restore(ctx, p, req)
+}
+
+// Transition changes task state
+func (uc *taskUseCase) Transition(ctx context.Context, p entities.Principal, req requests.TaskTransition) (entities.Task, *errs.Error) {
+	return uc.taskService.Transition(ctx, p, req)
 }
 
 // GetAllStream tasks
-func (uc *taskUseCase) GetAllStream() (*sql.Rows, *utils.HTTPError) {
-	return uc.taskService.GetAllStream()
+func (uc *taskUseCase) GetAllStream(ctx context.Context, p entities.Principal, req requests.TaskOwner) (*sql.Rows, *errs.Error) {
+	return uc.taskService.GetAllStream(ctx, p, req)
 }
 
 // ScanTask tasks
-func (uc *taskUseCase) ScanTask(rows *sql.Rows, task *entities.Task) *utils.HTTPError {
-	return uc.taskService.ScanTask(rows, task)
+func (uc *taskUseCase) ScanTask(ctx context.Context, rows *sql.Rows, task *entities.Task) *errs.Error {
+	return uc.taskService.ScanTask(ctx, rows, task)
 }
@@ -1,22 +1,32 @@
 package usecases
 
 import (
+	"context"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/errs"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/responses"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/services"
-	"github.com/fabienbellanger/fiber-boilerplate/utils"
 )
 
 type User interface {
-	Login(req requests.UserLogin) (responses.UserLogin, *utils.HTTPError)
-	Create(req requests.UserCreation) (entities.User, *utils.HTTPError)
-	GetAll(req requests.Pagination) (responses.UsersListPaginated, *utils.HTTPError)
-	GetByID(id requests.UserByID) (entities.User, *utils.HTTPError)
-	Delete(id requests.UserByID) *utils.HTTPError
-	Update(req requests.UserUpdate) (entities.User, *utils.HTTPError)
-	UpdatePassword(req requests.UserPasswordUpdate) *utils.HTTPError
-	ForgottenPassword(req requests.UserForgotPassword) (entities.PasswordResets, *utils.HTTPError)
+	Login(ctx context.Context, req requests.UserLogin) (responses.UserLogin, *errs.Error)
+	RefreshToken(ctx context.Context, req requests.TokenRefresh) (responses.UserLogin, *errs.Error)
+	CheckToken(ctx context.Context, req requests.UserToken) *errs.Error
+	Logout(ctx context.Context, p entities.Principal, req requests.UserLogout) *errs.Error
+	LogoutAll(ctx context.Context, p entities.Principal) *errs.Error
+	Create(ctx context.Context, p entities.Principal, req requests.UserCreation) (entities.User, *errs.Error)
+	GetAll(ctx context.Context, p entities.Principal, req requests.Pagination) (responses.UsersListPaginated, *errs.Error)
+	GetByID(ctx context.Context, p entities.Principal, id requests.UserByID) (entities.User, *errs.Error)
+	Delete(ctx context.Context, p entities.Principal, id requests.UserByID) *errs.Error
+	Update(ctx context.Context, p entities.Principal, req requests.UserUpdate) (entities.User, *errs.Error)
+	UpdatePassword(ctx context.Context, req requests.UserPasswordUpdate) *errs.Error
+	ForgottenPassword(ctx context.Context, req requests.UserForgotPassword) *errs.Error
+	Me(ctx context.Context, p entities.Principal) (entities.User, *errs.Error)
+	UpdateProfile(ctx context.Context, p entities.Principal, req requests.UserProfileUpdate) (entities.User, *errs.Error)
+	ChangePassword(ctx context.Context, p entities.Principal, req requests.UserMePasswordUpdate) (responses.UserLogin, *errs.Error)
+	DeleteAccount(ctx context.Context, p entities.Principal, req requests.UserMeDeletion) *errs.Error
+	VerifyEmail(ctx context.Context, req requests.UserEmailVerification) *errs.Error
 }
 
 type userUseCase struct {
@@ -29,41 +39,86 @@ func NewUser(userService services.UserService) User {
 }
 
 // Login user
-func (uc *userUseCase) Login(req requests.UserLogin) (responses.UserLogin, *utils.HTTPError) {
-	return uc.userService.Login(req)
+func (uc *userUseCase) Login(ctx context.Context, req requests.UserLogin) (responses.UserLogin, *errs.Error) {
+	return uc.userService.Login(ctx, req)
+}
+
+// RefreshToken user
+func (uc *userUseCase) RefreshToken(ctx context.Context, req requests.TokenRefresh) (responses.UserLogin, *errs.Error) {
+	return uc.userService.RefreshToken(ctx, req)
+}
+
+// CheckToken user
+func (uc *userUseCase) CheckToken(ctx context.Context, req requests.UserToken) *errs.Error {
+	return uc.userService.CheckToken(ctx, req)
+}
+
+// Logout user
+func (uc *userUseCase) Logout(ctx context.Context, p entities.Principal, req requests.UserLogout) *errs.Error {
+	return uc.userService.Logout(ctx, p, req)
+}
+
+// LogoutAll user
+func (uc *userUseCase) LogoutAll(ctx context.Context, p entities.Principal) *errs.Error {
+	return uc.userService.LogoutAll(ctx, p)
 }
 
 // Create user
-func (uc *userUseCase) Create(req requests.UserCreation) (entities.User, *utils.HTTPError) {
-	return uc.userService.Create(req)
+func (uc *userUseCase) Create(ctx context.Context, p entities.Principal, req requests.UserCreation) (entities.User, *errs.Error) {
+	return uc.userService.Create(ctx, p, req)
 }
 
 // GetAll users
-func (uc *userUseCase) GetAll(req requests.Pagination) (responses.UsersListPaginated, *utils.HTTPError) {
-	return uc.userService.GetAll(req)
+func (uc *userUseCase) GetAll(ctx context.Context, p entities.Principal, req requests.Pagination) (responses.UsersListPaginated, *errs.Error) {
+	return uc.userService.GetAll(ctx, p, req)
 }
 
 // GetByID user
-func (uc *userUseCase) GetByID(id requests.UserByID) (entities.User, *utils.HTTPError) {
-	return uc.userService.GetByID(id)
+func (uc *userUseCase) GetByID(ctx context.Context, p entities.Principal, id requests.UserByID) (entities.User, *errs.Error) {
+	return uc.userService.GetByID(ctx, p, id)
 }
 
 // Delete user
-func (uc *userUseCase) Delete(id requests.UserByID) *utils.HTTPError {
-	return uc.userService.Delete(id)
+func (uc *userUseCase) Delete(ctx context.Context, p entities.Principal, id requests.UserByID) *errs.Error {
+	return uc.userService.Delete(ctx, p, id)
 }
 
 // Update user
-func (uc *userUseCase) Update(req requests.UserUpdate) (entities.User, *utils.HTTPError) {
-	return uc.userService.Update(req)
+func (uc *userUseCase) Update(ctx context.Context, p entities.Principal, req requests.UserUpdate) (entities.User, *errs.Error) {
+	return uc.userService.Update(ctx, p, req)
 }
 
 // UpdatePassword user
-func (uc *userUseCase) UpdatePassword(req requests.UserPasswordUpdate) *utils.HTTPError {
-	return uc.userService.UpdatePassword(req)
+func (uc *userUseCase) UpdatePassword(ctx context.Context, req requests.UserPasswordUpdate) *errs.Error {
+	return uc.userService.UpdatePassword(ctx, req)
 }
 
 // ForgottenPassword user
-func (uc *userUseCase) ForgottenPassword(req requests.UserForgotPassword) (entities.PasswordResets, *utils.HTTPError) {
-	return uc.userService.ForgottenPassword(req)
+func (uc *userUseCase) ForgottenPassword(ctx context.Context, req requests.UserForgotPassword) *errs.Error {
+	return uc.userService.ForgottenPassword(ctx, req)
+}
+
+// Me user
+func (uc *userUseCase) Me(ctx context.Context, p entities.Principal) (entities.User, *errs.Error) {
+	return uc.userService.Me(ctx, p)
+}
+
+// UpdateProfile user
+func (uc *userUseCase) UpdateProfile(ctx context.Context, p entities.Principal, req requests.UserProfileUpdate) (entities.User, *errs.Error) {
+	return uc.userService.UpdateProfile(ctx, p, req)
+}
+
+// ChangePassword user
+func (uc *userUseCase) ChangePassword(ctx context.Context, p entities.Principal, req requests.UserMePasswordUpdate) (responses.UserLogin, *errs.Error) {
+	return uc.userService.ChangePassword(ctx, p, req)
+}
+
+// DeleteAccount user
+func (uc *userUseCase) DeleteAccount(ctx context.Context, p entities.Principal, req requests.UserMeDeletion) *errs.Error {
+	return uc.userService.DeleteAccount(ctx, p, req)
+}
+
+// VerifyEmail user
+func (uc *userUseCase) VerifyEmail(ctx context.Context, req requests.UserEmailVerification) *errs.Error {
+	return uc.userService.VerifyEmail(ctx, req)
 }
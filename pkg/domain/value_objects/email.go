@@ -1,30 +1,48 @@
 package values_objects
 
-import "github.com/fabienbellanger/fiber-boilerplate/utils"
+import (
+	"strings"
 
-// Email represents an email value object
-type Email struct {
-	Value string `validate:"required,email"`
-}
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+)
 
-// String returns the email value
-func (e *Email) String() string {
-	return e.Value
+// Email represents an email value object.
+// Emails are normalized (trimmed and lowercased) when they are created or decoded (JSON, query, form),
+// so that two emails differing only by case are equal.
+type Email string
+
+// NormalizeEmail trims and lowercases an email.
+func NormalizeEmail(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
 }
 
-// NewEmail creates a new email
+// NewEmail creates a new normalized email
 func NewEmail(value string) (Email, error) {
-	e := Email{Value: value}
+	e := Email(NormalizeEmail(value))
 
 	err := e.Validate()
 	if err != nil {
-		return Email{}, err
+		return "", err
 	}
 
 	return e, nil
 }
 
+// String returns the email value
+func (e Email) String() string {
+	return string(e)
+}
+
+// UnmarshalText decodes and normalizes an email.
+// It is used for JSON, query and form decoding.
+func (e *Email) UnmarshalText(text []byte) error {
+	*e = Email(NormalizeEmail(string(text)))
+	return nil
+}
+
 // Validate checks if a struct is valid and returns an array of errors
-func (e *Email) Validate() utils.ValidatorErrors {
-	return utils.ValidateStruct(e)
+func (e Email) Validate() utils.ValidatorErrors {
+	return utils.ValidateStruct(struct {
+		Value Email `validate:"required,email"`
+	}{e})
 }
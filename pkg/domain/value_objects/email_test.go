@@ -1,6 +1,7 @@
 package values_objects
 
 import (
+	"encoding/json"
 	"log"
 	"testing"
 
@@ -19,12 +20,14 @@ func TestNewEmail(t *testing.T) {
 		FailedField: "Value",
 		Tag:         "email",
 		Value:       "",
+		Kind:        "string",
 	})
 	var e2 utils.ValidatorErrors
 	e2 = append(e2, utils.ValidatorError{
 		FailedField: "Value",
 		Tag:         "required",
 		Value:       "",
+		Kind:        "string",
 	})
 
 	tests := []struct {
@@ -34,21 +37,28 @@ func TestNewEmail(t *testing.T) {
 		{
 			value: "toto@gmail.com",
 			wanted: result{
-				email: Email{Value: "toto@gmail.com"},
+				email: Email("toto@gmail.com"),
+				err:   nil,
+			},
+		},
+		{
+			value: "  Toto@GMail.com ",
+			wanted: result{
+				email: Email("toto@gmail.com"),
 				err:   nil,
 			},
 		},
 		{
 			value: "bad",
 			wanted: result{
-				email: Email{},
+				email: Email(""),
 				err:   e1,
 			},
 		},
 		{
 			value: "",
 			wanted: result{
-				email: Email{},
+				email: Email(""),
 				err:   e2,
 			},
 		},
@@ -66,3 +76,13 @@ func TestNewEmail(t *testing.T) {
 		})
 	}
 }
+
+func TestEmailUnmarshalJSON(t *testing.T) {
+	var req struct {
+		Email Email `json:"email"`
+	}
+	err := json.Unmarshal([]byte(`{"email": " Toto@Gmail.COM"}`), &req)
+
+	assert.Nil(t, err)
+	assert.Equal(t, Email("toto@gmail.com"), req.Email)
+}
@@ -1,32 +1,29 @@
 package values_objects
 
-import (
-	"github.com/fabienbellanger/fiber-boilerplate/utils"
-)
+import "github.com/fabienbellanger/fiber-boilerplate/utils"
 
-// Password represents an password value object
-type Password struct {
-	Value string `validate:"required,min=8"`
-}
-
-// String returns the password value
-func (p *Password) String() string {
-	return p.Value
-}
+// Password represents a plain text password value object
+type Password string
 
-// NewPassword creates a new password
-func NewPassword(value string) (Password, error) {
-	p := Password{Value: value}
+// NewPassword creates a new password which respects the password policy.
+// personal contains the user information (name, email, etc.) the password must not contain.
+func NewPassword(value string, personal ...string) (Password, error) {
+	p := Password(value)
 
-	err := p.Validate()
+	err := p.Validate(personal...)
 	if err != nil {
-		return Password{}, err
+		return "", err
 	}
 
 	return p, nil
 }
 
-// Validate checks if a struct is valid and returns an array of errors
-func (p *Password) Validate() utils.ValidatorErrors {
-	return utils.ValidateStruct(p)
+// String returns the password value
+func (p Password) String() string {
+	return string(p)
+}
+
+// Validate checks the password against the current password policy and returns an array of errors
+func (p Password) Validate(personal ...string) utils.ValidatorErrors {
+	return CurrentPasswordPolicy().Validate("Value", string(p), personal...)
 }
@@ -0,0 +1,167 @@
+package values_objects
+
+import (
+	"bufio"
+	_ "embed"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/spf13/viper"
+)
+
+// minPersonalLength is the minimum length of a personal information to be searched in a password.
+const minPersonalLength = 3
+
+//go:embed common_passwords.txt
+var commonPasswordsList string
+
+// commonPasswords is the denylist of the most common passwords (in lowercase): the passwords of the
+// zxcvbn frequency list which are long enough to pass the default minimum length.
+var commonPasswords = parseCommonPasswords(commonPasswordsList)
+
+// PasswordPolicy represents the rules a password must respect.
+type PasswordPolicy struct {
+	MinLength        int  // Minimum number of characters
+	RequireLowercase bool // At least one lowercase letter
+	RequireUppercase bool // At least one uppercase letter
+	RequireDigit     bool // At least one digit
+	RequireSymbol    bool // At least one character which is neither a letter nor a digit
+	RejectCommon     bool // Reject the passwords of the common passwords denylist
+}
+
+// DefaultPasswordPolicy is the policy used when no configuration is provided.
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:    8,
+	RejectCommon: true,
+}
+
+// CurrentPasswordPolicy returns the password policy from the configuration (PASSWORD_* variables).
+// The default policy values are used for the missing variables.
+func CurrentPasswordPolicy() PasswordPolicy {
+	policy := DefaultPasswordPolicy
+
+	if viper.GetString("PASSWORD_MIN_LENGTH") != "" {
+		policy.MinLength = viper.GetInt("PASSWORD_MIN_LENGTH")
+	}
+	setBool := func(key string, value *bool) {
+		if viper.GetString(key) != "" {
+			*value = viper.GetBool(key)
+		}
+	}
+	setBool("PASSWORD_REQUIRE_LOWERCASE", &policy.RequireLowercase)
+	setBool("PASSWORD_REQUIRE_UPPERCASE", &policy.RequireUppercase)
+	setBool("PASSWORD_REQUIRE_DIGIT", &policy.RequireDigit)
+	setBool("PASSWORD_REQUIRE_SYMBOL", &policy.RequireSymbol)
+	setBool("PASSWORD_REJECT_COMMON", &policy.RejectCommon)
+
+	return policy
+}
+
+// Validate checks if a password respects the policy and returns an array of errors.
+// field is the name of the field reported in the errors and personal contains the user information
+// (name, email, etc.) the password must not contain.
+func (p PasswordPolicy) Validate(field, password string, personal ...string) (errors utils.ValidatorErrors) {
+	newError := func(tag, param string) utils.ValidatorError {
+		return utils.ValidatorError{FailedField: field, Tag: tag, Value: param, Kind: "string"}
+	}
+
+	if password == "" {
+		return append(errors, newError("required", ""))
+	}
+
+	for _, tag := range p.Check(password, personal...) {
+		param := ""
+		if tag == "min" {
+			param = strconv.Itoa(p.MinLength)
+		}
+		errors = append(errors, newError(tag, param))
+	}
+	return
+}
+
+// Check returns the validation tags of the rules the password does not respect:
+// min, password_lowercase, password_uppercase, password_digit, password_symbol,
+// password_common and password_personal.
+func (p PasswordPolicy) Check(password string, personal ...string) (tags []string) {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		tags = append(tags, "min")
+	}
+
+	var hasLower, hasUpper, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case !unicode.IsLetter(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireLowercase && !hasLower {
+		tags = append(tags, "password_lowercase")
+	}
+	if p.RequireUppercase && !hasUpper {
+		tags = append(tags, "password_uppercase")
+	}
+	if p.RequireDigit && !hasDigit {
+		tags = append(tags, "password_digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		tags = append(tags, "password_symbol")
+	}
+
+	if p.RejectCommon && IsCommonPassword(password) {
+		tags = append(tags, "password_common")
+	}
+	if containsPersonal(password, personal) {
+		tags = append(tags, "password_personal")
+	}
+
+	return
+}
+
+// IsCommonPassword returns true if the password belongs to the common passwords denylist (case-insensitive).
+func IsCommonPassword(password string) bool {
+	_, ok := commonPasswords[strings.ToLower(password)]
+	return ok
+}
+
+// containsPersonal returns true if the password contains one of the personal information (case-insensitive).
+// For an email, the local part is searched too.
+func containsPersonal(password string, personal []string) bool {
+	password = strings.ToLower(password)
+
+	for _, value := range personal {
+		value = strings.ToLower(strings.TrimSpace(value))
+		candidates := []string{value}
+		if local, _, ok := strings.Cut(value, "@"); ok {
+			candidates = append(candidates, local)
+		}
+
+		for _, c := range candidates {
+			if utf8.RuneCountInString(c) >= minPersonalLength && strings.Contains(password, c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseCommonPasswords returns the set of passwords of a list (one password per line).
+func parseCommonPasswords(list string) map[string]struct{} {
+	passwords := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		if line := strings.ToLower(strings.TrimSpace(scanner.Text())); line != "" {
+			passwords[line] = struct{}{}
+		}
+	}
+	return passwords
+}
@@ -0,0 +1,136 @@
+package values_objects
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	strict := PasswordPolicy{
+		MinLength:        10,
+		RequireLowercase: true,
+		RequireUppercase: true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		RejectCommon:     true,
+	}
+
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		personal []string
+		wanted   []string
+	}{
+		{
+			name:     "Valid password with default policy",
+			policy:   DefaultPasswordPolicy,
+			password: "correct-horse",
+			wanted:   nil,
+		},
+		{
+			name:     "Too short password",
+			policy:   DefaultPasswordPolicy,
+			password: "short",
+			wanted:   []string{"min"},
+		},
+		{
+			name:     "Length in characters, not in bytes",
+			policy:   DefaultPasswordPolicy,
+			password: "ééééééé",
+			wanted:   []string{"min"},
+		},
+		{
+			name:     "Common password",
+			policy:   DefaultPasswordPolicy,
+			password: "Password",
+			wanted:   []string{"password_common"},
+		},
+		{
+			name:     "Common password allowed",
+			policy:   PasswordPolicy{MinLength: 8},
+			password: "12345678",
+			wanted:   nil,
+		},
+		{
+			name:     "Missing character classes",
+			policy:   strict,
+			password: "abcdefghijk",
+			wanted:   []string{"password_uppercase", "password_digit", "password_symbol"},
+		},
+		{
+			name:     "Valid password with strict policy",
+			policy:   strict,
+			password: "Tr0ub4dor&3x",
+			wanted:   nil,
+		},
+		{
+			name:     "Password containing the firstname",
+			policy:   DefaultPasswordPolicy,
+			password: "iamFabien2024",
+			personal: []string{"fabien", "Bellanger"},
+			wanted:   []string{"password_personal"},
+		},
+		{
+			name:     "Password containing the email local part",
+			policy:   DefaultPasswordPolicy,
+			password: "john.doe-1987",
+			personal: []string{"John.Doe@example.com"},
+			wanted:   []string{"password_personal"},
+		},
+		{
+			name:     "Too short personal information is ignored",
+			policy:   DefaultPasswordPolicy,
+			password: "jo-correct-horse",
+			personal: []string{"Jo", ""},
+			wanted:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wanted, tt.policy.Check(tt.password, tt.personal...))
+		})
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	errors := DefaultPasswordPolicy.Validate("password", "")
+	assert.Equal(t, utils.ValidatorErrors{{FailedField: "password", Tag: "required", Kind: "string"}}, errors)
+
+	errors = DefaultPasswordPolicy.Validate("password", "short12")
+	assert.Equal(t, utils.ValidatorErrors{{FailedField: "password", Tag: "min", Value: "8", Kind: "string"}}, errors)
+	assert.Equal(t, "password must be at least 8 characters long", errors.Error())
+
+	assert.Nil(t, DefaultPasswordPolicy.Validate("password", "correct-horse"))
+}
+
+func TestCurrentPasswordPolicy(t *testing.T) {
+	assert.Equal(t, DefaultPasswordPolicy, CurrentPasswordPolicy())
+
+	viper.Set("PASSWORD_MIN_LENGTH", "12")
+	viper.Set("PASSWORD_REQUIRE_DIGIT", "true")
+	viper.Set("PASSWORD_REJECT_COMMON", "false")
+	t.Cleanup(func() {
+		viper.Set("PASSWORD_MIN_LENGTH", "")
+		viper.Set("PASSWORD_REQUIRE_DIGIT", "")
+		viper.Set("PASSWORD_REJECT_COMMON", "")
+	})
+
+	assert.Equal(t, PasswordPolicy{MinLength: 12, RequireDigit: true}, CurrentPasswordPolicy())
+}
+
+func TestCommonPasswords(t *testing.T) {
+	assert.Greater(t, len(commonPasswords), 1000)
+	for password := range commonPasswords {
+		assert.GreaterOrEqual(t, utf8.RuneCountInString(password), DefaultPasswordPolicy.MinLength, password)
+	}
+
+	assert.True(t, IsCommonPassword("Baseball"))
+	assert.True(t, IsCommonPassword("superman"))
+	assert.False(t, IsCommonPassword("correct-horse"))
+}
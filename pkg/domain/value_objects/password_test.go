@@ -19,12 +19,14 @@ func TestNewPassword(t *testing.T) {
 		FailedField: "Value",
 		Tag:         "min",
 		Value:       "8",
+		Kind:        "string",
 	})
 	var e2 utils.ValidatorErrors
 	e2 = append(e2, utils.ValidatorError{
 		FailedField: "Value",
 		Tag:         "required",
 		Value:       "",
+		Kind:        "string",
 	})
 
 	tests := []struct {
@@ -32,23 +34,23 @@ func TestNewPassword(t *testing.T) {
 		wanted result
 	}{
 		{
-			value: "password",
+			value: "correct-horse",
 			wanted: result{
-				password: Password{Value: "password"},
+				password: Password("correct-horse"),
 				err:      nil,
 			},
 		},
 		{
 			value: "bad",
 			wanted: result{
-				password: Password{},
+				password: Password(""),
 				err:      e1,
 			},
 		},
 		{
 			value: "",
 			wanted: result{
-				password: Password{},
+				password: Password(""),
 				err:      e2,
 			},
 		},
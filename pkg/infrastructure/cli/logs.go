@@ -9,6 +9,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/logrusorgru/aurora/v3"
 	"github.com/spf13/cobra"
 )
 
@@ -67,11 +68,24 @@ type errorLog struct {
 	UserAgent   string    `json:"userAgent"`
 }
 
+type dbLog struct {
+	Level     string    `json:"level"`
+	Time      time.Time `json:"time"`
+	Caller    string    `json:"caller"`
+	Message   string    `json:"message"`
+	Error     string    `json:"error"`
+	SQL       string    `json:"sql"`
+	Rows      *int64    `json:"rows"`
+	Duration  string    `json:"duration"`
+	Slow      bool      `json:"slow"`
+	RequestID string    `json:"requestId"`
+}
+
 func parseLine(line []byte, serverLogs, dbLogs, verboseFlag bool) (string, error) {
 	if serverLogs {
 		return parseLineServer(line, verboseFlag)
 	} else if dbLogs {
-		return string(line), nil
+		return parseLineDB(line, verboseFlag)
 	}
 	return "", errors.New("invalid flag")
 }
@@ -152,3 +166,58 @@ func parseLineServer(line []byte, verboseFlag bool) (string, error) {
 
 	return result, nil
 }
+
+func parseLineDB(line []byte, verboseFlag bool) (string, error) {
+	var dbLog dbLog
+	err := json.Unmarshal(line, &dbLog)
+	if err != nil {
+		return string(line), err
+	}
+
+	message := ""
+	if dbLog.Message != "" {
+		message = fmt.Sprintf(" | %s", dbLog.Message)
+	}
+	errorLog := ""
+	if dbLog.Error != "" {
+		errorLog = fmt.Sprintf(" | Error: %s", dbLog.Error)
+	}
+	duration := ""
+	if dbLog.Duration != "" {
+		if dbLog.Slow {
+			duration = fmt.Sprintf(" | %s", aurora.Yellow(dbLog.Duration))
+		} else {
+			duration = fmt.Sprintf(" | %s", dbLog.Duration)
+		}
+	}
+	rows := ""
+	if dbLog.Rows != nil && *dbLog.Rows >= 0 {
+		rows = fmt.Sprintf(" | Rows: %d", *dbLog.Rows)
+	}
+	requestID := ""
+	if dbLog.RequestID != "" {
+		requestID = fmt.Sprintf(" | RequestID: %s", dbLog.RequestID)
+	}
+	sql := ""
+	if dbLog.SQL != "" {
+		sql = fmt.Sprintf(" | %s", dbLog.SQL)
+	}
+	caller := ""
+	if dbLog.Caller != "" && verboseFlag {
+		caller = fmt.Sprintf(" | %s", dbLog.Caller)
+	}
+
+	result := fmt.Sprintf("%s | %7s%s%s%s%s%s%s%s",
+		dbLog.Time.Format(time.RFC3339),
+		displayLogLevel(dbLog.Level),
+		message,
+		errorLog,
+		duration,
+		rows,
+		requestID,
+		sql,
+		caller,
+	)
+
+	return result, nil
+}
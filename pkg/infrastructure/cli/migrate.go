@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db/migrations"
+	"github.com/spf13/cobra"
+)
+
+var migrationsDir string
+
+func init() {
+	migrateCreateCmd.Flags().StringVarP(&migrationsDir, "dir", "d", "pkg/adapters/db/migrations", "migrations directory")
+
+	migrateCmd.AddCommand(migrateUpCmd)
+	migrateCmd.AddCommand(migrateDownCmd)
+	migrateCmd.AddCommand(migrateStatusCmd)
+	migrateCmd.AddCommand(migrateCreateCmd)
+	rootCmd.AddCommand(migrateCmd)
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Database migrations",
+	Long:  `Database migrations`,
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply all pending migrations",
+	Long:  `Apply all pending migrations`,
+	Run: func(cmd *cobra.Command, args []string) {
+		migrator, err := newMigrator()
+		if err != nil {
+			fmt.Printf("\nError: %v\n", err)
+			return
+		}
+
+		done, err := migrator.Up()
+		printMigrations("Applied", done)
+		if err != nil {
+			fmt.Printf("\nError: %v\n", err)
+			return
+		}
+		if len(done) == 0 {
+			fmt.Println("\nNo pending migrations")
+		}
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down [n]",
+	Short: "Revert the n last migrations (Default: 1)",
+	Long:  `Revert the n last migrations (Default: 1)`,
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		n := 1
+		if len(args) == 1 {
+			var err error
+			n, err = strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				fmt.Printf("\nError: invalid number of migrations %s\n", args[0])
+				return
+			}
+		}
+
+		migrator, err := newMigrator()
+		if err != nil {
+			fmt.Printf("\nError: %v\n", err)
+			return
+		}
+
+		done, err := migrator.Down(n)
+		printMigrations("Reverted", done)
+		if err != nil {
+			fmt.Printf("\nError: %v\n", err)
+			return
+		}
+		if len(done) == 0 {
+			fmt.Println("\nNo migrations to revert")
+		}
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Display migrations status",
+	Long:  `Display migrations status`,
+	Run: func(cmd *cobra.Command, args []string) {
+		migrator, err := newMigrator()
+		if err != nil {
+			fmt.Printf("\nError: %v\n", err)
+			return
+		}
+
+		status, err := migrator.Status()
+		if err != nil {
+			fmt.Printf("\nError: %v\n", err)
+			return
+		}
+
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range status {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		w.Flush()
+	},
+}
+
+var migrateCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a new migration file",
+	Long:  `Create a new migration file`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := db.CreateMigrationFile(migrationsDir, args[0], time.Now())
+		if err != nil {
+			fmt.Printf("\nError: %v\n", err)
+			return
+		}
+
+		fmt.Printf("\nMigration %s successfully created\n", path)
+	},
+}
+
+// newMigrator initializes configuration and database and returns the migrator.
+func newMigrator() (*db.Migrator, error) {
+	_, database, err := initConfigLoggerDatabase(false, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.NewMigrator(database, migrations.All()), nil
+}
+
+// printMigrations displays a list of migrations.
+func printMigrations(action string, list []db.Migration) {
+	for _, m := range list {
+		fmt.Printf("\n%s: %s_%s", action, m.Version, m.Name)
+	}
+	if len(list) > 0 {
+		fmt.Println()
+	}
+}
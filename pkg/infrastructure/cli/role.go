@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/stores"
+	values_objects "github.com/fabienbellanger/fiber-boilerplate/pkg/domain/value_objects"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	roleUserEmail string
+	roleNames     []string
+)
+
+func init() {
+	roleAssignCmd.Flags().StringVarP(&roleUserEmail, "email", "e", "", "user email")
+	roleAssignCmd.Flags().StringSliceVarP(&roleNames, "role", "r", nil, "roles to assign (Ex.: -r admin,user)")
+
+	roleAssignCmd.MarkFlagRequired("email")
+	roleAssignCmd.MarkFlagRequired("role")
+
+	roleCmd.AddCommand(roleAssignCmd)
+	rootCmd.AddCommand(roleCmd)
+}
+
+var roleCmd = &cobra.Command{
+	Use:   "role",
+	Short: "Users roles management",
+	Long:  `Users roles management`,
+}
+
+var roleAssignCmd = &cobra.Command{
+	Use:   "assign",
+	Short: "Assign roles to a user",
+	Long:  `Assign roles to a user`,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Init config, logger and database
+		// --------------------------------
+		_, db, err := initConfigLoggerDatabase(false, true)
+		if err != nil {
+			fmt.Printf("\nError: %v\n", err)
+			return
+		}
+
+		// Find user
+		// ---------
+		userStore := stores.NewUserStore(db, utils.NewPasswordHasher(viper.GetString("PASSWORD_HASHER")))
+		user, err := userStore.GetByUsername(context.Background(), values_objects.NormalizeEmail(roleUserEmail))
+		if err != nil {
+			fmt.Printf("\nError: %v\n", err)
+			return
+		}
+		if user.ID == "" {
+			fmt.Printf("\nError: no user found with email %s\n", roleUserEmail)
+			return
+		}
+
+		// Roles assignment
+		// ----------------
+		err = userStore.AssignRoles(context.Background(), user.ID, roleNames...)
+		if err != nil {
+			fmt.Printf("\nError: %v\n", err)
+			return
+		}
+
+		// Invalidate access tokens to apply the new permissions
+		// -----------------------------------------------------
+		err = userStore.IncrementTokenVersion(context.Background(), user.ID)
+		if err != nil {
+			fmt.Printf("\nError: %v\n", err)
+			return
+		}
+
+		fmt.Printf("\nRoles %s successfully assigned to %s\n", strings.Join(roleNames, ", "), user.Username)
+	},
+}
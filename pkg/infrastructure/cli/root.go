@@ -63,9 +63,12 @@ func initConfigLoggerDatabase(initLogger, initDatabase bool) (logger *zap.Logger
 			Charset:         viper.GetString("DB_CHARSET"),
 			Collation:       viper.GetString("DB_COLLATION"),
 			Location:        viper.GetString("DB_LOCATION"),
+			SSLMode:         viper.GetString("DB_SSLMODE"),
 			MaxIdleConns:    viper.GetInt("DB_MAX_IDLE_CONNS"),
 			MaxOpenConns:    viper.GetInt("DB_MAX_OPEN_CONNS"),
 			ConnMaxLifetime: viper.GetDuration("DB_CONN_MAX_LIFETIME") * time.Hour,
+			SlowThreshold:   viper.GetDuration("GORM_SLOW_THRESHOLD"),
+			Logger:          logger,
 		})
 		if err != nil {
 			return nil, nil, err
@@ -3,6 +3,8 @@ package cli
 import (
 	"log"
 
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db/migrations"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
@@ -25,23 +27,32 @@ var serverCmd = &cobra.Command{
 func startServer() {
 	// Configuration initialization
 	// ----------------------------
-	logger, db, err := initConfigLoggerDatabase(true, true)
+	logger, database, err := initConfigLoggerDatabase(true, true)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
 	// Database migrations
 	// -------------------
+	// The server does not start with pending migrations, unless they are explicitly allowed.
+	migrator := db.NewMigrator(database, migrations.All())
 	if viper.GetBool("DB_USE_AUTOMIGRATIONS") {
-		err = db.MakeMigrations()
-		if err != nil {
+		if _, err = migrator.Up(); err != nil {
 			log.Fatalln(err)
 		}
 	}
 
+	pending, err := migrator.Pending()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(pending) > 0 && !viper.GetBool("DB_ALLOW_PENDING_MIGRATIONS") {
+		log.Fatalf("%d pending migration(s), run the \"migrate up\" command or set DB_ALLOW_PENDING_MIGRATIONS=true\n", len(pending))
+	}
+
 	// Start server
 	// ------------
-	err = server.Run(db, logger, "./templates")
+	err = server.Run(database, logger, "./templates")
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -1,13 +1,18 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/stores"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/repositories"
+	values_objects "github.com/fabienbellanger/fiber-boilerplate/pkg/domain/value_objects"
 	"strings"
+	"time"
 
 	"github.com/fabienbellanger/fiber-boilerplate/utils"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var (
@@ -15,13 +20,14 @@ var (
 	userPassword  string
 	userLastname  string
 	userFirstname string
+	userRoles     []string
 )
 
 type userCreation struct {
-	Lastname  string `validate:"required"`
-	Firstname string `validate:"required"`
-	Email     string `validate:"required,email"`
-	Password  string `validate:"required,min=8"`
+	Lastname  string                  `validate:"required"`
+	Firstname string                  `validate:"required"`
+	Email     values_objects.Email    `validate:"required,email"`
+	Password  values_objects.Password `validate:"required"`
 }
 
 func init() {
@@ -29,6 +35,7 @@ func init() {
 	userCmd.Flags().StringVarP(&userFirstname, "firstname", "f", "", "user firstname")
 	userCmd.Flags().StringVarP(&userEmail, "email", "e", "", "user email")
 	userCmd.Flags().StringVarP(&userPassword, "password", "p", "", "user password")
+	userCmd.Flags().StringSliceVarP(&userRoles, "role", "r", []string{entities.RoleUser}, "user roles (Ex.: -r admin)")
 
 	userCmd.MarkFlagRequired("lastname")
 	userCmd.MarkFlagRequired("firstname")
@@ -46,15 +53,15 @@ var userCmd = &cobra.Command{
 		user := userCreation{
 			Lastname:  strings.TrimSpace(userLastname),
 			Firstname: strings.TrimSpace(userFirstname),
-			Password:  strings.TrimSpace(userPassword),
-			Email:     strings.TrimSpace(userEmail),
+			Password:  values_objects.Password(strings.TrimSpace(userPassword)),
+			Email:     values_objects.Email(values_objects.NormalizeEmail(userEmail)),
 		}
 
 		// Validate data
 		// -------------
 		errs := utils.ValidateStruct(user)
 		if len(errs) > 0 {
-			fmt.Printf("\nError: invalid email or password (min 8 characters)\n")
+			fmt.Printf("\nError: %v\n", errs)
 			return
 		}
 
@@ -66,19 +73,36 @@ var userCmd = &cobra.Command{
 			return
 		}
 
+		// The password policy depends on the configuration
+		errs = values_objects.CurrentPasswordPolicy().Validate("Password", user.Password.String(),
+			user.Email.String(), user.Lastname, user.Firstname)
+		if len(errs) > 0 {
+			fmt.Printf("\nError: %v\n", errs)
+			return
+		}
+
 		// User creation
 		// -------------
+		// The email of a user created from the command line is considered verified
+		now := time.Now().UTC()
 		u := entities.User{
-			Lastname:  user.Lastname,
-			Firstname: user.Firstname,
-			Password:  user.Password,
-			Username:  user.Email,
+			EmailVerifiedAt: &now,
+			Lastname:        user.Lastname,
+			Firstname:       user.Firstname,
+			Password:        user.Password.String(),
+			Username:        user.Email,
 		}
 
-		userStore := stores.NewUserStore(db)
-		err = userStore.Create(&u)
+		// The user and its roles are created together, so that an unknown role does not leave a user without roles
+		txManager := stores.NewTxManager(db, utils.NewPasswordHasher(viper.GetString("PASSWORD_HASHER")))
+		err = txManager.WithinTx(context.Background(), func(repos repositories.Repositories) error {
+			if err := repos.Users.Create(context.Background(), &u); err != nil {
+				return err
+			}
+			return repos.Users.AssignRoles(context.Background(), u.ID, userRoles...)
+		})
 		if err != nil {
-			fmt.Printf("\n%v\n", err)
+			fmt.Printf("\nError: %v\n", err)
 			return
 		}
 
@@ -90,11 +114,13 @@ User successfully created:
     - Firstname: %s
     - Email:     %s
     - Password:  %s
+    - Roles:     %s
 `,
 			user.Lastname,
 			user.Firstname,
 			user.Email,
 			user.Password,
+			strings.Join(userRoles, ", "),
 		)
 	},
 }
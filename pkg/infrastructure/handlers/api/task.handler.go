@@ -2,11 +2,14 @@ package api
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
-	"errors"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/usecases"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/infrastructure/handlers"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/infrastructure/middlewares/principal"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/infrastructure/middlewares/rbac"
 
 	"github.com/fabienbellanger/fiber-boilerplate/utils"
 	"github.com/gofiber/fiber/v2"
@@ -31,9 +34,15 @@ func NewTask(r fiber.Router, taskUseCase usecases.Task, logger *zap.Logger) Task
 
 // TaskProtectedRoutes adds tasks routes
 func (t *Task) TaskProtectedRoutes() {
-	t.router.Post("", t.create())
-	t.router.Get("", t.getAll())
-	t.router.Get("/stream", t.getAllStream())
+	t.router.Post("", rbac.RequirePermission(entities.PermissionTasksCreate), t.create())
+	t.router.Get("", rbac.RequirePermission(entities.PermissionTasksRead), t.getAll())
+	t.router.Get("/stream", rbac.RequirePermission(entities.PermissionTasksRead), t.getAllStream())
+	t.router.Get("/:id", rbac.RequirePermission(entities.PermissionTasksRead), t.getByID())
+	t.router.Put("/:id", rbac.RequirePermission(entities.PermissionTasksUpdate), t.update())
+	t.router.Patch("/:id", rbac.RequirePermission(entities.PermissionTasksUpdate), t.patch())
+	t.router.Delete("/:id", rbac.RequirePermission(entities.PermissionTasksDelete), t.delete())
+	t.router.Post("/:id/restore", rbac.RequirePermission(entities.PermissionTasksDelete), t.restore())
+	t.router.Post("/:id/transition", rbac.RequirePermission(entities.PermissionTasksUpdate), t.transition())
 }
 
 // create creates a new task.
@@ -41,20 +50,12 @@ func (t *Task) create() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		task := new(requests.TaskCreation)
 		if err := c.BodyParser(task); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(utils.HTTPError{
-				Code:    fiber.StatusBadRequest,
-				Message: "Bad Request",
-			})
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidBody, ""))
 		}
 
-		newTask, err := t.taskUseCase.Create(*task)
+		newTask, err := t.taskUseCase.Create(c.UserContext(), principal.Get(c), *task)
 		if err != nil {
-			if errors.Is(err, utils.HTTPError{}) && err.Err != nil {
-				if details, ok := err.Details.(string); ok {
-					return utils.NewError(c, t.logger, err.Message, details, err.Err)
-				}
-			}
-			return c.Status(err.Code).JSON(err)
+			return handlers.ManageError(err, c, t.logger)
 		}
 
 		return c.JSON(newTask)
@@ -64,39 +65,162 @@ func (t *Task) create() fiber.Handler {
 // getAll lists all tasks.
 func (t *Task) getAll() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		pagination := new(requests.Pagination)
-		if err := c.QueryParser(pagination); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(utils.HTTPError{
-				Code:    fiber.StatusBadRequest,
-				Message: "Bad Request",
-			})
+		req := new(requests.TaskList)
+		if err := c.QueryParser(req); err != nil {
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidParameters, ""))
 		}
 
-		res, err := t.taskUseCase.GetAll(*pagination)
+		filters, errFilters := requests.ParseFilters(c.Queries())
+		if errFilters != nil {
+			problem := utils.NewProblem(utils.ErrCodeValidationFailed, "Invalid parameters")
+			problem.Errors = errFilters
+			return utils.SendProblem(c, problem)
+		}
+		req.Filters = filters
+
+		res, err := t.taskUseCase.GetAll(c.UserContext(), principal.Get(c), *req)
 		if err != nil {
-			if errors.Is(err, utils.HTTPError{}) && err.Err != nil {
-				if details, ok := err.Details.(string); ok {
-					return utils.NewError(c, t.logger, err.Message, details, err.Err)
-				}
-			}
-			return c.Status(err.Code).JSON(err)
+			return handlers.ManageError(err, c, t.logger)
 		}
 
 		return c.JSON(res)
 	}
 }
 
+// getByID returns a task.
+func (t *Task) getByID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if id == "" {
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidParameters, "Invalid ID"))
+		}
+
+		task, err := t.taskUseCase.GetByID(c.UserContext(), principal.Get(c), requests.TaskByID{ID: id})
+		if err != nil {
+			return handlers.ManageError(err, c, t.logger)
+		}
+
+		return c.JSON(task)
+	}
+}
+
+// update updates a task.
+func (t *Task) update() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if id == "" {
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidParameters, "Invalid ID"))
+		}
+
+		taskUpdate := new(requests.TaskUpdate)
+		if err := c.BodyParser(taskUpdate); err != nil {
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidBody, ""))
+		}
+		taskUpdate.ID = id
+
+		res, err := t.taskUseCase.Update(c.UserContext(), principal.Get(c), *taskUpdate)
+		if err != nil {
+			return handlers.ManageError(err, c, t.logger)
+		}
+
+		return c.JSON(res)
+	}
+}
+
+// patch partially updates a task.
+func (t *Task) patch() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if id == "" {
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidParameters, "Invalid ID"))
+		}
+
+		taskPatch := new(requests.TaskPatch)
+		if err := c.BodyParser(taskPatch); err != nil {
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidBody, ""))
+		}
+		taskPatch.ID = id
+
+		res, err := t.taskUseCase.Patch(c.UserContext(), principal.Get(c), *taskPatch)
+		if err != nil {
+			return handlers.ManageError(err, c, t.logger)
+		}
+
+		return c.JSON(res)
+	}
+}
+
+// delete soft deletes a task.
+func (t *Task) delete() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if id == "" {
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidParameters, "Invalid ID"))
+		}
+
+		err := t.taskUseCase.Delete(c.UserContext(), principal.Get(c), requests.TaskByID{ID: id})
+		if err != nil {
+			return handlers.ManageError(err, c, t.logger)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// restore restores a soft deleted task.
+func (t *Task) restore() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if id == "" {
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidParameters, "Invalid ID"))
+		}
+
+		task, err := t.taskUseCase.Restore(c.UserContext(), principal.Get(c), requests.TaskByID{ID: id})
+		if err != nil {
+			return handlers.ManageError(err, c, t.logger)
+		}
+
+		return c.JSON(task)
+	}
+}
+
+// transition changes the state of a task.
+func (t *Task) transition() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if id == "" {
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidParameters, "Invalid ID"))
+		}
+
+		req := new(requests.TaskTransition)
+		if err := c.BodyParser(req); err != nil {
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidBody, ""))
+		}
+		req.ID = id
+
+		task, err := t.taskUseCase.Transition(c.UserContext(), principal.Get(c), *req)
+		if err != nil {
+			return handlers.ManageError(err, c, t.logger)
+		}
+
+		return c.JSON(task)
+	}
+}
+
 // getAllStream lists all tasks with a stream.
 func (t *Task) getAllStream() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		rows, err := t.taskUseCase.GetAllStream()
+		req := new(requests.TaskOwner)
+		if err := c.QueryParser(req); err != nil {
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidParameters, ""))
+		}
+
+		// Rows are read after the handler returns, so they must not be cancelled with the request context
+		ctx := context.WithoutCancel(c.UserContext())
+
+		rows, err := t.taskUseCase.GetAllStream(ctx, principal.Get(c), *req)
 		if err != nil {
-			if errors.Is(err, utils.HTTPError{}) && err.Err != nil {
-				if details, ok := err.Details.(string); ok {
-					return utils.NewError(c, t.logger, err.Message, details, err.Err)
-				}
-			}
-			return c.Status(err.Code).JSON(err)
+			return handlers.ManageError(err, c, t.logger)
 		}
 
 		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
@@ -110,7 +234,7 @@ func (t *Task) getAllStream() fiber.Handler {
 				}
 
 				var task entities.Task
-				if err := t.taskUseCase.ScanTask(rows, &task); err != nil {
+				if err := t.taskUseCase.ScanTask(ctx, rows, &task); err != nil {
 					continue
 				}
 				if err := enc.Encode(task); err != nil {
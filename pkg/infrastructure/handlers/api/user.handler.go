@@ -1,14 +1,33 @@
 package api
 
 import (
-	"errors"
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/usecases"
+	values_objects "github.com/fabienbellanger/fiber-boilerplate/pkg/domain/value_objects"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/infrastructure/handlers"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/infrastructure/middlewares/principal"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/infrastructure/middlewares/rbac"
 	"github.com/fabienbellanger/fiber-boilerplate/utils"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
 
+const (
+	// DefaultForgottenPasswordIPLimit is used when FORGOTTEN_PASSWORD_IP_LIMIT is not set
+	DefaultForgottenPasswordIPLimit = 10
+
+	// DefaultForgottenPasswordEmailLimit is used when FORGOTTEN_PASSWORD_EMAIL_LIMIT is not set
+	DefaultForgottenPasswordEmailLimit = 3
+
+	// DefaultForgottenPasswordThrottleDuration is used when FORGOTTEN_PASSWORD_THROTTLE_DURATION is not set
+	DefaultForgottenPasswordThrottleDuration = time.Hour
+)
+
 // User handler
 type User struct {
 	router      fiber.Router
@@ -27,18 +46,45 @@ func NewUser(r fiber.Router, userUseCase usecases.User, logger *zap.Logger) User
 
 // UserProtectedRoutes adds users protected routes
 func (u *User) UserProtectedRoutes() {
-	u.router.Get("", u.getAll())
-	u.router.Post("", u.create())
-	u.router.Get("/:id", u.getByID())
-	u.router.Put("/:id", u.update())
-	u.router.Delete("/:id", u.delete())
+	u.router.Get("", rbac.RequirePermission(entities.PermissionUsersRead), u.getAll())
+	u.router.Post("", rbac.RequirePermission(entities.PermissionUsersCreate), u.create())
+	u.router.Get("/:id", rbac.RequirePermission(entities.PermissionUsersRead), u.getByID())
+	u.router.Put("/:id", rbac.RequirePermission(entities.PermissionUsersUpdate), u.update())
+	u.router.Delete("/:id", rbac.RequirePermission(entities.PermissionUsersDelete), u.delete())
+}
+
+// UserMeRoutes adds the routes of the authenticated user account
+func (u *User) UserMeRoutes() {
+	u.router.Get("", u.getMe())
+	u.router.Patch("", u.updateMe())
+	u.router.Post("/password", u.changeMyPassword())
+	u.router.Delete("", u.deleteMe())
+}
+
+// UserLogoutRoutes adds logout routes
+func (u *User) UserLogoutRoutes() {
+	u.router.Post("/logout", u.logout())
+	u.router.Post("/logout/all", u.logoutAll())
 }
 
 // UserPublicRoutes adds users public routes
 func (u *User) UserPublicRoutes() {
 	u.router.Post("/login", u.login())
-	u.router.Post("/forgotten-password/:email", u.forgottenPassword())
+	u.router.Post("/token/refresh", u.refreshToken())
+	u.router.Post(
+		"/forgotten-password",
+		forgottenPasswordLimiter("FORGOTTEN_PASSWORD_IP_LIMIT", DefaultForgottenPasswordIPLimit, func(c *fiber.Ctx) string {
+			return c.IP()
+		}),
+		forgottenPasswordLimiter("FORGOTTEN_PASSWORD_EMAIL_LIMIT", DefaultForgottenPasswordEmailLimit, func(c *fiber.Ctx) string {
+			req := new(requests.UserForgotPassword)
+			_ = c.BodyParser(req)
+			return req.Email.String()
+		}),
+		u.forgottenPassword(),
+	)
 	u.router.Patch("/update-password/:token", u.updatePassword())
+	u.router.Post("/verify-email/:token", u.verifyEmail())
 }
 
 // login authenticates a user.
@@ -46,45 +92,77 @@ func (u *User) login() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		req := new(requests.UserLogin)
 		if err := c.BodyParser(req); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(utils.HTTPError{
-				Code:    fiber.StatusBadRequest,
-				Message: "Invalid body",
-			})
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidBody, ""))
 		}
 
-		res, err := u.userUseCase.Login(*req)
+		res, err := u.userUseCase.Login(c.UserContext(), *req)
 		if err != nil {
-			if errors.Is(err, utils.HTTPError{}) && err.Err != nil {
-				if details, ok := err.Details.(string); ok {
-					return utils.NewError(c, u.logger, err.Message, details, err.Err)
-				}
-			}
-			return c.Status(err.Code).JSON(err)
+			return handlers.ManageError(err, c, u.logger)
 		}
 
 		return c.JSON(res)
 	}
 }
 
+// refreshToken returns a new access token from a refresh token.
+func (u *User) refreshToken() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req := new(requests.TokenRefresh)
+		if err := c.BodyParser(req); err != nil {
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidBody, ""))
+		}
+
+		res, err := u.userUseCase.RefreshToken(c.UserContext(), *req)
+		if err != nil {
+			return handlers.ManageError(err, c, u.logger)
+		}
+
+		return c.JSON(res)
+	}
+}
+
+// logout revokes the current access token and the given refresh token.
+func (u *User) logout() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req := new(requests.UserLogout)
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(req); err != nil {
+				return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidBody, ""))
+			}
+		}
+
+		err := u.userUseCase.Logout(c.UserContext(), principal.Get(c), *req)
+		if err != nil {
+			return handlers.ManageError(err, c, u.logger)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// logoutAll revokes all the tokens of the current user.
+func (u *User) logoutAll() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		err := u.userUseCase.LogoutAll(c.UserContext(), principal.Get(c))
+		if err != nil {
+			return handlers.ManageError(err, c, u.logger)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
 // create creates a new user.
 func (u *User) create() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		user := new(requests.UserCreation)
 		if err := c.BodyParser(user); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(utils.HTTPError{
-				Code:    fiber.StatusBadRequest,
-				Message: "Bad Request",
-			})
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidBody, ""))
 		}
 
-		res, err := u.userUseCase.Create(*user)
+		res, err := u.userUseCase.Create(c.UserContext(), principal.Get(c), *user)
 		if err != nil {
-			if errors.Is(err, utils.HTTPError{}) && err.Err != nil {
-				if details, ok := err.Details.(string); ok {
-					return utils.NewError(c, u.logger, err.Message, details, err.Err)
-				}
-			}
-			return c.Status(err.Code).JSON(err)
+			return handlers.ManageError(err, c, u.logger)
 		}
 
 		return c.JSON(res)
@@ -96,20 +174,20 @@ func (u *User) getAll() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		pagination := new(requests.Pagination)
 		if err := c.QueryParser(pagination); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(utils.HTTPError{
-				Code:    fiber.StatusBadRequest,
-				Message: "Bad Request",
-			})
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidParameters, ""))
+		}
+
+		filters, errFilters := requests.ParseFilters(c.Queries())
+		if errFilters != nil {
+			problem := utils.NewProblem(utils.ErrCodeValidationFailed, "Invalid parameters")
+			problem.Errors = errFilters
+			return utils.SendProblem(c, problem)
 		}
+		pagination.Filters = filters
 
-		res, err := u.userUseCase.GetAll(*pagination)
+		res, err := u.userUseCase.GetAll(c.UserContext(), principal.Get(c), *pagination)
 		if err != nil {
-			if errors.Is(err, utils.HTTPError{}) && err.Err != nil {
-				if details, ok := err.Details.(string); ok {
-					return utils.NewError(c, u.logger, err.Message, details, err.Err)
-				}
-			}
-			return c.Status(err.Code).JSON(err)
+			return handlers.ManageError(err, c, u.logger)
 		}
 
 		return c.JSON(res)
@@ -121,22 +199,14 @@ func (u *User) getByID() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		id := c.Params("id")
 		if id == "" {
-			return c.Status(fiber.StatusBadRequest).JSON(utils.HTTPError{
-				Code:    fiber.StatusBadRequest,
-				Message: "Bad ID",
-			})
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidParameters, "Invalid ID"))
 		}
 
 		userID := requests.UserByID{ID: id}
 
-		user, err := u.userUseCase.GetByID(userID)
+		user, err := u.userUseCase.GetByID(c.UserContext(), principal.Get(c), userID)
 		if err != nil {
-			if errors.Is(err, utils.HTTPError{}) && err.Err != nil {
-				if details, ok := err.Details.(string); ok {
-					return utils.NewError(c, u.logger, err.Message, details, err.Err)
-				}
-			}
-			return c.Status(err.Code).JSON(err)
+			return handlers.ManageError(err, c, u.logger)
 		}
 
 		return c.JSON(user)
@@ -148,22 +218,14 @@ func (u *User) delete() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		id := c.Params("id")
 		if id == "" {
-			return c.Status(fiber.StatusBadRequest).JSON(utils.HTTPError{
-				Code:    fiber.StatusBadRequest,
-				Message: "Bad ID",
-			})
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidParameters, "Invalid ID"))
 		}
 
 		userID := requests.UserByID{ID: id}
 
-		err := u.userUseCase.Delete(userID)
+		err := u.userUseCase.Delete(c.UserContext(), principal.Get(c), userID)
 		if err != nil {
-			if errors.Is(err, utils.HTTPError{}) && err.Err != nil {
-				if details, ok := err.Details.(string); ok {
-					return utils.NewError(c, u.logger, err.Message, details, err.Err)
-				}
-			}
-			return c.Status(err.Code).JSON(err)
+			return handlers.ManageError(err, c, u.logger)
 		}
 
 		return c.SendStatus(fiber.StatusNoContent)
@@ -175,18 +237,12 @@ func (u *User) update() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		id := c.Params("id")
 		if id == "" {
-			return c.Status(fiber.StatusBadRequest).JSON(utils.HTTPError{
-				Code:    fiber.StatusBadRequest,
-				Message: "Bad ID",
-			})
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidParameters, "Invalid ID"))
 		}
 
 		user := new(requests.UserCreation)
 		if err := c.BodyParser(user); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(utils.HTTPError{
-				Code:    fiber.StatusBadRequest,
-				Message: "Bad Data",
-			})
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidBody, ""))
 		}
 
 		userUpdate := requests.UserUpdate{
@@ -197,14 +253,9 @@ func (u *User) update() fiber.Handler {
 			Firstname: user.Firstname,
 		}
 
-		res, err := u.userUseCase.Update(userUpdate)
+		res, err := u.userUseCase.Update(c.UserContext(), principal.Get(c), userUpdate)
 		if err != nil {
-			if errors.Is(err, utils.HTTPError{}) && err.Err != nil {
-				if details, ok := err.Details.(string); ok {
-					return utils.NewError(c, u.logger, err.Message, details, err.Err)
-				}
-			}
-			return c.Status(err.Code).JSON(err)
+			return handlers.ManageError(err, c, u.logger)
 		}
 
 		return c.JSON(res)
@@ -217,13 +268,10 @@ func (u *User) updatePassword() fiber.Handler {
 		token := c.Params("token")
 
 		newPassword := struct {
-			Password string `json:"password" xml:"password" form:"password"`
+			Password values_objects.Password `json:"password" xml:"password" form:"password"`
 		}{}
 		if err := c.BodyParser(&newPassword); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(utils.HTTPError{
-				Code:    fiber.StatusBadRequest,
-				Message: "Bad Request",
-			})
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidBody, ""))
 		}
 
 		password := requests.UserPasswordUpdate{
@@ -231,36 +279,129 @@ func (u *User) updatePassword() fiber.Handler {
 			Password: newPassword.Password,
 		}
 
-		err := u.userUseCase.UpdatePassword(password)
+		err := u.userUseCase.UpdatePassword(c.UserContext(), password)
 		if err != nil {
-			if errors.Is(err, utils.HTTPError{}) && err.Err != nil {
-				if details, ok := err.Details.(string); ok {
-					return utils.NewError(c, u.logger, err.Message, details, err.Err)
-				}
-			}
-			return c.Status(err.Code).JSON(err)
+			return handlers.ManageError(err, c, u.logger)
 		}
 
 		return c.SendStatus(fiber.StatusOK)
 	}
 }
 
-// forgottenPassword save a forgotten password request.
+// verifyEmail verifies a user email.
+func (u *User) verifyEmail() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req := requests.UserEmailVerification{Token: c.Params("token")}
+
+		err := u.userUseCase.VerifyEmail(c.UserContext(), req)
+		if err != nil {
+			return handlers.ManageError(err, c, u.logger)
+		}
+
+		return c.SendStatus(fiber.StatusOK)
+	}
+}
+
+// forgottenPassword saves a forgotten password request.
+// The response is always 202 Accepted, whether the email exists or not, so that it does not disclose the existing emails.
+// The request is processed after the response, its errors are only logged.
 func (u *User) forgottenPassword() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		email := c.Params("email")
-		req := requests.UserForgotPassword{Email: email}
+		req := new(requests.UserForgotPassword)
+		if err := c.BodyParser(req); err != nil {
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidBody, ""))
+		}
 
-		res, err := u.userUseCase.ForgottenPassword(req)
+		err := u.userUseCase.ForgottenPassword(c.UserContext(), *req)
 		if err != nil {
-			if errors.Is(err, utils.HTTPError{}) && err.Err != nil {
-				if details, ok := err.Details.(string); ok {
-					return utils.NewError(c, u.logger, err.Message, details, err.Err)
-				}
-			}
-			return c.Status(err.Code).JSON(err)
+			return handlers.ManageError(err, c, u.logger)
+		}
+
+		return c.SendStatus(fiber.StatusAccepted)
+	}
+}
+
+// forgottenPasswordLimiter limits the number of forgotten password requests by key.
+// The limit is read from the maxKey setting and the window from FORGOTTEN_PASSWORD_THROTTLE_DURATION (in minutes).
+func forgottenPasswordLimiter(maxKey string, defaultMax int, key func(*fiber.Ctx) string) fiber.Handler {
+	max := viper.GetInt(maxKey)
+	if max <= 0 {
+		max = defaultMax
+	}
+	expiration := viper.GetDuration("FORGOTTEN_PASSWORD_THROTTLE_DURATION") * time.Minute
+	if expiration <= 0 {
+		expiration = DefaultForgottenPasswordThrottleDuration
+	}
+
+	return limiter.New(limiter.Config{
+		Max:          max,
+		Expiration:   expiration,
+		KeyGenerator: key,
+		LimitReached: func(c *fiber.Ctx) error {
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeTooManyRequests, ""))
+		},
+	})
+}
+
+// getMe returns the authenticated user.
+func (u *User) getMe() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := u.userUseCase.Me(c.UserContext(), principal.Get(c))
+		if err != nil {
+			return handlers.ManageError(err, c, u.logger)
+		}
+
+		return c.JSON(user)
+	}
+}
+
+// updateMe partially updates the profile of the authenticated user.
+func (u *User) updateMe() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req := new(requests.UserProfileUpdate)
+		if err := c.BodyParser(req); err != nil {
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidBody, ""))
+		}
+
+		user, err := u.userUseCase.UpdateProfile(c.UserContext(), principal.Get(c), *req)
+		if err != nil {
+			return handlers.ManageError(err, c, u.logger)
+		}
+
+		return c.JSON(user)
+	}
+}
+
+// changeMyPassword changes the password of the authenticated user and returns new tokens.
+func (u *User) changeMyPassword() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req := new(requests.UserMePasswordUpdate)
+		if err := c.BodyParser(req); err != nil {
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidBody, ""))
+		}
+
+		res, err := u.userUseCase.ChangePassword(c.UserContext(), principal.Get(c), *req)
+		if err != nil {
+			return handlers.ManageError(err, c, u.logger)
 		}
 
 		return c.JSON(res)
 	}
 }
+
+// deleteMe deletes the authenticated user account.
+func (u *User) deleteMe() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req := new(requests.UserMeDeletion)
+		if err := c.BodyParser(req); err != nil {
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInvalidBody, ""))
+		}
+
+		err := u.userUseCase.DeleteAccount(c.UserContext(), principal.Get(c), *req)
+		if err != nil {
+			return handlers.ManageError(err, c, u.logger)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
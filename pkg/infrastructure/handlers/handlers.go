@@ -1,17 +1,49 @@
 package handlers
 
 import (
-	"errors"
+	"fmt"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/errs"
 	"github.com/fabienbellanger/fiber-boilerplate/utils"
 	"github.com/gofiber/fiber/v2"
 	"go.uber.org/zap"
 )
 
-func ManageError(err *utils.HTTPError, c *fiber.Ctx, logger *zap.Logger) error {
-	if errors.Is(err, utils.HTTPError{}) && err.Err != nil {
-		if details, ok := err.Details.(string); ok {
-			return utils.NewError(c, logger, err.Message, details, err.Err)
-		}
+// errorCodes maps the kinds of domain errors to their error code.
+var errorCodes = map[errs.Kind]string{
+	errs.KindValidation:   utils.ErrCodeValidationFailed,
+	errs.KindUnauthorized: utils.ErrCodeUnauthorized,
+	errs.KindForbidden:    utils.ErrCodeForbidden,
+	errs.KindNotFound:     utils.ErrCodeNotFound,
+	errs.KindConflict:     utils.ErrCodeConflict,
+}
+
+// ErrorCode returns the error code of a kind of domain error.
+func ErrorCode(kind errs.Kind) string {
+	if code, ok := errorCodes[kind]; ok {
+		return code
 	}
-	return c.Status(err.Code).JSON(err)
+	return utils.ErrCodeInternal
+}
+
+// ManageError translates a domain error into a problem response.
+// Internal errors are logged and their details are not sent to the client.
+func ManageError(err *errs.Error, c *fiber.Ctx, logger *zap.Logger) error {
+	if err.Kind == errs.KindInternal {
+		description, _ := err.Details.(string)
+		return utils.NewError(c, logger, err.Message, description, err.Err)
+	}
+
+	problem := utils.NewProblem(ErrorCode(err.Kind), err.Message)
+	switch details := err.Details.(type) {
+	case nil:
+	case utils.ValidatorErrors:
+		problem.Errors = details
+	case string:
+		problem.Detail = fmt.Sprintf("%s: %s", err.Message, details)
+	default:
+		problem.Details = details
+	}
+
+	return utils.SendProblem(c, problem)
 }
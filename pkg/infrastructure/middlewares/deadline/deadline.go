@@ -0,0 +1,91 @@
+package deadline
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Config defines the configuration for middleware.
+type Config struct {
+	// Next defines a function to skip this middleware when returned true.
+	//
+	// Optional. Default value nil.
+	Next func(c *fiber.Ctx) bool
+
+	// Timeout is the maximum duration of a request.
+	// The request context is canceled when it is reached.
+	//
+	// Optional. Default value 30 seconds.
+	Timeout time.Duration
+
+	// TimeoutHandler is called when the request deadline has been exceeded.
+	//
+	// Optional. Default value returns a 504 Gateway Timeout response.
+	TimeoutHandler fiber.Handler
+
+	// CanceledHandler is called when the request context has been canceled.
+	//
+	// Optional. Default value returns a 503 Service Unavailable response.
+	CanceledHandler fiber.Handler
+}
+
+// ConfigDefault is the default configuration.
+var ConfigDefault = Config{
+	Next:    nil,
+	Timeout: 30 * time.Second,
+	TimeoutHandler: func(c *fiber.Ctx) error {
+		return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeGatewayTimeout, ""))
+	},
+	CanceledHandler: func(c *fiber.Ctx) error {
+		return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeServiceUnavailable, ""))
+	},
+}
+
+// New creates a new instance of middleware handler.
+// It attaches a context with a deadline to the request (see c.UserContext()),
+// so that the database queries of a slow request are canceled.
+func New(config ...Config) func(*fiber.Ctx) error {
+	// Default configuration
+	cfg := ConfigDefault
+
+	// Override configuration if provided
+	if len(config) > 0 {
+		cfg = config[0]
+
+		if cfg.Timeout <= 0 {
+			cfg.Timeout = ConfigDefault.Timeout
+		}
+		if cfg.TimeoutHandler == nil {
+			cfg.TimeoutHandler = ConfigDefault.TimeoutHandler
+		}
+		if cfg.CanceledHandler == nil {
+			cfg.CanceledHandler = ConfigDefault.CanceledHandler
+		}
+	}
+
+	return func(c *fiber.Ctx) error {
+		if cfg.Next != nil && cfg.Next(c) {
+			return c.Next()
+		}
+
+		ctx, cancel := context.WithTimeout(c.UserContext(), cfg.Timeout)
+		defer cancel()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		// The context has expired and the handler failed because of it
+		if ctxErr := ctx.Err(); ctxErr != nil && (err != nil || c.Response().StatusCode() >= fiber.StatusInternalServerError) {
+			if errors.Is(ctxErr, context.DeadlineExceeded) {
+				return cfg.TimeoutHandler(c)
+			}
+			return cfg.CanceledHandler(c)
+		}
+
+		return err
+	}
+}
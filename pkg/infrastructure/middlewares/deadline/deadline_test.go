@@ -0,0 +1,52 @@
+package deadline
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		if c.Path() == "/canceled" {
+			ctx, cancel := context.WithCancel(c.UserContext())
+			cancel()
+			c.SetUserContext(ctx)
+		}
+		return c.Next()
+	})
+	app.Use(New(Config{Timeout: 20 * time.Millisecond}))
+	app.Get("/fast", func(c *fiber.Ctx) error {
+		_, ok := c.UserContext().Deadline()
+		assert.True(t, ok)
+		return c.SendStatus(fiber.StatusOK)
+	})
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		<-c.UserContext().Done()
+		return c.SendStatus(fiber.StatusInternalServerError)
+	})
+	app.Get("/canceled", func(c *fiber.Ctx) error {
+		return c.UserContext().Err()
+	})
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/fast", fiber.StatusOK},
+		{"/slow", fiber.StatusGatewayTimeout},
+		{"/canceled", fiber.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.path, nil))
+			assert.Nil(t, err)
+			assert.Equal(t, tt.code, resp.StatusCode)
+		})
+	}
+}
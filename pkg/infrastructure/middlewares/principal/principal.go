@@ -0,0 +1,90 @@
+package principal
+
+import (
+	"errors"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// contextKey is the key used to store the principal in the context.
+const contextKey = "principal"
+
+// New creates a new instance of middleware handler which decodes the JWT claims
+// into a typed principal stored in the context.
+// It must be used after the JWT middleware.
+func New() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, ok := utils.GetClaims(c)
+		if !ok {
+			return unauthorized(c)
+		}
+
+		p, err := FromClaims(claims)
+		if err != nil {
+			return unauthorized(c)
+		}
+		c.Locals(contextKey, p)
+
+		return c.Next()
+	}
+}
+
+// Get returns the principal of the request.
+// An unauthenticated principal is returned if the middleware has not been used.
+func Get(c *fiber.Ctx) entities.Principal {
+	p, ok := c.Locals(contextKey).(entities.Principal)
+	if !ok {
+		return entities.Principal{}
+	}
+	return p
+}
+
+// FromClaims decodes JWT claims into a principal.
+func FromClaims(claims jwt.MapClaims) (entities.Principal, error) {
+	id, _ := claims["id"].(string)
+	tokenID, _ := claims["jti"].(string)
+	if id == "" || tokenID == "" {
+		return entities.Principal{}, errors.New("missing id or jti claim")
+	}
+
+	username, _ := claims["username"].(string)
+	tokenVersion, _ := claims["tokenVersion"].(float64)
+
+	p := entities.Principal{
+		ID:           id,
+		Username:     username,
+		Roles:        stringSliceClaim(claims, "roles"),
+		Permissions:  stringSliceClaim(claims, "permissions"),
+		TokenID:      tokenID,
+		TokenVersion: uint(tokenVersion),
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		p.ExpiresAt = exp.Time
+	}
+
+	return p, nil
+}
+
+// stringSliceClaim returns a claim as a slice of strings.
+func stringSliceClaim(claims jwt.MapClaims, key string) []string {
+	values := make([]string, 0)
+	list, ok := claims[key].([]interface{})
+	if !ok {
+		return values
+	}
+
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+// unauthorized returns a 401 Unauthorized response.
+func unauthorized(c *fiber.Ctx) error {
+	return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeUnauthorized, ""))
+}
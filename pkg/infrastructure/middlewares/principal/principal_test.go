@@ -0,0 +1,40 @@
+package principal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromClaims(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	p, err := FromClaims(jwt.MapClaims{
+		"id":           "3d2f0e5c-3b1a-4c3e-8d0f-0b9a4a5e2c1d",
+		"username":     "test@test.com",
+		"roles":        []interface{}{entities.RoleAdmin},
+		"permissions":  []interface{}{entities.PermissionUsersRead, 12},
+		"jti":          "b7f3c1a2-9e4d-4f6b-8a1c-2d3e4f5a6b7c",
+		"tokenVersion": float64(2),
+		"exp":          float64(exp.Unix()),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, entities.Principal{
+		ID:           "3d2f0e5c-3b1a-4c3e-8d0f-0b9a4a5e2c1d",
+		Username:     "test@test.com",
+		Roles:        []string{entities.RoleAdmin},
+		Permissions:  []string{entities.PermissionUsersRead},
+		TokenID:      "b7f3c1a2-9e4d-4f6b-8a1c-2d3e4f5a6b7c",
+		TokenVersion: 2,
+		ExpiresAt:    exp,
+	}, p)
+	assert.True(t, p.HasRole(entities.RoleAdmin))
+	assert.True(t, p.HasPermission(entities.PermissionUsersRead))
+	assert.False(t, p.HasPermission(entities.PermissionUsersDelete))
+
+	_, err = FromClaims(jwt.MapClaims{"id": "3d2f0e5c-3b1a-4c3e-8d0f-0b9a4a5e2c1d"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,31 @@
+package rbac
+
+import (
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/infrastructure/middlewares/principal"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequirePermission returns a middleware handler which allows the request only if
+// the authenticated user has all the permissions.
+// It must be used after the principal middleware.
+//
+// Permissions are read from the JWT claims, so a role change only applies to the tokens issued afterwards.
+// This is why the role assign command increments the user token version: the current access tokens
+// are rejected and new ones, with the new permissions, must be obtained from the refresh token.
+func RequirePermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		p := principal.Get(c)
+		if !p.IsAuthenticated() {
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeUnauthorized, ""))
+		}
+
+		for _, permission := range permissions {
+			if !p.HasPermission(permission) {
+				return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeForbidden, ""))
+			}
+		}
+
+		return c.Next()
+	}
+}
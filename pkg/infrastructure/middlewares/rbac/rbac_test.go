@@ -0,0 +1,56 @@
+package rbac
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/infrastructure/middlewares/principal"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequirePermission(t *testing.T) {
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		if permissions := c.Get("X-Permissions"); permissions != "" {
+			c.Locals("user", &jwt.Token{Claims: jwt.MapClaims{
+				"id":          "3d2f0e5c-3b1a-4c3e-8d0f-0b9a4a5e2c1d",
+				"jti":         "b7f3c1a2-9e4d-4f6b-8a1c-2d3e4f5a6b7c",
+				"permissions": []interface{}{permissions},
+			}})
+			return principal.New()(c)
+		}
+		return c.Next()
+	})
+	app.Get("/users", RequirePermission(entities.PermissionUsersRead), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+	app.Delete("/users", RequirePermission(entities.PermissionUsersRead, entities.PermissionUsersDelete), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		permissions string
+		code        int
+	}{
+		{"allowed", "GET", entities.PermissionUsersRead, fiber.StatusOK},
+		{"denied", "GET", entities.PermissionUsersCreate, fiber.StatusForbidden},
+		{"one of the permissions missing", "DELETE", entities.PermissionUsersRead, fiber.StatusForbidden},
+		{"missing claims", "GET", "", fiber.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/users", nil)
+			if tt.permissions != "" {
+				req.Header.Set("X-Permissions", tt.permissions)
+			}
+			resp, err := app.Test(req)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.code, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package revocation
+
+import (
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/infrastructure/middlewares/principal"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Config defines the configuration for middleware.
+type Config struct {
+	// Validator checks that the token of the principal has not been revoked.
+	//
+	// Required.
+	Validator func(c *fiber.Ctx, p entities.Principal) error
+
+	// ErrorHandler is called when the token has been revoked or cannot be checked.
+	//
+	// Optional. Default value returns a 401 Unauthorized response.
+	ErrorHandler fiber.ErrorHandler
+}
+
+// ConfigDefault is the default configuration.
+var ConfigDefault = Config{
+	ErrorHandler: func(c *fiber.Ctx, err error) error {
+		return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeUnauthorized, ""))
+	},
+}
+
+// New creates a new instance of middleware handler.
+// It must be used after the principal middleware.
+func New(config Config) func(*fiber.Ctx) error {
+	cfg := config
+	if cfg.Validator == nil {
+		panic("revocation: Validator is required")
+	}
+	if cfg.ErrorHandler == nil {
+		cfg.ErrorHandler = ConfigDefault.ErrorHandler
+	}
+
+	return func(c *fiber.Ctx) error {
+		p := principal.Get(c)
+		if !p.IsAuthenticated() {
+			return cfg.ErrorHandler(c, nil)
+		}
+
+		if err := cfg.Validator(c, p); err != nil {
+			return cfg.ErrorHandler(c, err)
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,66 @@
+package revocation
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/infrastructure/middlewares/principal"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	revoked := "b7f3c1a2-9e4d-4f6b-8a1c-2d3e4f5a6b7c"
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		if jti := c.Get("X-Token-ID"); jti != "" {
+			c.Locals("user", &jwt.Token{Claims: jwt.MapClaims{
+				"id":           "3d2f0e5c-3b1a-4c3e-8d0f-0b9a4a5e2c1d",
+				"jti":          jti,
+				"tokenVersion": float64(1),
+			}})
+			return principal.New()(c)
+		}
+		return c.Next()
+	})
+	app.Use(New(Config{
+		Validator: func(c *fiber.Ctx, p entities.Principal) error {
+			if p.TokenID == revoked {
+				return errors.New("token revoked")
+			}
+			return nil
+		},
+	}))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	tests := []struct {
+		name    string
+		tokenID string
+		code    int
+	}{
+		{"valid token", "c0f0d5a4-7b4e-4a53-8d6a-1f3e2b5c7d9e", fiber.StatusOK},
+		{"revoked token", revoked, fiber.StatusUnauthorized},
+		{"unauthenticated", "", fiber.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.tokenID != "" {
+				req.Header.Set("X-Token-ID", tt.tokenID)
+			}
+			resp, err := app.Test(req)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.code, resp.StatusCode)
+		})
+	}
+}
+
+func TestNewWithoutValidator(t *testing.T) {
+	assert.Panics(t, func() { New(Config{}) })
+}
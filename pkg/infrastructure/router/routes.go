@@ -7,6 +7,7 @@ import (
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/usecases"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/infrastructure/handlers/api"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/infrastructure/handlers/web"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
 	"net/http"
 
 	"github.com/gofiber/fiber/v2"
@@ -51,9 +52,7 @@ func registerPublicWebRoutes(r fiber.Router, logger *zap.Logger) {
 
 func registerPublicAPIRoutes(r fiber.Router, db *db.DB, logger *zap.Logger) {
 	v1 := r.Group("/v1")
-	userStore := stores.NewUserStore(db)
-	userService := services.NewUser(userStore)
-	userUserCase := usecases.NewUser(userService)
+	userUserCase := newUserUseCase(db)
 
 	// Login & password reset
 	users := api.NewUser(v1, userUserCase, logger)
@@ -71,14 +70,21 @@ func registerProtectedAPIRoutes(r fiber.Router, db *db.DB, logger *zap.Logger) {
 }
 
 func registerUser(r fiber.Router, db *db.DB, logger *zap.Logger) {
-	userStore := stores.NewUserStore(db)
-	userService := services.NewUser(userStore)
-	userUserCase := usecases.NewUser(userService)
+	userUserCase := newUserUseCase(db)
+
+	// Logout
+	auth := api.NewUser(r, userUserCase, logger)
+	auth.UserLogoutRoutes()
 
 	// Users
 	userGroup := r.Group("/users")
 	users := api.NewUser(userGroup, userUserCase, logger)
 	users.UserProtectedRoutes()
+
+	// Authenticated user account
+	meGroup := r.Group("/me")
+	me := api.NewUser(meGroup, userUserCase, logger)
+	me.UserMeRoutes()
 }
 
 func registerTask(r fiber.Router, db *db.DB, logger *zap.Logger) {
@@ -90,3 +96,13 @@ func registerTask(r fiber.Router, db *db.DB, logger *zap.Logger) {
 	tasks := api.NewTask(taskGroup, taskUserCase, logger)
 	tasks.TaskProtectedRoutes()
 }
+
+// newUserUseCase returns a user use case backed by the database.
+func newUserUseCase(db *db.DB) usecases.User {
+	passwordHasher := utils.NewPasswordHasher(viper.GetString("PASSWORD_HASHER"))
+	userStore := stores.NewUserStore(db, passwordHasher)
+	txManager := stores.NewTxManager(db, passwordHasher)
+	userService := services.NewUser(userStore, txManager, passwordHasher)
+
+	return usecases.NewUser(userService)
+}
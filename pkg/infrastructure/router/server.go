@@ -1,8 +1,17 @@
 package router
 
 import (
+	"errors"
 	"fmt"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/errs"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/services"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/infrastructure/handlers"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/infrastructure/middlewares/deadline"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/infrastructure/middlewares/principal"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/infrastructure/middlewares/revocation"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/infrastructure/middlewares/timer"
 	"os"
 	"os/signal"
@@ -62,6 +71,13 @@ func Setup(db *db.DB, logger *zap.Logger, templatesPath string) (*fiber.App, err
 	initMiddlewares(app, logger)
 	initTools(app)
 
+	// Errors of the works run after the responses
+	// --------------------------------------------
+	services.LogError = func(err *errs.Error) {
+		description, _ := err.Details.(string)
+		logger.Error(description, zap.String("description", err.Message), zap.Error(err.Err))
+	}
+
 	// Routes
 	// ------
 	web := app.Group("")
@@ -74,7 +90,7 @@ func Setup(db *db.DB, logger *zap.Logger, templatesPath string) (*fiber.App, err
 
 	// Protected routes
 	// ----------------
-	err := initJWT(app)
+	err := initJWT(app, db, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -82,11 +98,8 @@ func Setup(db *db.DB, logger *zap.Logger, templatesPath string) (*fiber.App, err
 
 	// Custom 404 (after all routes but not available because of JWT)
 	// --------------------------------------------------------------
-	app.Use(func(ctx *fiber.Ctx) error {
-		return ctx.Status(fiber.StatusNotFound).JSON(utils.HTTPError{
-			Code:    fiber.StatusNotFound,
-			Message: "Resource Not Found",
-		})
+	app.Use(func(c *fiber.Ctx) error {
+		return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeNotFound, ""))
 	})
 
 	return app, nil
@@ -134,7 +147,7 @@ func initConfig(logger *zap.Logger, templatesPath string) fiber.Config {
 					zap.String("ip", c.IP()),
 					zap.String("requestId", fmt.Sprintf("%v", requestID)))
 
-				return c.Status(code).JSON(e)
+				return utils.SendProblem(c, utils.NewStatusProblem(code, e.Message))
 			}
 
 			// Internal Server Error
@@ -152,10 +165,7 @@ func initConfig(logger *zap.Logger, templatesPath string) fiber.Config {
 					zap.String("ip", c.IP()),
 					zap.String("requestId", fmt.Sprintf("%v", requestID)))
 
-				return c.Status(code).JSON(utils.HTTPError{
-					Code:    code,
-					Message: "Internal Server Error",
-				})
+				return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeInternal, ""))
 			}
 			return nil
 		},
@@ -202,6 +212,19 @@ func initMiddlewares(s *fiber.App, logger *zap.Logger) {
 	// Request ID
 	// ----------
 	s.Use(requestid.New())
+	s.Use(func(c *fiber.Ctx) error {
+		// The request ID is carried by the context to correlate the database logs
+		c.SetUserContext(utils.ContextWithRequestID(c.UserContext(), fmt.Sprintf("%v", c.Locals("requestid"))))
+		return c.Next()
+	})
+
+	// Request deadline
+	// ----------------
+	if timeout := viper.GetDuration("SERVER_REQUEST_TIMEOUT"); timeout > 0 {
+		s.Use(deadline.New(deadline.Config{
+			Timeout: timeout * time.Second,
+		}))
+	}
 
 	// Timer
 	// -----
@@ -229,7 +252,7 @@ func initMiddlewares(s *fiber.App, logger *zap.Logger) {
 				return c.IP()
 			},
 			LimitReached: func(c *fiber.Ctx) error {
-				return fiber.NewError(fiber.StatusTooManyRequests, "Too Many Requests")
+				return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeTooManyRequests, ""))
 			},
 		}))
 	}
@@ -270,7 +293,7 @@ func initTools(s *fiber.App) {
 	}
 }
 
-func initJWT(s *fiber.App) (err error) {
+func initJWT(s *fiber.App, db *db.DB, logger *zap.Logger) (err error) {
 	algo := viper.GetString("JWT_ALGO")
 	key, err := utils.GetKeyFromAlgo(algo, viper.GetString("JWT_SECRET"), viper.GetString("JWT_PUBLIC_KEY_PATH"))
 	if err != nil {
@@ -283,10 +306,35 @@ func initJWT(s *fiber.App) (err error) {
 			Key:    key,
 		},
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			return c.Status(fiber.StatusUnauthorized).JSON(utils.HTTPError{
-				Code:    fiber.StatusUnauthorized,
-				Message: "Unauthorized",
-			})
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeUnauthorized, ""))
+		},
+	}))
+
+	// Authenticated principal
+	// -----------------------
+	s.Use(principal.New())
+
+	// Revoked tokens
+	// --------------
+	userUseCase := newUserUseCase(db)
+	s.Use(revocation.New(revocation.Config{
+		Validator: func(c *fiber.Ctx, p entities.Principal) error {
+			if err := userUseCase.CheckToken(c.UserContext(), requests.UserToken{
+				UserID:       p.ID,
+				TokenID:      p.TokenID,
+				TokenVersion: p.TokenVersion,
+			}); err != nil {
+				return err
+			}
+			return nil
+		},
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			var e *errs.Error
+			if errors.As(err, &e) && e.Kind != errs.KindUnauthorized {
+				return handlers.ManageError(e, c, logger)
+			}
+
+			return utils.SendProblem(c, utils.NewProblem(utils.ErrCodeUnauthorized, ""))
 		},
 	}))
 
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+
+	"github.com/fabienbellanger/fiber-boilerplate/tests"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// Fixtures
+const (
+	fixtureUserID = "9c2f7a3e-5d1b-4c8a-9e6f-1a2b3c4d5e6f" // Owner of the tasks
+	otherUserID   = "4b8e2d1f-6c3a-4e7b-9d5f-0a1b2c3d4e5f"
+	firstTaskID   = "1f0e3b7a-2c4d-4e5f-8a9b-0c1d2e3f4a5b" // todo
+	secondTaskID  = "2a1b4c8d-3e5f-4a6b-9c0d-1e2f3a4b5c6d" // done
+)
+
+// taskRequest returns a test of a request on a task route with a JSON body if body is not empty.
+func taskRequest(description, method, route, token, body string, expectedCode int) tests.Test {
+	test := tests.Test{
+		Description: description,
+		Route:       "/api/v1/tasks" + route,
+		Method:      method,
+		Headers: []tests.Header{
+			{Key: "Authorization", Value: "Bearer " + token},
+		},
+		CheckCode:    true,
+		ExpectedCode: expectedCode,
+	}
+	if body != "" {
+		test.Body = strings.NewReader(body)
+		test.Headers = append(test.Headers, tests.Header{Key: "Content-Type", Value: fiber.MIMEApplicationJSONCharsetUTF8})
+	}
+	return test
+}
+
+func TestTaskGetByID(t *testing.T) {
+	database := tdb.Tx(t)
+	assert.Nil(t, tests.LoadFixtures(database, "../fixtures", "users", "tasks"))
+
+	useCases := []tests.Test{
+		{
+			Description: "Get a task of another user with the tasks:all permission",
+			Route:       "/api/v1/tasks/1f0e3b7a-2c4d-4e5f-8a9b-0c1d2e3f4a5b",
+			Method:      "GET",
+			Headers: []tests.Header{
+				{Key: "Authorization", Value: "Bearer " + tdb.Token},
+			},
+			CheckCode:    true,
+			ExpectedCode: 200,
+		},
+		{
+			Description: "Get an unknown task",
+			Route:       "/api/v1/tasks/00000000-0000-0000-0000-000000000000",
+			Method:      "GET",
+			Headers: []tests.Header{
+				{Key: "Authorization", Value: "Bearer " + tdb.Token},
+			},
+			CheckCode:    true,
+			ExpectedCode: 404,
+		},
+	}
+
+	tests.Execute(t, database, useCases, "../../templates")
+}
+
+func TestTaskOwnership(t *testing.T) {
+	database := tdb.Tx(t)
+	assert.Nil(t, tests.LoadFixtures(database, "../fixtures", "users", "user_roles", "tasks"))
+
+	ownerToken := tests.UserToken(t, database, fixtureUserID)
+	otherToken := tests.UserToken(t, database, otherUserID)
+
+	// Tasks of other users are reported as not found
+	useCases := []tests.Test{
+		taskRequest("Get a task of another user", "GET", "/"+firstTaskID, otherToken, "", 404),
+		taskRequest("Update a task of another user", "PUT", "/"+firstTaskID, otherToken, `{"name":"Renamed task"}`, 404),
+		taskRequest("Patch a task of another user", "PATCH", "/"+firstTaskID, otherToken, `{"name":"Renamed task"}`, 404),
+		taskRequest("Delete a task of another user", "DELETE", "/"+firstTaskID, otherToken, "", 404),
+		taskRequest("Transition a task of another user", "POST", "/"+firstTaskID+"/transition", otherToken, `{"state":"in_progress"}`, 404),
+		taskRequest("List the tasks of another user without the tasks:all permission", "GET", "?owner="+fixtureUserID, otherToken, "", 403),
+		taskRequest("List the tasks of all users without the tasks:all permission", "GET", "?owner=all", otherToken, "", 403),
+		taskRequest("Get an own task", "GET", "/"+firstTaskID, ownerToken, "", 200),
+		taskRequest("List the tasks of another user with the tasks:all permission", "GET", "?owner="+fixtureUserID, tdb.Token, "", 200),
+		taskRequest("List the tasks of all users with the tasks:all permission", "GET", "?owner=all", tdb.Token, "", 200),
+	}
+
+	tests.Execute(t, database, useCases, "../../templates")
+
+	// The task has not been changed
+	var task entities.Task
+	assert.Nil(t, database.First(&task, "id = ?", firstTaskID).Error)
+	assert.Equal(t, "First task", task.Name)
+	assert.Equal(t, entities.TaskStateTodo, task.State)
+}
+
+func TestTaskCRUD(t *testing.T) {
+	database := tdb.Tx(t)
+	assert.Nil(t, tests.LoadFixtures(database, "../fixtures", "users", "user_roles", "tasks"))
+
+	token := tests.UserToken(t, database, fixtureUserID)
+
+	useCases := []tests.Test{
+		taskRequest("Create a task", "POST", "", token, `{"name":"New task","description":"New task description"}`, 200),
+		taskRequest("Create a task with an invalid name", "POST", "", token, `{"name":"ab"}`, 400),
+		taskRequest("Update a task", "PUT", "/"+firstTaskID, token, `{"name":"Updated task","description":"Updated description"}`, 200),
+		taskRequest("Update a task without name", "PUT", "/"+firstTaskID, token, `{"description":"Updated description"}`, 400),
+		taskRequest("Update a task with an invalid name", "PUT", "/"+firstTaskID, token, `{"name":"ab"}`, 400),
+		taskRequest("Update a task with an invalid body", "PUT", "/"+firstTaskID, token, `{"name":`, 400),
+		taskRequest("Update an unknown task", "PUT", "/00000000-0000-0000-0000-000000000000", token, `{"name":"Updated task"}`, 404),
+		taskRequest("Patch a task", "PATCH", "/"+firstTaskID, token, `{"description":"Patched description"}`, 200),
+		taskRequest("Restore a task which is not deleted", "POST", "/"+firstTaskID+"/restore", token, "", 404),
+		taskRequest("Delete a task", "DELETE", "/"+firstTaskID, token, "", 204),
+		taskRequest("Get a deleted task", "GET", "/"+firstTaskID, token, "", 404),
+		taskRequest("Delete a deleted task", "DELETE", "/"+firstTaskID, token, "", 404),
+		taskRequest("Restore a deleted task of another user", "POST", "/"+firstTaskID+"/restore", tests.UserToken(t, database, otherUserID), "", 404),
+		taskRequest("Restore a deleted task", "POST", "/"+firstTaskID+"/restore", token, "", 200),
+		taskRequest("Get a restored task", "GET", "/"+firstTaskID, token, "", 200),
+	}
+
+	tests.Execute(t, database, useCases, "../../templates")
+
+	// Only the fields of the successful updates are saved
+	var task entities.Task
+	assert.Nil(t, database.First(&task, "id = ?", firstTaskID).Error)
+	assert.Equal(t, "Updated task", task.Name)
+	assert.Equal(t, "Patched description", task.Description)
+	assert.False(t, task.DeletedAt.Valid)
+}
+
+func TestTaskTransition(t *testing.T) {
+	database := tdb.Tx(t)
+	assert.Nil(t, tests.LoadFixtures(database, "../fixtures", "users", "user_roles", "tasks"))
+
+	token := tests.UserToken(t, database, fixtureUserID)
+
+	useCases := []tests.Test{
+		taskRequest("Start a task", "POST", "/"+firstTaskID+"/transition", token, `{"state":"in_progress"}`, 200),
+		taskRequest("Transition to an unknown state", "POST", "/"+firstTaskID+"/transition", token, `{"state":"unknown"}`, 400),
+		taskRequest("Transition without state", "POST", "/"+firstTaskID+"/transition", token, `{}`, 400),
+		{
+			Description: "Transition not allowed",
+			Route:       "/api/v1/tasks/" + secondTaskID + "/transition",
+			Method:      "POST",
+			Body:        strings.NewReader(`{"state":"todo"}`),
+			Headers: []tests.Header{
+				{Key: "Authorization", Value: "Bearer " + token},
+				{Key: "Content-Type", Value: fiber.MIMEApplicationJSONCharsetUTF8},
+				{Key: "X-Request-ID", Value: "task-transition-409"},
+			},
+			CheckBody:    true,
+			CheckCode:    true,
+			ExpectedCode: 409,
+			ExpectedBody: `{"type":"urn:problem-type:conflict","title":"Conflict","status":409,"detail":"Transition not allowed","instance":"task-transition-409","code":"conflict","details":{"allowed":["in_progress","archived"],"from":"done","to":"todo"}}`,
+		},
+		taskRequest("Archive a done task", "POST", "/"+secondTaskID+"/transition", token, `{"state":"archived"}`, 200),
+		taskRequest("Transition of an archived task", "POST", "/"+secondTaskID+"/transition", token, `{"state":"in_progress"}`, 409),
+	}
+
+	tests.Execute(t, database, useCases, "../../templates")
+
+	// The history keeps the successful transitions
+	var count int64
+	assert.Nil(t, database.Model(&entities.TaskTransition{}).Where("task_id IN ?", []string{firstTaskID, secondTaskID}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestTaskFixturesRolledBack(t *testing.T) {
+	var count int64
+	tdb.DB.Table("tasks").Count(&count)
+	assert.Equal(t, int64(0), count)
+}
@@ -1,18 +1,29 @@
 package api
 
 import (
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/requests"
+	values_objects "github.com/fabienbellanger/fiber-boilerplate/pkg/domain/value_objects"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/fabienbellanger/fiber-boilerplate/tests"
 	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
 )
 
-func TestUserCreation(t *testing.T) {
-	tdb := tests.Init("../../.env")
-	defer tdb.Drop()
+var tdb tests.TestDB
+
+func TestMain(m *testing.M) {
+	tdb = tests.Init("../../.env")
+	code := m.Run()
+	tdb.Drop()
+	os.Exit(code)
+}
 
+func TestUserCreation(t *testing.T) {
 	useCases := []tests.Test{
 		{
 			Description: "User creation",
@@ -20,7 +31,7 @@ func TestUserCreation(t *testing.T) {
 			Method:      "POST",
 			Body: strings.NewReader(tests.JsonToString(requests.UserCreation{
 				Username:  "test1@gmail.com",
-				Password:  "11111111",
+				Password:  "new-secret-1",
 				Lastname:  "Test",
 				Firstname: "Creation",
 			})),
@@ -31,24 +42,62 @@ func TestUserCreation(t *testing.T) {
 			CheckCode:    true,
 			ExpectedCode: 200,
 		},
+		{
+			Description: "User creation with an existing username",
+			Route:       "/api/v1/users",
+			Method:      "POST",
+			Body: strings.NewReader(tests.JsonToString(requests.UserCreation{
+				Username:  "test1@gmail.com",
+				Password:  "new-secret-1",
+				Lastname:  "Test",
+				Firstname: "Duplicate",
+			})),
+			Headers: []tests.Header{
+				{Key: "Content-Type", Value: fiber.MIMEApplicationJSONCharsetUTF8},
+				{Key: "Authorization", Value: "Bearer " + tdb.Token},
+				{Key: "X-Request-ID", Value: "user-creation-409"},
+			},
+			CheckBody:    true,
+			CheckCode:    true,
+			ExpectedCode: 409,
+			ExpectedBody: `{"type":"urn:problem-type:conflict","title":"Conflict","status":409,"detail":"Username already exists","instance":"user-creation-409","code":"conflict"}`,
+		},
+		{
+			Description: "User creation with an existing username in another case",
+			Route:       "/api/v1/users",
+			Method:      "POST",
+			Body: strings.NewReader(tests.JsonToString(requests.UserCreation{
+				Username:  " Test1@GMAIL.com",
+				Password:  "new-secret-1",
+				Lastname:  "Test",
+				Firstname: "Duplicate",
+			})),
+			Headers: []tests.Header{
+				{Key: "Content-Type", Value: fiber.MIMEApplicationJSONCharsetUTF8},
+				{Key: "Authorization", Value: "Bearer " + tdb.Token},
+			},
+			CheckCode:    true,
+			ExpectedCode: 409,
+		},
 		{
 			Description: "User creation with invalid password",
 			Route:       "/api/v1/users",
 			Method:      "POST",
 			Body: strings.NewReader(tests.JsonToString(requests.UserCreation{
 				Username:  "test1@gmail.com",
-				Password:  "1111111",
+				Password:  "secret1",
 				Lastname:  "Test",
 				Firstname: "Creation",
 			})),
 			Headers: []tests.Header{
 				{Key: "Content-Type", Value: fiber.MIMEApplicationJSONCharsetUTF8},
 				{Key: "Authorization", Value: "Bearer " + tdb.Token},
+				{Key: "X-Request-ID", Value: "user-creation-password"},
 			},
 			CheckBody:    true,
 			CheckCode:    true,
 			ExpectedCode: 400,
-			ExpectedBody: `{"code":400,"message":"Invalid body","details":[{"FailedField":"Password","Tag":"min","Value":"8"}]}`,
+			ExpectedBody: `{"type":"urn:problem-type:validation_failed","title":"Validation failed","status":400,"detail":"Invalid body","instance":"user-creation-password","code":"validation_failed","errors":[{"field":"password","tag":"min","value":"8","message":"password must be at least 8 characters long"}]}`,
 		},
 		{
 			Description: "User creation with invalid username",
@@ -56,28 +105,80 @@ func TestUserCreation(t *testing.T) {
 			Method:      "POST",
 			Body: strings.NewReader(tests.JsonToString(requests.UserCreation{
 				Username:  "test1",
-				Password:  "11111111",
+				Password:  "new-secret-1",
 				Lastname:  "Test",
 				Firstname: "Creation",
 			})),
 			Headers: []tests.Header{
 				{Key: "Content-Type", Value: fiber.MIMEApplicationJSONCharsetUTF8},
 				{Key: "Authorization", Value: "Bearer " + tdb.Token},
+				{Key: "X-Request-ID", Value: "user-creation-username"},
+				{Key: "Accept-Language", Value: "fr-FR,fr;q=0.9,en;q=0.8"},
 			},
 			CheckBody:    true,
 			CheckCode:    true,
 			ExpectedCode: 400,
-			ExpectedBody: `{"code":400,"message":"Invalid body","details":[{"FailedField":"Username","Tag":"email","Value":""}]}`,
+			ExpectedBody: `{"type":"urn:problem-type:validation_failed","title":"Validation failed","status":400,"detail":"Invalid body","instance":"user-creation-username","code":"validation_failed","errors":[{"field":"username","tag":"email","message":"username doit être une adresse email valide"}]}`,
 		},
 	}
 
-	tests.Execute(t, tdb.DB, useCases, "../../templates")
+	tests.Execute(t, tdb.Tx(t), useCases, "../../templates")
 }
 
-func TestUserLogin(t *testing.T) {
-	tdb := tests.Init("../../.env")
-	defer tdb.Drop()
+func TestUserPermissions(t *testing.T) {
+	database := tdb.Tx(t)
+	assert.Nil(t, tests.LoadFixtures(database, "../fixtures", "users"))
+
+	// The fixture user has no role
+	userID := "9c2f7a3e-5d1b-4c8a-9e6f-1a2b3c4d5e6f"
+	userToken := tests.UserToken(t, database, userID)
+
+	request := func(description, method, token string, expectedCode int) tests.Test {
+		test := tests.Test{
+			Description: description,
+			Route:       "/api/v1/users/" + userID,
+			Method:      method,
+			Headers: []tests.Header{
+				{Key: "Authorization", Value: "Bearer " + token},
+			},
+			CheckCode:    true,
+			ExpectedCode: expectedCode,
+		}
+		if method == "PUT" {
+			test.Body = strings.NewReader(tests.JsonToString(requests.UserUpdate{
+				Username:  "fixture@test.com",
+				Password:  "new-secret-1",
+				Lastname:  "Fixture",
+				Firstname: "User",
+			}))
+			test.Headers = append(test.Headers, tests.Header{Key: "Content-Type", Value: fiber.MIMEApplicationJSONCharsetUTF8})
+		}
+		return test
+	}
+
+	useCases := []tests.Test{
+		request("Get their own account without the users:read permission", "GET", userToken, 403),
+		request("Update their own account without the users:update permission", "PUT", userToken, 403),
+		request("Delete their own account without the users:delete permission", "DELETE", userToken, 403),
+		{
+			Description: "Get their own account with the /me endpoint",
+			Route:       "/api/v1/me",
+			Method:      "GET",
+			Headers: []tests.Header{
+				{Key: "Authorization", Value: "Bearer " + userToken},
+			},
+			CheckCode:    true,
+			ExpectedCode: 200,
+		},
+		request("Get a user with the users:read permission", "GET", tdb.Token, 200),
+		request("Update a user with the users:update permission", "PUT", tdb.Token, 200),
+		request("Delete a user with the users:delete permission", "DELETE", tdb.Token, 204),
+	}
 
+	tests.Execute(t, database, useCases, "../../templates")
+}
+
+func TestUserLogin(t *testing.T) {
 	useCases := []tests.Test{
 		{
 			Description: "User login",
@@ -95,5 +196,231 @@ func TestUserLogin(t *testing.T) {
 		},
 	}
 
-	tests.Execute(t, tdb.DB, useCases, "../../templates")
+	tests.Execute(t, tdb.Tx(t), useCases, "../../templates")
+}
+
+func TestUserRefreshToken(t *testing.T) {
+	useCases := []tests.Test{
+		{
+			Description: "Refresh token with an unknown token",
+			Route:       "/api/v1/token/refresh",
+			Method:      "POST",
+			Body:        strings.NewReader(tests.JsonToString(requests.TokenRefresh{RefreshToken: "unknown"})),
+			Headers: []tests.Header{
+				{Key: "Content-Type", Value: fiber.MIMEApplicationJSONCharsetUTF8},
+				{Key: "X-Request-ID", Value: "token-refresh-401"},
+			},
+			CheckBody:    true,
+			CheckCode:    true,
+			ExpectedCode: 401,
+			ExpectedBody: `{"type":"urn:problem-type:unauthorized","title":"Unauthorized","status":401,"detail":"Unauthorized","instance":"token-refresh-401","code":"unauthorized"}`,
+		},
+		{
+			Description: "Refresh token without token",
+			Route:       "/api/v1/token/refresh",
+			Method:      "POST",
+			Body:        strings.NewReader(tests.JsonToString(requests.TokenRefresh{})),
+			Headers: []tests.Header{
+				{Key: "Content-Type", Value: fiber.MIMEApplicationJSONCharsetUTF8},
+			},
+			CheckCode:    true,
+			ExpectedCode: 400,
+		},
+	}
+
+	tests.Execute(t, tdb.Tx(t), useCases, "../../templates")
+}
+
+func TestUserLogout(t *testing.T) {
+	getMe := func(description string, expectedCode int) tests.Test {
+		return tests.Test{
+			Description: description,
+			Route:       "/api/v1/me",
+			Method:      "GET",
+			Headers: []tests.Header{
+				{Key: "Authorization", Value: "Bearer " + tdb.Token},
+			},
+			CheckCode:    true,
+			ExpectedCode: expectedCode,
+		}
+	}
+	logout := func(description, route string) tests.Test {
+		return tests.Test{
+			Description: description,
+			Route:       route,
+			Method:      "POST",
+			Headers: []tests.Header{
+				{Key: "Authorization", Value: "Bearer " + tdb.Token},
+			},
+			CheckCode:    true,
+			ExpectedCode: 204,
+		}
+	}
+
+	t.Run("jti on the denylist", func(t *testing.T) {
+		tests.Execute(t, tdb.Tx(t), []tests.Test{
+			getMe("Token valid before logout", 200),
+			logout("Logout", "/api/v1/logout"),
+			getMe("Token revoked by logout", 401),
+		}, "../../templates")
+	})
+
+	t.Run("stale token version after logout from all devices", func(t *testing.T) {
+		tests.Execute(t, tdb.Tx(t), []tests.Test{
+			getMe("Token valid before logout", 200),
+			logout("Logout from all devices", "/api/v1/logout/all"),
+			getMe("Token revoked by logout from all devices", 401),
+		}, "../../templates")
+	})
+
+	t.Run("stale token version after password reset", func(t *testing.T) {
+		database := tdb.Tx(t)
+
+		var userID string
+		assert.Nil(t, database.Table("users").Select("id").Where("username = ?", tests.UserUsername).Scan(&userID).Error)
+		passwordReset, token, err := entities.NewPasswordReset(userID, time.Hour)
+		assert.Nil(t, err)
+		assert.Nil(t, database.Create(&passwordReset).Error)
+
+		tests.Execute(t, database, []tests.Test{
+			getMe("Token valid before password reset", 200),
+			{
+				Description: "Password reset",
+				Route:       "/api/v1/update-password/" + token,
+				Method:      "PATCH",
+				Body:        strings.NewReader(`{"password":"new-secret-1"}`),
+				Headers: []tests.Header{
+					{Key: "Content-Type", Value: fiber.MIMEApplicationJSONCharsetUTF8},
+				},
+				CheckCode:    true,
+				ExpectedCode: 200,
+			},
+			getMe("Token revoked by password reset", 401),
+		}, "../../templates")
+	})
+}
+
+func TestUserMe(t *testing.T) {
+	useCases := []tests.Test{
+		{
+			Description: "Get the authenticated user",
+			Route:       "/api/v1/me",
+			Method:      "GET",
+			Headers: []tests.Header{
+				{Key: "Authorization", Value: "Bearer " + tdb.Token},
+			},
+			CheckCode:    true,
+			ExpectedCode: 200,
+		},
+		{
+			Description:  "Get the authenticated user without token",
+			Route:        "/api/v1/me",
+			Method:       "GET",
+			CheckCode:    true,
+			ExpectedCode: 401,
+		},
+		{
+			Description: "Update the authenticated user profile",
+			Route:       "/api/v1/me",
+			Method:      "PATCH",
+			Body:        strings.NewReader(`{"firstname": "Me"}`),
+			Headers: []tests.Header{
+				{Key: "Content-Type", Value: fiber.MIMEApplicationJSONCharsetUTF8},
+				{Key: "Authorization", Value: "Bearer " + tdb.Token},
+			},
+			CheckCode:    true,
+			ExpectedCode: 200,
+		},
+		{
+			Description: "Change the authenticated user password with a wrong current password",
+			Route:       "/api/v1/me/password",
+			Method:      "POST",
+			Body: strings.NewReader(tests.JsonToString(requests.UserMePasswordUpdate{
+				CurrentPassword: "wrong-secret",
+				Password:        "new-secret-1",
+			})),
+			Headers: []tests.Header{
+				{Key: "Content-Type", Value: fiber.MIMEApplicationJSONCharsetUTF8},
+				{Key: "Authorization", Value: "Bearer " + tdb.Token},
+				{Key: "X-Request-ID", Value: "me-password"},
+			},
+			CheckBody:    true,
+			CheckCode:    true,
+			ExpectedCode: 400,
+			ExpectedBody: `{"type":"urn:problem-type:validation_failed","title":"Validation failed","status":400,"detail":"Invalid parameters","instance":"me-password","code":"validation_failed","errors":[{"field":"current_password","tag":"password_mismatch","message":"current_password is incorrect"}]}`,
+		},
+		{
+			Description: "Delete the authenticated user without confirmation",
+			Route:       "/api/v1/me",
+			Method:      "DELETE",
+			Body:        strings.NewReader(`{}`),
+			Headers: []tests.Header{
+				{Key: "Content-Type", Value: fiber.MIMEApplicationJSONCharsetUTF8},
+				{Key: "Authorization", Value: "Bearer " + tdb.Token},
+			},
+			CheckCode:    true,
+			ExpectedCode: 400,
+		},
+	}
+
+	tests.Execute(t, tdb.Tx(t), useCases, "../../templates")
+}
+
+func TestUserVerifyEmail(t *testing.T) {
+	useCases := []tests.Test{
+		{
+			Description: "Email verification with an unknown token",
+			Route:       "/api/v1/verify-email/c0f0d5a4-7b4e-4a53-8d6a-1f3e2b5c7d9e",
+			Method:      "POST",
+			Headers: []tests.Header{
+				{Key: "X-Request-ID", Value: "verify-email-404"},
+			},
+			CheckBody:    true,
+			CheckCode:    true,
+			ExpectedCode: 404,
+			ExpectedBody: `{"type":"urn:problem-type:not_found","title":"Resource not found","status":404,"detail":"No email verification found","instance":"verify-email-404","code":"not_found"}`,
+		},
+	}
+
+	tests.Execute(t, tdb.Tx(t), useCases, "../../templates")
+}
+
+func TestUserForgottenPassword(t *testing.T) {
+	forgottenPassword := func(description, email string, expectedCode int) tests.Test {
+		return tests.Test{
+			Description: description,
+			Route:       "/api/v1/forgotten-password",
+			Method:      "POST",
+			Body:        strings.NewReader(tests.JsonToString(requests.UserForgotPassword{Email: values_objects.Email(email)})),
+			Headers: []tests.Header{
+				{Key: "Content-Type", Value: fiber.MIMEApplicationJSONCharsetUTF8},
+			},
+			CheckCode:    true,
+			ExpectedCode: expectedCode,
+		}
+	}
+
+	useCases := []tests.Test{
+		forgottenPassword("Forgotten password with an unknown email", "unknown@test.com", 202),
+		forgottenPassword("Forgotten password with an invalid email", "unknown", 400),
+		forgottenPassword("Forgotten password", tests.UserUsername, 202),
+		forgottenPassword("Forgotten password with the same email", strings.ToUpper(tests.UserUsername), 202),
+		forgottenPassword("Forgotten password with the same email again", tests.UserUsername, 202),
+		{
+			Description: "Forgotten password with too many requests for the same email",
+			Route:       "/api/v1/forgotten-password",
+			Method:      "POST",
+			Body:        strings.NewReader(tests.JsonToString(requests.UserForgotPassword{Email: tests.UserUsername})),
+			Headers: []tests.Header{
+				{Key: "Content-Type", Value: fiber.MIMEApplicationJSONCharsetUTF8},
+				{Key: "X-Request-ID", Value: "forgotten-password-429"},
+			},
+			CheckBody:    true,
+			CheckCode:    true,
+			ExpectedCode: 429,
+			ExpectedBody: `{"type":"urn:problem-type:too_many_requests","title":"Too many requests","status":429,"instance":"forgotten-password-429","code":"too_many_requests"}`,
+		},
+	}
+
+	tests.Execute(t, tdb.Tx(t), useCases, "../../templates")
 }
@@ -1,17 +1,24 @@
 package tests
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/db/migrations"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/adapters/stores"
 	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/entities"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/errs"
+	"github.com/fabienbellanger/fiber-boilerplate/pkg/domain/services"
 	server "github.com/fabienbellanger/fiber-boilerplate/pkg/infrastructure/router"
+	"github.com/fabienbellanger/fiber-boilerplate/utils"
 	"go.uber.org/zap"
 	"io"
 	"log"
 	"math/rand"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -52,6 +59,7 @@ type Header struct {
 }
 
 // Init initializes configuration from .env path and returns TestDB.
+// The .env file is optional, tests use an in-memory SQLite database unless TEST_DB_DRIVER is mysql.
 func Init(p string) TestDB {
 	viper.SetConfigFile(p)
 	viper.ReadInConfig()
@@ -67,6 +75,18 @@ func Init(p string) TestDB {
 	viper.Set("GORM_LOG_OUTPUT", "stdout")
 	viper.Set("LIMITER_ENABLE", false)
 
+	// Emails are not sent during tests
+	services.SendEmail = func(from string, to []string, subject, templateFile string, data interface{}) error {
+		return nil
+	}
+
+	// Works are run before the responses during tests, so that they do not outlive the tests transactions
+	services.RunInBackground = func(work func() *errs.Error) {
+		if err := work(); err != nil {
+			services.LogError(err)
+		}
+	}
+
 	tdb, err := newTestDB()
 	if err != nil {
 		log.Panicf("%v\n", err)
@@ -82,12 +102,38 @@ type TestDB struct {
 }
 
 // newTestDB returns a TestDB instance.
-func newTestDB() (TestDB, error) {
+func newTestDB() (tdb TestDB, err error) {
+	switch viper.GetString("TEST_DB_DRIVER") {
+	case db.DriverMySQL:
+		tdb, err = newMySQLTestDB()
+	default:
+		tdb.DB, err = db.New(&db.DatabaseConfig{Driver: db.DriverSQLite, Database: db.SQLiteMemory})
+	}
+	if err != nil {
+		return TestDB{}, err
+	}
+
+	// Run migrations
+	if _, err = db.NewMigrator(tdb.DB, migrations.All()).Up(); err != nil {
+		return TestDB{}, err
+	}
+
+	// Create first user and get token
+	tdb.Token, err = createUserAndAuthenticate(tdb.DB)
+	if err != nil {
+		return TestDB{}, err
+	}
+
+	return tdb, nil
+}
+
+// newMySQLTestDB creates and uses a randomly named MySQL database.
+func newMySQLTestDB() (TestDB, error) {
 	rand.New(rand.NewSource(time.Now().UTC().UnixNano()))
 	dbName := viper.GetString("DB_DATABASE") + "__" + fmt.Sprintf("%08d", rand.Int63n(1e8))
 
 	config := db.DatabaseConfig{
-		Driver:          viper.GetString("DB_DRIVER"),
+		Driver:          db.DriverMySQL,
 		Host:            viper.GetString("DB_HOST"),
 		Username:        viper.GetString("DB_USERNAME"),
 		Password:        viper.GetString("DB_PASSWORD"),
@@ -106,49 +152,109 @@ func newTestDB() (TestDB, error) {
 		return TestDB{}, err
 	}
 
-	// Create database for test, use it and run migrations
+	// Create database for test and use it
 	dbt.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`;", dbName))
 	dbt.Exec(fmt.Sprintf("USE `%s`;", dbName))
-	dbt.MakeMigrations()
 
-	// Create first user and get token
-	token, err := createUserAndAuthenticate(dbt)
-	if err != nil {
-		return TestDB{}, err
-	}
-
-	return TestDB{DB: dbt, name: dbName, Token: token}, nil
+	return TestDB{DB: dbt, name: dbName}, nil
 }
 
 // Drop database after the test.
 func (tdb *TestDB) Drop() error {
+	if tdb.name == "" {
+		sqlDB, err := tdb.DB.DB.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.Close()
+	}
+
 	result := tdb.DB.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS `%s`;", tdb.name))
 
 	return result.Error
 }
 
-// Create a first user, authenticate him and return JWT.
+// Tx starts a transaction and returns a database using it.
+// The transaction is rolled back at the end of the test, so that tests do not see the changes of each other.
+func (tdb *TestDB) Tx(t *testing.T) *db.DB {
+	tx := tdb.DB.Begin()
+	if tx.Error != nil {
+		t.Fatalf("cannot start transaction: %v", tx.Error)
+	}
+	t.Cleanup(func() {
+		tx.Rollback()
+	})
+
+	return &db.DB{DB: tx}
+}
+
+// LoadFixtures inserts the rows of the fixtures files <dir>/<table>.json in the tables, in the given order.
+// A fixture file contains an array of objects whose keys are the columns.
+func LoadFixtures(database *db.DB, dir string, tables ...string) error {
+	for _, table := range tables {
+		data, err := os.ReadFile(filepath.Join(dir, table+".json"))
+		if err != nil {
+			return err
+		}
+
+		var rows []map[string]interface{}
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return fmt.Errorf("fixtures %s: %w", table, err)
+		}
+		if len(rows) == 0 {
+			continue
+		}
+
+		if err := database.Table(table).Create(&rows).Error; err != nil {
+			return fmt.Errorf("fixtures %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// UserToken returns an access token of a user of the database, with the permissions of its roles.
+func UserToken(t *testing.T, database *db.DB, userID string) string {
+	userStore := stores.NewUserStore(database, utils.NewPasswordHasher(viper.GetString("PASSWORD_HASHER")))
+	user, err := userStore.GetByID(context.Background(), userID)
+	if err != nil || user.ID == "" {
+		t.Fatalf("cannot get user %s: %v", userID, err)
+	}
+
+	token, _, err := user.GenerateJWT(time.Hour, viper.GetString("JWT_ALGO"), viper.GetString("JWT_SECRET"))
+	if err != nil {
+		t.Fatalf("cannot generate token: %v", err)
+	}
+
+	return token
+}
+
+// Create a first user, authenticate them and return JWT.
 func createUserAndAuthenticate(db *db.DB) (token string, err error) {
-	// Create first user
-	userStore := stores.NewUserStore(db)
-	err = userStore.Create(&entities.User{
+	// Create first user with admin role
+	userStore := stores.NewUserStore(db, utils.NewPasswordHasher(viper.GetString("PASSWORD_HASHER")))
+	user := entities.User{
 		Lastname:  "User",
 		Firstname: "Test",
 		Password:  UserPassword,
 		Username:  UserUsername,
-	})
+	}
+	err = userStore.Create(context.Background(), &user)
+	if err != nil {
+		return
+	}
+	err = userStore.AssignRoles(context.Background(), user.ID, entities.RoleAdmin)
 	if err != nil {
 		return
 	}
 
 	// Get User
-	user, err := userStore.Login(UserUsername, UserPassword)
+	user, err = userStore.Login(context.Background(), UserUsername, UserPassword)
 	if err != nil {
 		return
 	}
 
 	// Get token
-	token, _, err = user.GenerateJWT(viper.GetDuration("JWT_LIFETIME"), viper.GetString("JWT_ALGO"), viper.GetString("JWT_SECRET"))
+	token, _, err = user.GenerateJWT(time.Hour, viper.GetString("JWT_ALGO"), viper.GetString("JWT_SECRET"))
 	if err != nil {
 		return
 	}
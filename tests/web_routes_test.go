@@ -25,12 +25,13 @@ func TestWebRoutes(t *testing.T) {
 			Body:        strings.NewReader("v=1"),
 			Headers: []Header{
 				{Key: "Content-Type", Value: "application/x-www-form-urlencoded"},
+				{Key: "X-Request-ID", Value: "not-exists"},
 			},
 			CheckCode:     true,
 			CheckBody:     true,
 			ExpectedError: false,
 			ExpectedCode:  401,
-			ExpectedBody:  "{\"code\":401,\"message\":\"Unauthorized\"}",
+			ExpectedBody:  `{"type":"urn:problem-type:unauthorized","title":"Unauthorized","status":401,"instance":"not-exists","code":"unauthorized"}`,
 		},
 	}
 
@@ -0,0 +1,23 @@
+package utils
+
+import "context"
+
+// contextKey is the type of the keys of the values stored by the application in a context.
+type contextKey string
+
+// requestIDKey is the context key of the request ID.
+const requestIDKey contextKey = "requestId"
+
+// ContextWithRequestID returns a copy of ctx which carries the request ID.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx or an empty string.
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
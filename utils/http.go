@@ -51,29 +51,6 @@ const (
 	StatusNetworkAuthenticationRequired = 511
 )
 
-// HTTPError represents an HTTP error.
-type HTTPError struct {
-	Code    int         `json:"code"`
-	Message string      `json:"message"`
-	Details interface{} `json:"details,omitempty"`
-	Err     error       `json:"-"`
-}
-
-// NewHTTPError returns a new HTTPError.
-func NewHTTPError(code int, message string, details interface{}, err error) *HTTPError {
-
-	return &HTTPError{
-		Code:    code,
-		Message: message,
-		Details: details,
-		Err:     err,
-	}
-}
-
-func (e HTTPError) Error() string {
-	return e.Message
-}
-
 // NewError returns a fiber error and log the error.
 func NewError(c *fiber.Ctx, logger *zap.Logger, msg, details string, err error) *fiber.Error {
 	if logger != nil {
@@ -5,6 +5,7 @@ import (
 	"encoding/pem"
 	"errors"
 	jwtware "github.com/gofiber/contrib/jwt"
+	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"os"
 )
@@ -84,3 +85,14 @@ func GetKeyFromAlgo(algo, secret, keyPath string) (interface{}, error) {
 
 	return key, nil
 }
+
+// GetClaims returns the claims of the JWT stored in the context by the JWT middleware.
+func GetClaims(c *fiber.Ctx) (jwt.MapClaims, bool) {
+	token, ok := c.Locals("user").(*jwt.Token)
+	if !ok {
+		return nil, false
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	return claims, ok
+}
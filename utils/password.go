@@ -0,0 +1,280 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing algorithms
+const (
+	PasswordAlgoArgon2id = "argon2id"
+	PasswordAlgoBcrypt   = "bcrypt"
+)
+
+// ErrInvalidPasswordHash is returned when a stored hash cannot be decoded.
+var ErrInvalidPasswordHash = errors.New("invalid password hash")
+
+// legacySHA512Regexp matches the unsalted SHA-512 hashes stored by previous versions.
+var legacySHA512Regexp = regexp.MustCompile(`^[a-f0-9]{128}$`)
+
+// PasswordAlgorithm is the interface implemented by a password hashing algorithm.
+type PasswordAlgorithm interface {
+	// Hash returns an encoded hash (algorithm, parameters and salt included) of the password.
+	Hash(password string) (string, error)
+
+	// Verify checks the password against an encoded hash produced by this algorithm.
+	Verify(hash, password string) (bool, error)
+
+	// Identify reports whether the encoded hash has been produced by this algorithm.
+	Identify(hash string) bool
+
+	// Outdated reports whether the encoded hash uses weaker parameters than the current ones.
+	Outdated(hash string) bool
+}
+
+// PasswordHasher hashes passwords and verifies them against stored hashes.
+type PasswordHasher interface {
+	// Hash returns an encoded hash of the password with the current algorithm.
+	Hash(password string) (string, error)
+
+	// Verify checks the password against an encoded hash, whatever algorithm produced it.
+	Verify(hash, password string) (bool, error)
+
+	// NeedsRehash reports whether the encoded hash must be replaced by a hash of the current algorithm.
+	NeedsRehash(hash string) bool
+}
+
+// passwordHasher hashes with the current algorithm and verifies with all known algorithms.
+type passwordHasher struct {
+	current    PasswordAlgorithm
+	algorithms []PasswordAlgorithm
+}
+
+// NewPasswordHasher returns a PasswordHasher using the given algorithm for new hashes.
+// The default algorithm is Argon2id.
+func NewPasswordHasher(algo string) PasswordHasher {
+	var current PasswordAlgorithm
+	switch algo {
+	case PasswordAlgoBcrypt:
+		current = NewBcrypt(bcrypt.DefaultCost)
+	default:
+		current = NewArgon2id()
+	}
+
+	return &passwordHasher{
+		current: current,
+		algorithms: []PasswordAlgorithm{
+			current,
+			NewArgon2id(),
+			NewBcrypt(bcrypt.DefaultCost),
+			legacySHA512{},
+		},
+	}
+}
+
+// Hash returns an encoded hash of the password with the current algorithm.
+func (h *passwordHasher) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+// Verify checks the password against an encoded hash, whatever algorithm produced it.
+func (h *passwordHasher) Verify(hash, password string) (bool, error) {
+	for _, a := range h.algorithms {
+		if a.Identify(hash) {
+			return a.Verify(hash, password)
+		}
+	}
+	return false, ErrInvalidPasswordHash
+}
+
+// NeedsRehash reports whether the encoded hash must be replaced by a hash of the current algorithm.
+func (h *passwordHasher) NeedsRehash(hash string) bool {
+	return !h.current.Identify(hash) || h.current.Outdated(hash)
+}
+
+// Argon2id
+// --------
+
+// Argon2id hashes passwords with Argon2id and encodes them in the PHC string format:
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>
+type Argon2id struct {
+	Memory      uint32 // In KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// NewArgon2id returns an Argon2id algorithm with the recommended parameters.
+func NewArgon2id() Argon2id {
+	return Argon2id{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// Hash returns an encoded Argon2id hash of the password with a random salt.
+func (a Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		a.Memory,
+		a.Iterations,
+		a.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify checks the password against an encoded Argon2id hash.
+func (a Argon2id) Verify(hash, password string) (bool, error) {
+	params, salt, key, err := a.decode(hash)
+	if err != nil {
+		return false, err
+	}
+
+	otherKey := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+}
+
+// Identify reports whether the encoded hash is an Argon2id hash.
+func (a Argon2id) Identify(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+// Outdated reports whether the encoded hash uses other parameters than the current ones.
+func (a Argon2id) Outdated(hash string) bool {
+	params, salt, key, err := a.decode(hash)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != a.Memory ||
+		params.Iterations != a.Iterations ||
+		params.Parallelism != a.Parallelism ||
+		uint32(len(salt)) != a.SaltLength ||
+		uint32(len(key)) != a.KeyLength
+}
+
+// decode extracts parameters, salt and key from an encoded Argon2id hash.
+func (a Argon2id) decode(hash string) (params Argon2id, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+
+	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+
+	key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+
+	return params, salt, key, nil
+}
+
+// Bcrypt
+// ------
+
+// Bcrypt hashes passwords with bcrypt.
+type Bcrypt struct {
+	Cost int
+}
+
+// NewBcrypt returns a bcrypt algorithm with the given cost.
+func NewBcrypt(cost int) Bcrypt {
+	return Bcrypt{Cost: cost}
+}
+
+// Hash returns a bcrypt hash of the password.
+// The password is truncated to 72 bytes by bcrypt.
+func (b Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify checks the password against a bcrypt hash.
+func (b Bcrypt) Verify(hash, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Identify reports whether the encoded hash is a bcrypt hash.
+func (b Bcrypt) Identify(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// Outdated reports whether the bcrypt hash uses another cost than the current one.
+func (b Bcrypt) Outdated(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != b.Cost
+}
+
+// Legacy SHA-512
+// --------------
+
+// legacySHA512 verifies the unsalted SHA-512 hashes stored by previous versions.
+// It must never be used as the current algorithm.
+type legacySHA512 struct{}
+
+// Hash returns the hexadecimal SHA-512 of the password.
+func (legacySHA512) Hash(password string) (string, error) {
+	hash := sha512.Sum512([]byte(password))
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// Verify checks the password against a SHA-512 hash.
+func (l legacySHA512) Verify(hash, password string) (bool, error) {
+	h, _ := l.Hash(password)
+	return subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1, nil
+}
+
+// Identify reports whether the hash is a SHA-512 hexadecimal hash.
+func (legacySHA512) Identify(hash string) bool {
+	return legacySHA512Regexp.MatchString(hash)
+}
+
+// Outdated always returns true.
+func (legacySHA512) Outdated(_ string) bool {
+	return true
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestArgon2idHashAndVerify(t *testing.T) {
+	a := NewArgon2id()
+
+	hash, err := a.Hash("00000000")
+	assert.Nil(t, err)
+	assert.True(t, a.Identify(hash))
+	assert.False(t, a.Outdated(hash))
+
+	ok, err := a.Verify(hash, "00000000")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = a.Verify(hash, "11111111")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	// Salt must be random
+	otherHash, _ := a.Hash("00000000")
+	assert.NotEqual(t, hash, otherHash)
+
+	// Different parameters
+	weaker := a
+	weaker.Iterations = 1
+	weakerHash, err := weaker.Hash("00000000")
+	assert.Nil(t, err)
+	assert.True(t, a.Outdated(weakerHash))
+
+	_, err = a.Verify("$argon2id$v=19$bad", "00000000")
+	assert.Equal(t, ErrInvalidPasswordHash, err)
+}
+
+func TestPasswordHasher(t *testing.T) {
+	legacyHash, _ := legacySHA512{}.Hash("00000000")
+	bcryptHash, _ := NewBcrypt(bcrypt.MinCost).Hash("00000000")
+
+	tests := []struct {
+		name        string
+		algo        string
+		hash        string
+		password    string
+		valid       bool
+		needsRehash bool
+	}{
+		{"Legacy SHA-512 hash", PasswordAlgoArgon2id, legacyHash, "00000000", true, true},
+		{"Legacy SHA-512 hash with wrong password", PasswordAlgoArgon2id, legacyHash, "11111111", false, true},
+		{"Bcrypt hash with Argon2id hasher", PasswordAlgoArgon2id, bcryptHash, "00000000", true, true},
+		{"Bcrypt hash with bcrypt hasher and another cost", PasswordAlgoBcrypt, bcryptHash, "00000000", true, true},
+		{"Bcrypt hash with wrong password", PasswordAlgoBcrypt, bcryptHash, "11111111", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewPasswordHasher(tt.algo)
+
+			ok, err := h.Verify(tt.hash, tt.password)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.valid, ok)
+			assert.Equal(t, tt.needsRehash, h.NeedsRehash(tt.hash))
+		})
+	}
+
+	h := NewPasswordHasher("")
+	hash, err := h.Hash("00000000")
+	assert.Nil(t, err)
+	assert.True(t, NewArgon2id().Identify(hash))
+	assert.False(t, h.NeedsRehash(hash))
+
+	_, err = h.Verify("unknown", "00000000")
+	assert.Equal(t, ErrInvalidPasswordHash, err)
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MIMEApplicationProblemJSON is the content type of the error responses (RFC 7807).
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// ProblemTypePrefix is the prefix of the URI identifying a problem type, followed by the error code.
+const ProblemTypePrefix = "urn:problem-type:"
+
+// Error codes catalogue
+// ---------------------
+// The codes are stable and can be used by clients to handle errors.
+const (
+	ErrCodeBadRequest         = "bad_request"
+	ErrCodeInvalidBody        = "invalid_body"
+	ErrCodeInvalidParameters  = "invalid_parameters"
+	ErrCodeValidationFailed   = "validation_failed"
+	ErrCodeUnauthorized       = "unauthorized"
+	ErrCodeForbidden          = "forbidden"
+	ErrCodeNotFound           = "not_found"
+	ErrCodeMethodNotAllowed   = "method_not_allowed"
+	ErrCodeConflict           = "conflict"
+	ErrCodeTooManyRequests    = "too_many_requests"
+	ErrCodeInternal           = "internal_error"
+	ErrCodeServiceUnavailable = "service_unavailable"
+	ErrCodeGatewayTimeout     = "gateway_timeout"
+	ErrCodeHTTP               = "http_error" // Other HTTP errors
+)
+
+// ProblemType represents a kind of problem of the catalogue.
+type ProblemType struct {
+	Status int
+	Title  string
+}
+
+// ProblemTypes maps the error codes to their problem type.
+var ProblemTypes = map[string]ProblemType{
+	ErrCodeBadRequest:         {StatusBadRequest, "Bad request"},
+	ErrCodeInvalidBody:        {StatusBadRequest, "Invalid request body"},
+	ErrCodeInvalidParameters:  {StatusBadRequest, "Invalid request parameters"},
+	ErrCodeValidationFailed:   {StatusBadRequest, "Validation failed"},
+	ErrCodeUnauthorized:       {StatusUnauthorized, "Unauthorized"},
+	ErrCodeForbidden:          {StatusForbidden, "Forbidden"},
+	ErrCodeNotFound:           {StatusNotFound, "Resource not found"},
+	ErrCodeMethodNotAllowed:   {StatusMethodNotAllowed, "Method not allowed"},
+	ErrCodeConflict:           {StatusConflict, "Conflict"},
+	ErrCodeTooManyRequests:    {StatusTooManyRequests, "Too many requests"},
+	ErrCodeInternal:           {StatusInternalServerError, "Internal server error"},
+	ErrCodeServiceUnavailable: {StatusServiceUnavailable, "Service unavailable"},
+	ErrCodeGatewayTimeout:     {StatusGatewayTimeout, "Gateway timeout"},
+}
+
+// statusCodes maps the HTTP status codes to their default error code.
+var statusCodes = map[int]string{
+	StatusBadRequest:          ErrCodeBadRequest,
+	StatusUnauthorized:        ErrCodeUnauthorized,
+	StatusForbidden:           ErrCodeForbidden,
+	StatusNotFound:            ErrCodeNotFound,
+	StatusMethodNotAllowed:    ErrCodeMethodNotAllowed,
+	StatusConflict:            ErrCodeConflict,
+	StatusTooManyRequests:     ErrCodeTooManyRequests,
+	StatusInternalServerError: ErrCodeInternal,
+	StatusServiceUnavailable:  ErrCodeServiceUnavailable,
+	StatusGatewayTimeout:      ErrCodeGatewayTimeout,
+}
+
+// Problem represents an error response (RFC 7807).
+// Code, Errors and Details are extension members.
+type Problem struct {
+	Type     string          `json:"type"`
+	Title    string          `json:"title"`
+	Status   int             `json:"status"`
+	Detail   string          `json:"detail,omitempty"`
+	Instance string          `json:"instance,omitempty"` // Request ID
+	Code     string          `json:"code"`
+	Errors   ValidatorErrors `json:"errors,omitempty"`  // Invalid fields
+	Details  interface{}     `json:"details,omitempty"` // Other information about the problem
+}
+
+// NewProblem returns the problem of an error code of the catalogue.
+func NewProblem(code, detail string) Problem {
+	pt, ok := ProblemTypes[code]
+	if !ok {
+		code = ErrCodeInternal
+		pt = ProblemTypes[code]
+	}
+
+	return Problem{
+		Type:   ProblemTypePrefix + code,
+		Title:  pt.Title,
+		Status: pt.Status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// NewStatusProblem returns the problem of an HTTP status code.
+// Status codes without a dedicated error code use ErrCodeHTTP.
+func NewStatusProblem(status int, detail string) Problem {
+	if code, ok := statusCodes[status]; ok {
+		return NewProblem(code, detail)
+	}
+
+	return Problem{
+		Type:   ProblemTypePrefix + ErrCodeHTTP,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   ErrCodeHTTP,
+	}
+}
+
+// SendProblem sends a problem as an application/problem+json response.
+// The request ID is used as the problem instance and the messages of the validation errors
+// are in the language of the Accept-Language header.
+func SendProblem(c *fiber.Ctx, p Problem) error {
+	p.Errors = p.Errors.Localize(c.AcceptsLanguages(Languages...))
+
+	if p.Instance == "" {
+		if requestID, ok := c.Locals("requestid").(string); ok {
+			p.Instance = requestID
+		}
+	}
+
+	return c.Status(p.Status).JSON(p, MIMEApplicationProblemJSON)
+}
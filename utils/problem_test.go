@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewProblem(t *testing.T) {
+	p := NewProblem(ErrCodeNotFound, "No user found")
+	assert.Equal(t, Problem{
+		Type:   "urn:problem-type:not_found",
+		Title:  "Resource not found",
+		Status: StatusNotFound,
+		Detail: "No user found",
+		Code:   ErrCodeNotFound,
+	}, p)
+
+	p = NewProblem("unknown", "")
+	assert.Equal(t, ErrCodeInternal, p.Code)
+	assert.Equal(t, StatusInternalServerError, p.Status)
+}
+
+func TestNewStatusProblem(t *testing.T) {
+	p := NewStatusProblem(StatusTooManyRequests, "")
+	assert.Equal(t, ErrCodeTooManyRequests, p.Code)
+
+	p = NewStatusProblem(StatusTeapot, "")
+	assert.Equal(t, ErrCodeHTTP, p.Code)
+	assert.Equal(t, StatusTeapot, p.Status)
+	assert.Equal(t, "I'm a teapot", p.Title)
+}
+
+func TestSendProblem(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Locals("requestid", "request-id")
+		return SendProblem(c, NewProblem(ErrCodeForbidden, ""))
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	assert.Nil(t, err)
+	assert.Equal(t, StatusForbidden, resp.StatusCode)
+	assert.Equal(t, MIMEApplicationProblemJSON, resp.Header.Get(fiber.HeaderContentType))
+
+	var p Problem
+	assert.Nil(t, json.NewDecoder(resp.Body).Decode(&p))
+	assert.Equal(t, "request-id", p.Instance)
+	assert.Equal(t, ErrCodeForbidden, p.Code)
+}
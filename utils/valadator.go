@@ -1,48 +1,125 @@
 package utils
 
 import (
-	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 )
 
 // ValidatorError represents error validation struct.
 type ValidatorError struct {
-	FailedField string
-	Tag         string
-	Value       string
+	FailedField string   `json:"field"`           // JSON name of the field
+	Tag         string   `json:"tag"`             // Failed validation tag
+	Value       string   `json:"value,omitempty"` // Parameter of the tag (Ex.: 8 for min=8)
+	Message     string   `json:"message,omitempty"`
+	Allowed     []string `json:"allowed,omitempty"` // Allowed values, when the value is not one of them
+	Kind        string   `json:"-"`                 // Kind of the field value (string, number or empty), used to choose the message
 }
 
-// TODO: Change
+// Error returns the message of the error in the default language.
 func (ve ValidatorError) Error() string {
-	return "Field: %s, Tag: %s, Value: %s"
+	return ve.message(DefaultLanguage)
 }
 
 // ValidatorErrors is a slice of ValidatorError.
 type ValidatorErrors []ValidatorError
 
-// TODO: Change
+// Error returns the messages of the errors in the default language.
 func (ve ValidatorErrors) Error() string {
-	e := ""
-	for _, v := range ve {
-		e += fmt.Sprintf("%s\n", v)
+	messages := make([]string, len(ve))
+	for i, e := range ve {
+		messages[i] = e.Error()
 	}
-	return e
+	return strings.Join(messages, "; ")
+}
+
+// Localize returns a copy of the errors with their message in the given language.
+// The default language is used if the language is not supported.
+func (ve ValidatorErrors) Localize(lang string) ValidatorErrors {
+	if ve == nil {
+		return nil
+	}
+
+	errs := make(ValidatorErrors, len(ve))
+	for i, e := range ve {
+		e.Message = e.message(lang)
+		errs[i] = e
+	}
+	return errs
+}
+
+// validate is the validator shared by all validations.
+// Custom validations must be registered with RegisterValidation.
+var validate = newValidator()
+
+// newValidator returns a validator which reports the JSON names of the fields.
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(fieldName)
+	return v
+}
+
+// fieldName returns the name of a field in the json, query or form tag, the Go name otherwise.
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "query", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(key), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// RegisterValidation adds a custom validation with its messages by language.
+// It is not safe for concurrent use and must be called during initialization (in an init function).
+func RegisterValidation(tag string, fn validator.Func, messages map[string]string) error {
+	if err := validate.RegisterValidation(tag, fn); err != nil {
+		return err
+	}
+
+	for lang, message := range messages {
+		if _, ok := validationMessages[lang]; ok {
+			validationMessages[lang][tag] = message
+		}
+	}
+	return nil
+}
+
+// RegisterStructValidation adds a struct level validation for the given types.
+// The messages of the reported tags must be in the catalogue (see RegisterValidation).
+// It is not safe for concurrent use and must be called during initialization (in an init function).
+func RegisterStructValidation(fn validator.StructLevelFunc, types ...interface{}) {
+	validate.RegisterStructValidation(fn, types...)
 }
 
 // ValidateStruct checks if a struct is valid and returns an array of errors
 // if it is not valid.
-func ValidateStruct(task interface{}) (errors ValidatorErrors) {
-	validate := validator.New()
-	err := validate.Struct(task)
+func ValidateStruct(s interface{}) (errors ValidatorErrors) {
+	err := validate.Struct(s)
 	if err != nil {
 		for _, err := range err.(validator.ValidationErrors) {
 			errors = append(errors, ValidatorError{
 				FailedField: err.Field(),
 				Tag:         err.Tag(),
 				Value:       err.Param(),
+				Kind:        valueKind(err.Kind()),
 			})
 		}
 	}
 	return
 }
+
+// valueKind returns the kind of value used to choose a message.
+func valueKind(k reflect.Kind) string {
+	switch k {
+	case reflect.String:
+		return "string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+type validatedRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password,omitempty" validate:"required,min=8"`
+	Page     int    `query:"page" validate:"max=10"`
+	Code     string `validate:"even_length"`
+}
+
+func TestValidateStruct(t *testing.T) {
+	err := RegisterValidation("even_length", func(fl validator.FieldLevel) bool {
+		return len(fl.Field().String())%2 == 0
+	}, map[string]string{
+		"en": "{field} must have an even length",
+		"fr": "{field} doit avoir une longueur paire",
+	})
+	assert.Nil(t, err)
+
+	errs := ValidateStruct(validatedRequest{Email: "test", Password: "1234", Page: 11, Code: "abc"})
+	assert.Equal(t, ValidatorErrors{
+		{FailedField: "email", Tag: "email", Kind: "string"},
+		{FailedField: "password", Tag: "min", Value: "8", Kind: "string"},
+		{FailedField: "page", Tag: "max", Value: "10", Kind: "number"},
+		{FailedField: "Code", Tag: "even_length", Kind: "string"},
+	}, errs)
+
+	assert.Equal(t, []string{
+		"email must be a valid email address",
+		"password must be at least 8 characters long",
+		"page must be 10 or less",
+		"Code must have an even length",
+	}, messages(errs.Localize("en")))
+	assert.Equal(t, []string{
+		"email doit être une adresse email valide",
+		"password doit contenir au moins 8 caractères",
+		"page doit être inférieur ou égal à 10",
+		"Code doit avoir une longueur paire",
+	}, messages(errs.Localize("fr")))
+
+	// Unsupported language
+	assert.Equal(t, "email must be a valid email address", errs.Localize("de")[0].Message)
+
+	assert.Nil(t, ValidateStruct(validatedRequest{Email: "test@test.com", Password: "12345678", Code: "ab"}))
+}
+
+func TestValidatorErrorsError(t *testing.T) {
+	errs := ValidatorErrors{
+		{FailedField: "email", Tag: "required"},
+		{FailedField: "filter[name][like]", Tag: "operator", Value: "like"},
+		{FailedField: "name", Tag: "unknown"},
+		{FailedField: "s", Tag: "sort", Value: "password", Allowed: []string{"id", "name"}},
+		{FailedField: "state", Tag: "oneof", Value: "closed", Kind: "string", Allowed: []string{"todo", "done"}},
+	}
+	assert.Equal(t, "email is required; filter[name][like]: the operator like is not supported; name is invalid; "+
+		"s: password cannot be used to sort, allowed fields: id, name; "+
+		"state: closed is not allowed, allowed values: todo, done", errs.Error())
+}
+
+func messages(errs ValidatorErrors) []string {
+	m := make([]string, len(errs))
+	for i, e := range errs {
+		m[i] = e.Message
+	}
+	return m
+}
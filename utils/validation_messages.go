@@ -0,0 +1,99 @@
+package utils
+
+import "strings"
+
+// DefaultLanguage is the language used when the requested language is not supported.
+const DefaultLanguage = "en"
+
+// Languages is the list of supported languages.
+var Languages = []string{"en", "fr"}
+
+// validationMessages maps the languages to the messages of the validation tags.
+// A message for a kind of value is named "<tag>_<kind>" and takes precedence over "<tag>".
+// When the allowed values are given, the message named "<tag>_allowed" is used first.
+// {field} is replaced by the field name, {param} by the tag parameter and {allowed} by the allowed values.
+var validationMessages = map[string]map[string]string{
+	"en": {
+		"default":       "{field} is invalid",
+		"required":      "{field} is required",
+		"email":         "{field} must be a valid email address",
+		"uuid":          "{field} must be a valid UUID",
+		"min_string":    "{field} must be at least {param} characters long",
+		"min":           "{field} must be {param} or greater",
+		"max_string":    "{field} must be at most {param} characters long",
+		"max":           "{field} must be {param} or less",
+		"oneof":         "{field} must be one of: {param}",
+		"oneof_allowed": "{field}: {param} is not allowed, allowed values: {allowed}",
+		"filter":        "{field} is not a valid filter",
+		"operator":      "{field}: the operator {param} is not supported",
+		"field":         "{field} cannot be used as a filter",
+		"value":         "{field}: the value {param} is invalid",
+		"cursor":        "{field} is invalid or expired",
+		"sort":          "{field}: {param} cannot be used to sort, allowed fields: {allowed}",
+
+		"password_lowercase": "{field} must contain at least one lowercase letter",
+		"password_uppercase": "{field} must contain at least one uppercase letter",
+		"password_digit":     "{field} must contain at least one digit",
+		"password_symbol":    "{field} must contain at least one symbol",
+		"password_common":    "{field} is too common",
+		"password_personal":  "{field} must not contain your name or email",
+		"password_mismatch":  "{field} is incorrect",
+	},
+	"fr": {
+		"default":       "{field} est invalide",
+		"required":      "{field} est obligatoire",
+		"email":         "{field} doit être une adresse email valide",
+		"uuid":          "{field} doit être un UUID valide",
+		"min_string":    "{field} doit contenir au moins {param} caractères",
+		"min":           "{field} doit être supérieur ou égal à {param}",
+		"max_string":    "{field} doit contenir au plus {param} caractères",
+		"max":           "{field} doit être inférieur ou égal à {param}",
+		"oneof":         "{field} doit être l'une des valeurs suivantes : {param}",
+		"oneof_allowed": "{field} : {param} n'est pas autorisé, valeurs autorisées : {allowed}",
+		"filter":        "{field} n'est pas un filtre valide",
+		"operator":      "{field} : l'opérateur {param} n'est pas supporté",
+		"field":         "{field} ne peut pas être utilisé comme filtre",
+		"value":         "{field} : la valeur {param} est invalide",
+		"cursor":        "{field} est invalide ou expiré",
+		"sort":          "{field} : {param} ne peut pas être utilisé pour trier, champs autorisés : {allowed}",
+
+		"password_lowercase": "{field} doit contenir au moins une lettre minuscule",
+		"password_uppercase": "{field} doit contenir au moins une lettre majuscule",
+		"password_digit":     "{field} doit contenir au moins un chiffre",
+		"password_symbol":    "{field} doit contenir au moins un symbole",
+		"password_common":    "{field} est trop courant",
+		"password_personal":  "{field} ne doit pas contenir votre nom ou votre email",
+		"password_mismatch":  "{field} est incorrect",
+	},
+}
+
+// message returns the message of the error in the given language.
+func (ve ValidatorError) message(lang string) string {
+	messages, ok := validationMessages[lang]
+	if !ok {
+		messages = validationMessages[DefaultLanguage]
+	}
+
+	keys := make([]string, 0, 4)
+	if len(ve.Allowed) > 0 {
+		keys = append(keys, ve.Tag+"_allowed")
+	}
+	if ve.Kind != "" {
+		keys = append(keys, ve.Tag+"_"+ve.Kind)
+	}
+	keys = append(keys, ve.Tag, "default")
+
+	var message string
+	for _, key := range keys {
+		if m, ok := messages[key]; ok {
+			message = m
+			break
+		}
+	}
+
+	return strings.NewReplacer(
+		"{field}", ve.FailedField,
+		"{param}", ve.Value,
+		"{allowed}", strings.Join(ve.Allowed, ", "),
+	).Replace(message)
+}